@@ -4,7 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -25,6 +27,23 @@ func NewBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Bl
 	return b
 }
 
+// genesisBlock is block 0 of every chain. It is fixed, so nodes started
+// apart share it and ValidChain can reject chains built on another.
+func genesisBlock() *Block {
+	return RestoreBlock(0, 0, (&Block{}).Hash(), []*Transaction{})
+}
+
+// RestoreBlock rebuilds a block received from a peer, keeping its
+// original timestamp.
+func RestoreBlock(timestamp int64, nonce int, previousHash [32]byte, transactions []*Transaction) *Block {
+	return &Block{
+		nonce:        nonce,
+		previousHash: previousHash,
+		timestamp:    timestamp,
+		transactions: transactions,
+	}
+}
+
 func (b *Block) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Timestamp int64 `json:"timestamp"`
@@ -41,14 +60,17 @@ func (b *Block) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// UnmarshalJSON decodes a block as served by a peer, which may be
+// hostile: previous_hash must be 32 hex encoded bytes and transactions
+// must not be null.
 func (b *Block) UnmarshalJSON(data []byte) error {
 
-	var previousHash string
+	var previousHash *string
 
 	v := &struct {
 		Timestamp    *int64          `json:"timestamp"`
 		Nonce        *int            `json:"nonce"`
-		PreviousHash *string         `json:"previous_hash"`
+		PreviousHash **string        `json:"previous_hash"`
 		Transactions *[]*Transaction `json:"transactions"`
 	}{
 		Timestamp:    &b.timestamp,
@@ -61,8 +83,18 @@ func (b *Block) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	ph, _ := hex.DecodeString(*v.PreviousHash)
-	copy(b.previousHash[:], ph[:32])
+	if previousHash == nil {
+		return errors.New("block: previous_hash is required")
+	}
+	ph, err := hex.DecodeString(*previousHash)
+	if err != nil || len(ph) != len(b.previousHash) {
+		return fmt.Errorf("block: previous_hash must be %d hex encoded bytes", len(b.previousHash))
+	}
+	copy(b.previousHash[:], ph)
+
+	if slices.Contains(b.transactions, nil) {
+		return errors.New("block: null transaction")
+	}
 
 	return nil
 }
@@ -85,6 +117,17 @@ func (b *Block) Hash() [32]byte {
 	return sha256.Sum256(m)
 }
 
+func (b *Block) contains(t *Transaction) bool {
+	for _, bt := range b.transactions {
+		if bt.senderBlockchainAddress == t.senderBlockchainAddress &&
+			bt.recipientBlockchainAddress == t.recipientBlockchainAddress &&
+			bt.value == t.value {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *Block) Transactions() []*Transaction {
 	return b.transactions
 }
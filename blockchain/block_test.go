@@ -1,9 +1,13 @@
 package blockchain
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMakeBlock(t *testing.T) {
@@ -16,3 +20,24 @@ func TestMakeBlock(t *testing.T) {
 	assert.NotEqual(t, b.nonce, 1)
 	assert.NotEqual(t, b.previousHash, "Final hash")
 }
+
+func TestUnmarshalBlock(t *testing.T) {
+	//
+	b := NewBlock(7, sha256.Sum256([]byte("previous")), []*Transaction{NewTransaction("alice", "bob", 1)})
+	m, err := json.Marshal(b)
+	require.NoError(t, err)
+	var decoded Block
+	require.NoError(t, json.Unmarshal(m, &decoded))
+	assert.Equal(t, b.Hash(), decoded.Hash())
+	//
+	for _, raw := range []string{
+		`{"nonce":1}`,
+		`{"previous_hash":null}`,
+		`{"previous_hash":"00"}`,
+		`{"previous_hash":"` + strings.Repeat("0", 66) + `"}`,
+		`{"previous_hash":"` + strings.Repeat("z", 64) + `"}`,
+		`{"previous_hash":"` + strings.Repeat("0", 64) + `","transactions":[null]}`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(raw), new(Block)), raw)
+	}
+}
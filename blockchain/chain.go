@@ -1,17 +1,19 @@
 package blockchain
 
 import (
-	"bytes"
-	"crypto/ecdsa"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/i101dev/blockchain-api/client"
 	"github.com/i101dev/blockchain-api/utils"
 )
 
@@ -27,72 +29,333 @@ const (
 	NEIGHBOR_IP_RANGE_START          = 0
 	NEIGHBOR_IP_RANGE_END            = 1
 	BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC = 20
+	PEER_REQUEST_TIMEOUT             = 5 * time.Second
+)
+
+var (
+	ErrInvalidProof  = errors.New("invalid proof of work")
+	ErrInvalidReward = errors.New("invalid mining reward")
 )
 
 // ------------------------------------------------------------------
 
 type Blockchain struct {
-	mux               sync.Mutex
+	// mux guards the chain and transaction pool. Exported methods take it
+	// themselves and return copies; unexported helpers expect it held.
+	mux               sync.RWMutex
 	transactionPool   []*Transaction // equivalent to `memPool`
 	chain             []*Block
 	blockchainAddress string
 	port              uint16
+	params            Params
+	id                string
+	peerPath          string
 
 	muxNeighbors sync.Mutex
 	peers        []string
+	staticPeers  map[string]bool
+	removedPeers map[string]bool
+	peerHeight   int
+	// peerIDs are the node IDs of peers that completed a handshake.
+	peerIDs  map[string]string
+	identity *utils.NodeIdentity
+
+	miner  loop
+	syncer loop
+
+	// muxResolve lets one ResolveConflicts run at a time; it downloads
+	// the full chain of every peer.
+	muxResolve sync.Mutex
+
+	events     EventBus
+	metrics    *Metrics
+	peerScores *PeerScores
 }
 
-func NewBlockchain(blockchainAddress string, port uint16) *Blockchain {
+func NewBlockchain(blockchainAddress string, port uint16, opts ...Option) *Blockchain {
 
-	b := &Block{}
 	bc := new(Blockchain)
 
 	bc.port = port
 	bc.blockchainAddress = blockchainAddress
+	bc.params = DefaultParams()
+
+	for _, opt := range opts {
+		opt(bc)
+	}
+
+	bc.peerIDs = make(map[string]string)
+	bc.staticPeers = make(map[string]bool)
+	for _, p := range bc.params.Peers {
+		bc.staticPeers[p] = true
+		bc.peers = append(bc.peers, p)
+	}
+	bc.metrics.add(bc)
 
-	bc.CreateBlock(0, b.Hash())
+	bc.chain = []*Block{genesisBlock()}
 
 	return bc
 }
 
+// logger tags records with the chain ID.
+func (bc *Blockchain) logger() *slog.Logger {
+	return slog.With("chain", bc.id)
+}
+
+func (bc *Blockchain) Params() Params {
+	return bc.params
+}
+
+func (bc *Blockchain) ID() string {
+	return bc.id
+}
+
+// MinerAddress receives the rewards of blocks mined here. The chain does
+// not mine when it is empty.
+func (bc *Blockchain) MinerAddress() string {
+	return bc.blockchainAddress
+}
+
 func (bc *Blockchain) Run() {
 	// bc.StartMining()
 	bc.StartSyncPeers()
 	bc.ResolveConflicts()
 }
 
+// SetNeighbors rescans the local port range for peers and shakes hands
+// with new ones. Peers added by an operator are always kept, and peers
+// they removed are never rediscovered.
 func (bc *Blockchain) SetNeighbors() {
-	bc.peers = utils.FindNeighbors(
+	found := utils.FindNeighbors(
 		utils.GetHost(), bc.port,
-		NEIGHBOR_IP_RANGE_START, NEIGHBOR_IP_RANGE_END,
-		BLOCKCHAIN_PORT_RANGE_START, BLOCKCHAIN_PORT_RANGE_END)
-	log.Printf("%v", bc.peers)
+		bc.params.IPRangeStart, bc.params.IPRangeEnd,
+		bc.params.PortRangeStart, bc.params.PortRangeEnd)
+
+	bc.muxNeighbors.Lock()
+	peers := make([]string, 0, len(found)+len(bc.staticPeers))
+	for _, p := range found {
+		if !bc.removedPeers[p] && !bc.staticPeers[p] && !bc.banned(p) {
+			peers = append(peers, p)
+		}
+	}
+	for p := range bc.staticPeers {
+		if !bc.banned(p) {
+			peers = append(peers, p)
+		}
+	}
+	bc.muxNeighbors.Unlock()
+
+	peers = bc.identify(peers)
+	sort.Strings(peers)
+
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+
+	// A handshake may have shown a peer to be a banned node.
+	bc.peers = slices.DeleteFunc(peers, bc.banned)
+	bc.logger().Debug("neighbors scanned", "peers", bc.peers)
 }
 
-func (bc *Blockchain) SyncNeighbors() {
+// banned reports whether peer is banned by address or node ID. The
+// caller holds muxNeighbors.
+func (bc *Blockchain) banned(peer string) bool {
+	id := bc.peerIDs[peer]
+	return bc.peerScores.Banned(peer) || (id != "" && bc.peerScores.Banned(id))
+}
+
+// AddPeer adds a peer by "host:port" and keeps it across rescans.
+func (bc *Blockchain) AddPeer(peer string) {
 	bc.muxNeighbors.Lock()
 	defer bc.muxNeighbors.Unlock()
+
+	if bc.staticPeers == nil {
+		bc.staticPeers = make(map[string]bool)
+	}
+	bc.staticPeers[peer] = true
+	delete(bc.removedPeers, peer)
+
+	if !slices.Contains(bc.peers, peer) {
+		bc.peers = append(bc.peers, peer)
+		sort.Strings(bc.peers)
+	}
+}
+
+// RemovePeer drops a peer and stops it being rediscovered. It reports
+// whether the peer was known.
+func (bc *Blockchain) RemovePeer(peer string) bool {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+
+	if bc.removedPeers == nil {
+		bc.removedPeers = make(map[string]bool)
+	}
+	bc.removedPeers[peer] = true
+	delete(bc.staticPeers, peer)
+
+	i := slices.Index(bc.peers, peer)
+	if i < 0 {
+		return false
+	}
+	bc.peers = slices.Delete(bc.peers, i, i+1)
+	return true
+}
+
+// misbehaved scores a peer this node called by "host:port" and, once
+// known, by node ID, dropping it from the peers if either is banned.
+// Rescans skip it until the ban ends.
+func (bc *Blockchain) misbehaved(peer string, points int, reason string) {
+
+	bc.muxNeighbors.Lock()
+	id := bc.peerIDs[peer]
+	bc.muxNeighbors.Unlock()
+
+	banned := bc.peerScores.Misbehaved(peer, points, reason)
+	if id != "" && bc.peerScores.Misbehaved(id, points, reason) {
+		banned = true
+	}
+	if !banned {
+		return
+	}
+
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	if i := slices.Index(bc.peers, peer); i >= 0 {
+		bc.peers = slices.Delete(bc.peers, i, i+1)
+	}
+}
+
+func (bc *Blockchain) SyncNeighbors() {
 	bc.SetNeighbors()
 }
 
+// StartSyncPeers scans for neighbors now and then every sync interval
+// until StopSyncPeers is called.
 func (bc *Blockchain) StartSyncPeers() {
 	bc.SyncNeighbors()
-	_ = time.AfterFunc(time.Second*BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC, bc.StartSyncPeers)
+	bc.syncer.start(bc.params.SyncInterval, bc.params.SyncInterval, bc.SyncNeighbors)
+}
+
+func (bc *Blockchain) StopSyncPeers() {
+	bc.syncer.stop()
+}
+
+// Stop ends the mining and peer sync loops, waiting for any run in
+// progress, so the chain can be saved.
+func (bc *Blockchain) Stop() {
+	bc.StopMining()
+	bc.StopSyncPeers()
 }
 
+// Chain returns a copy of the blocks, genesis first.
 func (bc *Blockchain) Chain() []*Block {
-	return bc.chain
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return slices.Clone(bc.chain)
+}
+
+func (bc *Blockchain) Height() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.height()
+}
+
+// height is Height for a caller holding mux.
+func (bc *Blockchain) height() int {
+	return len(bc.chain) - 1
+}
+
+func (bc *Blockchain) BlockByHeight(height int) (*Block, bool) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if height < 0 || height >= len(bc.chain) {
+		return nil, false
+	}
+	return bc.chain[height], true
+}
+
+// BlockByHash looks a block up by the hex hash of its JSON, returning
+// its height as well.
+func (bc *Blockchain) BlockByHash(hash string) (*Block, int, bool) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	for i, b := range bc.chain {
+		if fmt.Sprintf("%x", b.Hash()) == hash {
+			return b, i, true
+		}
+	}
+	return nil, 0, false
+}
+
+// FindTransaction looks a transaction up by hash, first in the chain and
+// then in the pool. The height is -1 for a pending transaction.
+func (bc *Blockchain) FindTransaction(hash string) (*Transaction, int, bool) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	for i, b := range bc.chain {
+		for _, t := range b.transactions {
+			if t.id() == hash {
+				return t, i, true
+			}
+		}
+	}
+	for _, t := range bc.transactionPool {
+		if t.id() == hash {
+			return t, -1, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Peers returns a copy of the peer list, which rescans and peer
+// management change under muxNeighbors while callers iterate it.
+func (bc *Blockchain) Peers() []string {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	return append([]string{}, bc.peers...)
+}
+
+// ObservePeerHeight records the height of a peer's tip, once an announced
+// block is accepted or a fetched chain validates.
+func (bc *Blockchain) ObservePeerHeight(height int) {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	bc.peerHeight = max(bc.peerHeight, height)
+}
+
+// SyncLag is how many blocks the highest peer tip seen is ahead of the
+// local tip.
+func (bc *Blockchain) SyncLag() int {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	return max(bc.peerHeight-bc.Height(), 0)
 }
 
+// TransactionPool returns a copy of the pending transactions.
 func (bc *Blockchain) TransactionPool() []*Transaction {
-	return bc.transactionPool
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return slices.Clone(bc.transactionPool)
 }
 
 func (bc *Blockchain) ClearTransactionPool() {
-	bc.transactionPool = bc.transactionPool[:0]
+	bc.mux.Lock()
+	removed := bc.transactionPool
+	bc.transactionPool = []*Transaction{}
+	height := bc.height()
+	bc.mux.Unlock()
+
+	if len(removed) > 0 {
+		bc.events.Publish(&Event{Type: EVENT_MEMPOOL_REMOVE, Height: height, Transactions: removed})
+	}
+}
+
+func (bc *Blockchain) Subscribe() (<-chan *Event, func()) {
+	return bc.events.Subscribe()
 }
 
 func (bc *Blockchain) MarshalJSON() ([]byte, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
 	return json.Marshal(struct {
 		Blocks []*Block `json:"blocks"`
 	}{
@@ -103,9 +366,9 @@ func (bc *Blockchain) MarshalJSON() ([]byte, error) {
 func (bc *Blockchain) UnmarshalJSON(data []byte) error {
 
 	v := &struct {
-		Blocks []*Block `json:"chain"`
+		Blocks *[]*Block `json:"blocks"`
 	}{
-		Blocks: bc.chain,
+		Blocks: &bc.chain,
 	}
 
 	if err := json.Unmarshal(data, &v); err != nil {
@@ -115,11 +378,31 @@ func (bc *Blockchain) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func decodeBlocks(raw []json.RawMessage) ([]*Block, error) {
+	blocks := make([]*Block, 0, len(raw))
+	for _, r := range raw {
+		b := new(Block)
+		if err := json.Unmarshal(r, b); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+func (bc *Blockchain) peerClient(peer string) *client.Client {
+	return client.New(peer+bc.peerPath,
+		client.WithTLS(bc.params.PeerTLS),
+		client.WithTimeout(bc.params.PeerTimeout),
+		client.WithToken(bc.params.PeerToken),
+		client.WithIdentity(bc.identity))
+}
+
 func (bc *Blockchain) Print() {
 	fmt.Println("--------------")
 	fmt.Println("| Blockchain |")
 	fmt.Println("--------------")
-	for i, block := range bc.chain {
+	for i, block := range bc.Chain() {
 		fmt.Println()
 		fmt.Printf("%s Block %d %s\n", strings.Repeat("=", 15), i, strings.Repeat("=", 65))
 		block.Print()
@@ -128,69 +411,116 @@ func (bc *Blockchain) Print() {
 	fmt.Printf("%s\n", strings.Repeat("*", 89))
 }
 
-func (bc *Blockchain) CreateTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey, sig *utils.Signature) bool {
+func (bc *Blockchain) CreateTransaction(sender string, recipient string, value float32, senderPublicKey utils.PublicKey, sig *utils.Signature) bool {
+	if senderPublicKey == nil || sig == nil {
+		return false
+	}
+	return bc.CreateWitnessedTransaction(sender, recipient, value, &Witness{PublicKey: senderPublicKey.String(), Signature: sig.String()})
+}
 
-	isTransacted := bc.AddTransaction(sender, recipient, value, senderPublicKey, sig)
+// CreateWitnessedTransaction adds a transaction to the pool and relays it
+// to peers.
+func (bc *Blockchain) CreateWitnessedTransaction(sender string, recipient string, value float32, witness *Witness) bool {
+
+	isTransacted := bc.AddWitnessedTransaction(sender, recipient, value, witness)
 
 	if isTransacted {
-		for _, n := range bc.peers {
 
-			pubKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(), senderPublicKey.Y.Bytes())
-			sigStr := sig.String()
+		txn := NewWitnessedTransaction(sender, recipient, value, witness)
+		bt := txn.Request()
 
-			bt := &TransactionRequest{&sender, &recipient, &pubKeyStr, &sigStr, &value}
+		for _, p := range bc.Peers() {
+			if err := bc.peerClient(p).RelayTransaction(context.Background(), bt); err != nil {
+				bc.logger().Warn("relay transaction failed", "peer", p, "tx", txn.id(), "error", err)
+				bc.metrics.peerFailure(bc.id, p, "relay_transaction")
+			}
+		}
+	}
 
-			m, _ := json.Marshal(bt)
-			buf := bytes.NewBuffer(m)
+	return isTransacted
+}
 
-			endpoint := fmt.Sprintf("http://%s/transactions", n)
-			client := &http.Client{}
+// AddTransaction adds a signed transaction to the pool. A MINING_SENDER
+// reward is added unchecked and is for local use only: peers reject a
+// block unless it pays at most one reward, of params.Reward.
+func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32, senderPublicKey utils.PublicKey, sig *utils.Signature) bool {
 
-			req, _ := http.NewRequest("PUT", endpoint, buf)
-			resp, _ := client.Do(req)
+	if sender == MINING_SENDER {
+		bc.mux.Lock()
+		bc.addToPool(NewTransaction(sender, recipient, value))
+		bc.mux.Unlock()
+		return true
+	}
 
-			log.Printf("%+v", resp)
-		}
+	if senderPublicKey == nil || sig == nil {
+		return false
 	}
 
-	return isTransacted
+	return bc.AddWitnessedTransaction(sender, recipient, value, &Witness{PublicKey: senderPublicKey.String(), Signature: sig.String()})
 }
 
-func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey, sig *utils.Signature) bool {
+// AddWitnessedTransaction adds a transaction authorized by a single-key or
+// multisig witness to the pool.
+func (bc *Blockchain) AddWitnessedTransaction(sender string, recipient string, value float32, witness *Witness) bool {
 
 	txn := NewTransaction(sender, recipient, value)
+	txn.witness = witness
 
+	// Rewards are paid only by Mining, never relayed.
+	err := verifyWitness(txn)
 	if sender == MINING_SENDER {
-		bc.transactionPool = append(bc.transactionPool, txn)
-		return true
+		err = ErrInvalidReward
+	}
+	if err != nil {
+		bc.logger().Info("transaction rejected", "tx", txn.id(), "sender", sender, "error", err)
+		bc.RejectTransaction(err)
+		return false
 	}
 
-	if bc.VerifyTransactionSignature(senderPublicKey, sig, txn) {
-
-		// if bc.CalculateTotalAmount(sender) < value {
-		// 	log.Println("insufficient funds")
-		// 	return false
-		// }
+	// if bc.CalculateTotalAmount(sender) < value {
+	// 	bc.logger().Info("insufficient funds")
+	// 	return false
+	// }
 
-		bc.transactionPool = append(bc.transactionPool, txn)
-		return true
-	}
+	bc.mux.Lock()
+	bc.addToPool(txn)
+	bc.mux.Unlock()
+	bc.logger().Debug("transaction added", "tx", txn.id(), "sender", sender, "recipient", recipient, "value", value)
+	return true
+}
 
-	// fmt.Println("failed to verify transaction signature")
+// RejectTransaction counts a transaction refused before it reached the
+// pool, such as a request with a malformed key.
+func (bc *Blockchain) RejectTransaction(err error) {
+	bc.metrics.rejectTransaction(bc.id, err)
+}
 
-	return false
+// addToPool appends txn to the pool. The caller holds mux.
+func (bc *Blockchain) addToPool(txn *Transaction) {
+	bc.transactionPool = append(bc.transactionPool, txn)
+	bc.events.Publish(&Event{Type: EVENT_MEMPOOL_ADD, Height: len(bc.chain) - 1, Transactions: []*Transaction{txn}})
 }
 
-func (bc *Blockchain) VerifyTransactionSignature(senderPublicKey *ecdsa.PublicKey, sig *utils.Signature, txn *Transaction) bool {
-	m, _ := json.Marshal(txn)
-	hash := sha256.Sum256([]byte(m))
-	return ecdsa.Verify(senderPublicKey, hash[:], sig.R, sig.S)
+func (bc *Blockchain) VerifyTransactionSignature(senderPublicKey utils.PublicKey, sig *utils.Signature, txn *Transaction) bool {
+	if senderPublicKey == nil || sig == nil || sig.Algorithm != senderPublicKey.Algorithm() {
+		return false
+	}
+	hash := txn.signingHash()
+	return senderPublicKey.Verify(hash[:], sig)
 }
 
 func (bc *Blockchain) CopyTransactionPool() []*Transaction {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.copyTransactionPool()
+}
+
+// copyTransactionPool is CopyTransactionPool for a caller holding mux.
+func (bc *Blockchain) copyTransactionPool() []*Transaction {
 	transactions := make([]*Transaction, 0)
 	for _, t := range bc.transactionPool {
 		newTx := NewTransaction(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value)
+		newTx.witness = t.witness
 		transactions = append(transactions, newTx)
 	}
 	return transactions
@@ -212,77 +542,208 @@ func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions
 }
 
 func (bc *Blockchain) ProofOfWork() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.proofOfWork()
+}
+
+// proofOfWork is ProofOfWork for a caller holding mux.
+func (bc *Blockchain) proofOfWork() int {
 
-	transactions := bc.CopyTransactionPool()
-	previousHash := bc.LastBlock().Hash()
+	transactions := bc.copyTransactionPool()
+	previousHash := bc.lastBlock().Hash()
 
+	start := time.Now()
 	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MINING_DIFFICULTY) {
+	for !bc.ValidProof(nonce, previousHash, transactions, bc.params.Difficulty) {
 		nonce += 1
 	}
+	bc.metrics.observeProofOfWork(bc.id, nonce+1, time.Since(start))
 
 	return nonce
 }
 
+// CreateBlock appends a block of the pool and has peers clear their
+// pools.
 func (bc *Blockchain) CreateBlock(nonce int, previousHash [32]byte) *Block {
 
+	bc.mux.Lock()
+	b := bc.createBlock(nonce, previousHash)
+	bc.mux.Unlock()
+
+	bc.clearPeerPools()
+	return b
+}
+
+// createBlock appends a block of the pool. The caller holds mux.
+func (bc *Blockchain) createBlock(nonce int, previousHash [32]byte) *Block {
+
 	b := NewBlock(nonce, previousHash, bc.transactionPool)
+	if len(bc.chain) > 0 {
+		bc.metrics.observeBlock(bc.id, bc.lastBlock(), b)
+	}
 	bc.chain = append(bc.chain, b)
 	bc.transactionPool = []*Transaction{}
 
-	for _, p := range bc.peers {
-		endpoint := fmt.Sprintf("http://%s/transactions", p)
-		client := &http.Client{}
+	height := len(bc.chain) - 1
+	bc.events.Publish(&Event{Type: EVENT_NEW_BLOCK, Height: height, Block: b})
+	if len(b.transactions) > 0 {
+		bc.events.Publish(&Event{Type: EVENT_MEMPOOL_REMOVE, Height: height, Transactions: b.transactions})
+	}
+
+	return b
+}
+
+// clearPeerPools asks every peer to drop its pool after a block is mined.
+func (bc *Blockchain) clearPeerPools() {
+	for _, p := range bc.Peers() {
+		if err := bc.peerClient(p).ClearTransactions(context.Background()); err != nil {
+			bc.logger().Warn("clear transactions failed", "peer", p, "error", err)
+			bc.metrics.peerFailure(bc.id, p, "clear_transactions")
+		}
+	}
+}
 
-		req, _ := http.NewRequest("DELETE", endpoint, nil)
-		resp, _ := client.Do(req)
+// AddBlock appends a block announced by a peer if it extends the local
+// tip with a valid proof, and drops its transactions from the pool.
+func (bc *Blockchain) AddBlock(b *Block) bool {
 
-		log.Printf("%+v", resp)
+	if err := bc.VerifyBlock(b); err != nil {
+		bc.logger().Info("block rejected", "hash", fmt.Sprintf("%x", b.Hash()), "error", err)
+		return false
 	}
 
-	return b
+	return bc.AddVerifiedBlock(b)
+}
+
+// AddVerifiedBlock is AddBlock for a block the caller has already checked
+// with VerifyBlock.
+func (bc *Blockchain) AddVerifiedBlock(b *Block) bool {
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if b.previousHash != bc.lastBlock().Hash() {
+		return false
+	}
+
+	bc.metrics.observeBlock(bc.id, bc.lastBlock(), b)
+	bc.chain = append(bc.chain, b)
+
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	removed := make([]*Transaction, 0)
+
+	for _, t := range bc.transactionPool {
+		if b.contains(t) {
+			removed = append(removed, t)
+		} else {
+			pool = append(pool, t)
+		}
+	}
+	bc.transactionPool = pool
+
+	height := len(bc.chain) - 1
+	bc.events.Publish(&Event{Type: EVENT_NEW_BLOCK, Height: height, Block: b})
+	if len(removed) > 0 {
+		bc.events.Publish(&Event{Type: EVENT_MEMPOOL_REMOVE, Height: height, Transactions: removed})
+	}
+	bc.logger().Info("block added", "height", height, "hash", fmt.Sprintf("%x", b.Hash()), "transactions", len(b.transactions))
+
+	return true
+}
+
+// VerifyBlock checks the proof of work and transaction witnesses of b,
+// which hold wherever b sits. A block failing them was forged or
+// corrupted, unlike one that merely does not extend the tip.
+func (bc *Blockchain) VerifyBlock(b *Block) error {
+
+	if !bc.ValidProof(b.nonce, b.previousHash, b.transactions, bc.params.Difficulty) {
+		return ErrInvalidProof
+	}
+
+	if err := bc.verifyReward(b); err != nil {
+		return err
+	}
+
+	for _, t := range b.transactions {
+		if err := verifyWitness(t); err != nil {
+			return fmt.Errorf("transaction %s: %w", t.id(), err)
+		}
+	}
+
+	return nil
 }
 
 func (bc *Blockchain) LastBlock() *Block {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.lastBlock()
+}
+
+// lastBlock is LastBlock for a caller holding mux.
+func (bc *Blockchain) lastBlock() *Block {
 	return bc.chain[len(bc.chain)-1]
 }
 
+// Mining mines the pool into a block while holding mux, then tells peers
+// once it is released, as they answer by fetching this node's chain.
 func (bc *Blockchain) Mining() bool {
 
 	bc.mux.Lock()
-	defer bc.mux.Unlock()
+
+	if bc.blockchainAddress == "" {
+		bc.mux.Unlock()
+		bc.logger().Warn("mining skipped", "reason", "no miner address")
+		return false
+	}
 
 	if len(bc.transactionPool) == 0 {
-		fmt.Println("\nNo transactions - Mining skipped")
+		bc.mux.Unlock()
+		bc.logger().Debug("mining skipped", "reason", "no transactions")
 		return false
 	}
 
-	fmt.Println("\nMining NOW!")
+	bc.logger().Debug("mining", "height", len(bc.chain), "transactions", len(bc.transactionPool))
+
+	bc.addToPool(NewTransaction(MINING_SENDER, bc.blockchainAddress, bc.params.Reward))
+	nonce := bc.proofOfWork()
+	b := bc.createBlock(nonce, bc.lastBlock().Hash())
+	height := bc.height()
+	bc.mux.Unlock()
 
-	bc.AddTransaction(MINING_SENDER, bc.blockchainAddress, MINING_REWARD, nil, nil)
-	nonce := bc.ProofOfWork()
-	previousHash := bc.LastBlock().Hash()
-	bc.CreateBlock(nonce, previousHash)
-	log.Println("action=mining, status=success")
+	bc.logger().Info("block mined", "height", height, "hash", fmt.Sprintf("%x", b.Hash()), "nonce", nonce, "transactions", len(b.transactions))
+	bc.clearPeerPools()
 
-	for _, p := range bc.peers {
-		endpoint := fmt.Sprintf("http://%s/consensus", p)
-		client := &http.Client{}
-		req, _ := http.NewRequest("PUT", endpoint, nil)
-		resp, _ := client.Do(req)
-		log.Printf("%+v", resp)
+	for _, p := range bc.Peers() {
+		if _, err := bc.peerClient(p).Consensus(context.Background()); err != nil {
+			bc.logger().Warn("consensus request failed", "peer", p, "error", err)
+			bc.metrics.peerFailure(bc.id, p, "consensus")
+		}
 	}
 
 	return true
 }
 
+// StartMining mines a block every mining interval until StopMining
+// is called. Starting a running miner does nothing.
 func (bc *Blockchain) StartMining() {
-	bc.Mining()
-	_ = time.AfterFunc(time.Second*MINING_TIMER, bc.StartMining)
+	bc.miner.start(0, bc.params.MiningInterval, func() { bc.Mining() })
+}
+
+// StopMining stops the mining loop, waiting for a block being mined.
+func (bc *Blockchain) StopMining() {
+	bc.miner.stop()
+}
+
+func (bc *Blockchain) IsMining() bool {
+	return bc.miner.running()
 }
 
 func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
 
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
 	var totalAmount float32 = 0.0
 
 	for _, b := range bc.chain {
@@ -301,8 +762,15 @@ func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
 	return totalAmount
 }
 
+// ValidChain checks that chain starts at the genesis block and that every
+// later block links to the one before, has a valid proof and reward, and
+// only signed transfers.
 func (bc *Blockchain) ValidChain(chain []*Block) bool {
 
+	if len(chain) == 0 || chain[0].Hash() != genesisBlock().Hash() {
+		return false
+	}
+
 	preBlock := chain[0]
 	currentIndex := 1
 
@@ -314,7 +782,16 @@ func (bc *Blockchain) ValidChain(chain []*Block) bool {
 			return false
 		}
 
-		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transactions(), MINING_DIFFICULTY) {
+		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transactions(), bc.params.Difficulty) {
+			return false
+		}
+
+		if err := bc.verifyReward(b); err != nil {
+			slog.Info("invalid reward in block", "hash", fmt.Sprintf("%x", b.Hash()), "error", err)
+			return false
+		}
+
+		if !validTransactions(b) {
 			return false
 		}
 
@@ -325,39 +802,127 @@ func (bc *Blockchain) ValidChain(chain []*Block) bool {
 	return true
 }
 
+// verifyReward checks that b pays at most one mining reward, of
+// params.Reward.
+func (bc *Blockchain) verifyReward(b *Block) error {
+
+	rewards := 0
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress != MINING_SENDER {
+			continue
+		}
+		if rewards++; rewards > 1 {
+			return fmt.Errorf("%w: more than one in block", ErrInvalidReward)
+		}
+		if t.value != bc.params.Reward {
+			return fmt.Errorf("%w: %v, want %v", ErrInvalidReward, t.value, bc.params.Reward)
+		}
+	}
+
+	return nil
+}
+
+// validTransactions checks the witness of every transaction in b.
+func validTransactions(b *Block) bool {
+	for _, t := range b.transactions {
+		if err := verifyWitness(t); err != nil {
+			slog.Info("invalid transaction in block", "tx", t.id(), "sender", t.senderBlockchainAddress, "error", err)
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveConflicts replaces the chain with the longest valid chain of a
+// peer. A peer serving a longer but invalid chain is banned. It returns
+// false at once if another call is already running.
 func (bc *Blockchain) ResolveConflicts() bool {
 
-	var longestChain []*Block = nil
-	maxLength := len(bc.chain)
+	if !bc.muxResolve.TryLock() {
+		bc.logger().Debug("resolving conflicts already in progress")
+		return false
+	}
+	defer bc.muxResolve.Unlock()
+
+	return bc.resolveConflicts()
+}
 
-	fmt.Println("\nResolving conflicts...")
+// ResolveConflictsInBackground runs ResolveConflicts in a goroutine unless
+// a call is already running, so repeated triggers start at most one.
+func (bc *Blockchain) ResolveConflictsInBackground() bool {
 
-	for _, p := range bc.peers {
+	if !bc.muxResolve.TryLock() {
+		return false
+	}
 
-		endpoint := fmt.Sprintf("http://%s/chain", p)
-		resp, _ := http.Get(endpoint)
+	go func() {
+		defer bc.muxResolve.Unlock()
+		bc.resolveConflicts()
+	}()
 
-		if resp.StatusCode == 200 {
-			var bcResp Blockchain
-			decoder := json.NewDecoder(resp.Body)
-			_ = decoder.Decode(&bcResp)
+	return true
+}
 
-			chain := bcResp.Chain()
+// resolveConflicts is ResolveConflicts for a caller holding muxResolve.
+// Chains are fetched without mux, and the longest replaces the local one
+// under it only if it is still longer.
+func (bc *Blockchain) resolveConflicts() bool {
 
-			if len(chain) > maxLength && bc.ValidChain(chain) {
-				maxLength = len(chain)
-				longestChain = chain
-			}
+	var longestChain []*Block = nil
+	maxLength := bc.Height() + 1
+
+	peers := bc.Peers()
+	bc.logger().Debug("resolving conflicts", "height", bc.Height(), "peers", len(peers))
+
+	for _, p := range peers {
+
+		cr, err := bc.peerClient(p).Chain(context.Background())
+		if err != nil {
+			bc.logger().Warn("fetch chain failed", "peer", p, "error", err)
+			bc.metrics.peerFailure(bc.id, p, "fetch_chain")
+			continue
+		}
+
+		chain, err := decodeBlocks(cr.Blocks)
+		if err != nil {
+			bc.logger().Warn("decode chain failed", "peer", p, "error", err)
+			bc.metrics.peerFailure(bc.id, p, "fetch_chain")
+			bc.misbehaved(p, SCORE_INVALID_CHAIN, "malformed chain")
+			continue
+		}
+
+		// A chain no longer than one already held cannot raise the lag;
+		// a longer one counts only once it validates.
+		if len(chain) <= maxLength {
+			bc.ObservePeerHeight(len(chain) - 1)
+			continue
 		}
+		if !bc.ValidChain(chain) {
+			bc.logger().Warn("invalid chain from peer", "peer", p, "height", len(chain)-1)
+			bc.misbehaved(p, SCORE_INVALID_CHAIN, "invalid chain")
+			continue
+		}
+		bc.ObservePeerHeight(len(chain) - 1)
+		maxLength = len(chain)
+		longestChain = chain
 	}
 
 	if longestChain != nil {
-		bc.chain = longestChain
-		log.Printf("Resovle confilicts replaced")
-		return true
+		bc.mux.Lock()
+		replaced := len(longestChain) > len(bc.chain)
+		if replaced {
+			bc.chain = longestChain
+		}
+		bc.mux.Unlock()
+
+		if replaced {
+			bc.events.Publish(&Event{Type: EVENT_REORG, Height: len(longestChain) - 1, Block: longestChain[len(longestChain)-1]})
+			bc.logger().Info("chain replaced", "height", len(longestChain)-1)
+			return true
+		}
 	}
 
-	log.Printf("Resovle conflicts not replaced")
+	bc.logger().Debug("chain kept", "height", bc.Height())
 	return false
 }
 
@@ -367,10 +932,57 @@ type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	witness                    *Witness
 }
 
 func NewTransaction(sender string, recipient string, value float32) *Transaction {
-	return &Transaction{sender, recipient, value}
+	return &Transaction{senderBlockchainAddress: sender, recipientBlockchainAddress: recipient, value: value}
+}
+
+// NewWitnessedTransaction restores a signed transaction, such as one
+// received inside a block from a peer.
+func NewWitnessedTransaction(sender string, recipient string, value float32, witness *Witness) *Transaction {
+	t := NewTransaction(sender, recipient, value)
+	t.witness = witness
+	return t
+}
+
+func (t *Transaction) SenderBlockchainAddress() string {
+	return t.senderBlockchainAddress
+}
+
+func (t *Transaction) RecipientBlockchainAddress() string {
+	return t.recipientBlockchainAddress
+}
+
+func (t *Transaction) Value() float32 {
+	return t.value
+}
+
+func (t *Transaction) Witness() *Witness {
+	return t.witness
+}
+
+// Hash identifies a transaction by the SHA-256 of its JSON, witness
+// included.
+func (t *Transaction) Hash() [32]byte {
+	m, _ := json.Marshal(t)
+	return sha256.Sum256(m)
+}
+
+// id is the hex hash a transaction is looked up by, also logged as "tx".
+func (t *Transaction) id() string {
+	return fmt.Sprintf("%x", t.Hash())
+}
+
+// LogValue logs the transfer without its witness.
+func (t *Transaction) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("tx", t.id()),
+		slog.String("sender", t.senderBlockchainAddress),
+		slog.String("recipient", t.recipientBlockchainAddress),
+		slog.Float64("value", float64(t.value)),
+	)
 }
 
 func (t *Transaction) Print() {
@@ -382,26 +994,30 @@ func (t *Transaction) Print() {
 
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender    string   `json:"sender_blockchain_address"`
+		Recipient string   `json:"recipient_blockchain_address"`
+		Value     float32  `json:"value"`
+		Witness   *Witness `json:"witness,omitempty"`
 	}{
 		Sender:    t.senderBlockchainAddress,
 		Recipient: t.recipientBlockchainAddress,
 		Value:     t.value,
+		Witness:   t.witness,
 	})
 }
 
 func (t *Transaction) UnmarshalJSON(data []byte) error {
 
 	v := &struct {
-		Sender    *string  `json:"sender_blockchain_address"`
-		Recipient *string  `json:"recipient_blockchain_address"`
-		Value     *float32 `json:"value"`
+		Sender    *string   `json:"sender_blockchain_address"`
+		Recipient *string   `json:"recipient_blockchain_address"`
+		Value     *float32  `json:"value"`
+		Witness   **Witness `json:"witness"`
 	}{
 		Sender:    &t.senderBlockchainAddress,
 		Recipient: &t.recipientBlockchainAddress,
 		Value:     &t.value,
+		Witness:   &t.witness,
 	}
 
 	if err := json.Unmarshal(data, &v); err != nil {
@@ -410,70 +1026,3 @@ func (t *Transaction) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
-
-// -------------------------------------------------------------------------
-
-type TransactionRequest struct {
-	SenderBlockchainAddress    *string  `json:"sender_blockchain_address"`
-	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
-	SenderPublicKey            *string  `json:"sender_public_key"`
-	Signature                  *string  `json:"signature"`
-	Value                      *float32 `json:"value"`
-}
-
-// func (t *TransactionRequest) Print() {
-// 	fmt.Printf("\n	%s", strings.Repeat("-", 55))
-// 	fmt.Printf("\n	> SenderBlockchainAddress: %s", *t.SenderBlockchainAddress)
-// 	fmt.Printf("\n	> RecipientBlockchainAddress: %s", *t.RecipientBlockchainAddress)
-// 	fmt.Printf("\n	> SenderPublicKey: %s", *t.SenderPublicKey)
-// 	fmt.Printf("\n	> Signature: %s", *t.Signature)
-// 	// fmt.Printf("\n	> value: %.1f", t.Value)
-// }
-
-func (tr *TransactionRequest) Validate() bool {
-
-	if tr.SenderBlockchainAddress == nil ||
-		tr.RecipientBlockchainAddress == nil ||
-		tr.SenderPublicKey == nil ||
-		tr.Signature == nil ||
-		tr.Value == nil {
-		return false
-	}
-
-	// if tr.Value == nil {
-	// 	log.Println("Value MISSING")
-	// 	return false
-	// }
-	// if tr.SenderBlockchainAddress == nil {
-	// 	log.Println("SenderBlockchainAddress MISSING")
-	// 	return false
-	// }
-	// if tr.RecipientBlockchainAddress == nil {
-	// 	log.Println("RecipientBlockchainAddress MISSING")
-	// 	return false
-	// }
-	// if tr.SenderPublicKey == nil {
-	// 	log.Println("SenderPublicKey MISSING")
-	// 	return false
-	// }
-	// if tr.Signature == nil {
-	// 	log.Println("Signature MISSING")
-	// 	return false
-	// }
-
-	return true
-}
-
-// -------------------------------------------------------------------------
-
-type AmountResponse struct {
-	Amount float32 `json:"amount"`
-}
-
-func (ar *AmountResponse) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Amount float32 `json:"amount"`
-	}{
-		Amount: ar.Amount,
-	})
-}
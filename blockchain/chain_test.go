@@ -1 +1,323 @@
 package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// addTransfer adds a transfer of value to recipient, signed by a new
+// wallet, to the pool of bc.
+func addTransfer(t *testing.T, bc *Blockchain, recipient string, value float32) {
+	sender := wallet.NewWallet()
+	txn := wallet.NewWalletTransaction(sender.PrivateKey(), sender.PublicKey(), sender.BlockchainAddress(), recipient, value)
+	assert.True(t, bc.AddTransaction(sender.BlockchainAddress(), recipient, value, sender.PublicKey(), txn.GenerateSignature()))
+}
+
+func TestChainJSONRoundTrip(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	bc.AddTransaction(MINING_SENDER, "alice", MINING_REWARD, nil, nil)
+	bc.CreateBlock(bc.ProofOfWork(), bc.LastBlock().Hash())
+	//
+	m, err := json.Marshal(bc)
+	assert.NoError(t, err)
+	//
+	var decoded Blockchain
+	assert.NoError(t, json.Unmarshal(m, &decoded))
+	assert.Equal(t, len(bc.Chain()), len(decoded.Chain()))
+	assert.Equal(t, bc.LastBlock().Hash(), decoded.LastBlock().Hash())
+	assert.True(t, bc.ValidChain(decoded.Chain()))
+}
+
+func TestAddTransactionAlgorithms(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	//
+	for _, alg := range []utils.Algorithm{utils.ALG_P256, utils.ALG_SECP256K1, utils.ALG_ED25519} {
+		privateKey, err := utils.GenerateKey(alg)
+		assert.NoError(t, err)
+		//
+		sender := wallet.AddressFromPublicKey(privateKey.Public())
+		recipient := wallet.NewWallet().BlockchainAddress()
+		//
+		m, _ := json.Marshal(NewTransaction(sender, recipient, 1))
+		hash := sha256.Sum256(m)
+		sig, err := privateKey.Sign(hash[:])
+		assert.NoError(t, err)
+		//
+		assert.True(t, bc.AddTransaction(sender, recipient, 1, privateKey.Public(), sig), alg)
+		assert.False(t, bc.AddTransaction(sender, recipient, 2, privateKey.Public(), sig), alg)
+	}
+	assert.Len(t, bc.TransactionPool(), 3)
+}
+
+func TestAddTransactionAddressChecks(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	sender := wallet.NewWallet()
+	other := wallet.NewWallet()
+	//
+	sign := func(from string, to string) *utils.Signature {
+		m, _ := json.Marshal(NewTransaction(from, to, 1))
+		hash := sha256.Sum256(m)
+		sig, _ := sender.PrivateKey().Sign(hash[:])
+		return sig
+	}
+	//
+	// A valid signature under someone else's address is not enough.
+	sig := sign(other.BlockchainAddress(), sender.BlockchainAddress())
+	assert.False(t, bc.AddTransaction(other.BlockchainAddress(), sender.BlockchainAddress(), 1, sender.PublicKey(), sig))
+	//
+	sig = sign(sender.BlockchainAddress(), "bob")
+	assert.False(t, bc.AddTransaction(sender.BlockchainAddress(), "bob", 1, sender.PublicKey(), sig))
+	//
+	assert.Empty(t, bc.TransactionPool())
+}
+
+func TestMultisigTransaction(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	a, b, c := wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()
+	ms, _ := wallet.NewMultisig(2, []utils.PublicKey{a.PublicKey(), b.PublicKey(), c.PublicKey()})
+	recipient := wallet.NewWallet().BlockchainAddress()
+	//
+	hash := wallet.TransactionHash(ms.Address(), recipient, 3)
+	witness := &Witness{Threshold: 2, PublicKeys: ms.PublicKeyStrs(), Signatures: make([]string, 3)}
+	cosign := func(w *wallet.Wallet) {
+		sig, _ := w.PrivateKey().Sign(hash[:])
+		for i, k := range witness.PublicKeys {
+			if k == w.PublicKeyStr() {
+				witness.Signatures[i] = sig.String()
+			}
+		}
+	}
+	//
+	cosign(a)
+	assert.False(t, bc.AddWitnessedTransaction(ms.Address(), recipient, 3, witness))
+	cosign(c)
+	assert.True(t, bc.AddWitnessedTransaction(ms.Address(), recipient, 3, witness))
+	assert.False(t, bc.AddWitnessedTransaction(ms.Address(), recipient, 4, witness))
+	//
+	bc.CreateBlock(bc.ProofOfWork(), bc.LastBlock().Hash())
+	assert.True(t, bc.ValidChain(bc.Chain()))
+	//
+	m, _ := json.Marshal(bc)
+	var decoded Blockchain
+	assert.NoError(t, json.Unmarshal(m, &decoded))
+	assert.True(t, bc.ValidChain(decoded.Chain()))
+	assert.Equal(t, witness, decoded.LastBlock().Transactions()[0].Witness())
+}
+
+func TestWitnessFromRequestCanonical(t *testing.T) {
+	//
+	a, b, c := wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()
+	ms, _ := wallet.NewMultisig(2, []utils.PublicKey{a.PublicKey(), b.PublicKey(), c.PublicKey()})
+	recipient := wallet.NewWallet().BlockchainAddress()
+	hash := wallet.TransactionHash(ms.Address(), recipient, 3)
+	signatures := make(map[string]string)
+	for _, w := range []*wallet.Wallet{a, b, c} {
+		sig, _ := w.PrivateKey().Sign(hash[:])
+		signatures[w.PublicKeyStr()] = sig.String()
+	}
+	request := func(keys []string, signed int) *client.TransactionRequest {
+		sender, threshold, value := ms.Address(), 2, float32(3)
+		tr := &client.TransactionRequest{SenderBlockchainAddress: &sender, RecipientBlockchainAddress: &recipient, Value: &value, Threshold: &threshold, PublicKeys: keys}
+		for i, k := range keys {
+			if i < signed {
+				tr.Signatures = append(tr.Signatures, signatures[k])
+			} else {
+				tr.Signatures = append(tr.Signatures, "")
+			}
+		}
+		return tr
+	}
+	sorted := ms.PublicKeyStrs()
+	//
+	want, err := WitnessFromRequest(request(sorted, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, sorted, want.PublicKeys)
+	//
+	// Reordering the keys or adding a signature beyond the threshold does
+	// not change the witness.
+	got, err := WitnessFromRequest(request([]string{sorted[2], sorted[1], sorted[0]}, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+	//
+	tr := request(sorted, 2)
+	tr.Signatures = tr.Signatures[:2]
+	_, err = WitnessFromRequest(tr)
+	assert.ErrorIs(t, err, wallet.ErrInvalidMultisig)
+}
+
+func TestValidChainRejectsUnsignedTransfer(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	sender := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	//
+	forged := NewTransaction(sender.BlockchainAddress(), recipient, 50)
+	b := NewBlock(0, bc.LastBlock().Hash(), []*Transaction{forged})
+	for !bc.ValidProof(b.nonce, b.previousHash, b.transactions, MINING_DIFFICULTY) {
+		b.nonce++
+	}
+	//
+	assert.False(t, bc.AddBlock(b))
+	assert.False(t, bc.ValidChain(append(bc.Chain(), b)))
+}
+
+func TestVerifyBlockRewards(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	miner := wallet.NewWallet().BlockchainAddress()
+	mine := func(transactions ...*Transaction) *Block {
+		b := NewBlock(0, bc.LastBlock().Hash(), transactions)
+		for !bc.ValidProof(b.nonce, b.previousHash, b.transactions, MINING_DIFFICULTY) {
+			b.nonce++
+		}
+		return b
+	}
+	//
+	b := mine(NewTransaction(MINING_SENDER, miner, MINING_REWARD+1))
+	assert.ErrorIs(t, bc.VerifyBlock(b), ErrInvalidReward)
+	assert.False(t, bc.ValidChain(append(bc.Chain(), b)))
+	//
+	b = mine(NewTransaction(MINING_SENDER, miner, MINING_REWARD), NewTransaction(MINING_SENDER, miner, MINING_REWARD))
+	assert.ErrorIs(t, bc.VerifyBlock(b), ErrInvalidReward)
+	assert.False(t, bc.ValidChain(append(bc.Chain(), b)))
+	//
+	b = mine(NewTransaction(MINING_SENDER, miner, MINING_REWARD))
+	assert.NoError(t, bc.VerifyBlock(b))
+	assert.True(t, bc.ValidChain(append(bc.Chain(), b)))
+	assert.True(t, bc.AddBlock(b))
+	//
+	// Rewards are never relayed into the pool.
+	assert.False(t, bc.AddWitnessedTransaction(MINING_SENDER, miner, MINING_REWARD, nil))
+	assert.Empty(t, bc.TransactionPool())
+}
+
+func TestValidChainRejectsForeignGenesis(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	other := NewBlockchain("miner", 5000)
+	assert.Equal(t, bc.LastBlock().Hash(), other.LastBlock().Hash())
+	assert.True(t, bc.ValidChain(other.Chain()))
+	//
+	foreign := []*Block{NewBlock(0, (&Block{}).Hash(), []*Transaction{})}
+	assert.False(t, bc.ValidChain(foreign))
+	assert.False(t, bc.ValidChain(nil))
+}
+
+func TestMetricsWhileMining(t *testing.T) {
+	//
+	metrics := NewMetrics()
+	params := DefaultParams()
+	params.Difficulty = 1
+	bc := NewBlockchain(wallet.NewWallet().BlockchainAddress(), 5000, WithParams(params), WithMetrics(metrics))
+	recipient := wallet.NewWallet().BlockchainAddress()
+	//
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			bc.AddTransaction(MINING_SENDER, recipient, 1, nil, nil)
+			bc.Mining()
+		}
+	}()
+	//
+	for range 20 {
+		ch := make(chan prometheus.Metric, 1000)
+		metrics.Collect(ch)
+		close(ch)
+		assert.NotEmpty(t, bc.Chain())
+		assert.GreaterOrEqual(t, len(bc.TransactionPool()), 0)
+	}
+	wg.Wait()
+	assert.Equal(t, 20, bc.Height())
+}
+
+func TestPeersChangeWhileMining(t *testing.T) {
+	//
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"message":"success"}`))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	peers := []string{"127.0.0.1:" + port, "localhost:" + port}
+	//
+	params := DefaultParams()
+	params.Difficulty = 1
+	bc := NewBlockchain(wallet.NewWallet().BlockchainAddress(), 5000, WithParams(params))
+	//
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			bc.AddPeer(peers[i%2])
+			bc.RemovePeer(peers[(i+1)%2])
+		}
+	}()
+	//
+	for range 10 {
+		bc.AddTransaction(MINING_SENDER, wallet.NewWallet().BlockchainAddress(), 1, nil, nil)
+		assert.True(t, bc.Mining())
+	}
+	<-done
+}
+
+func TestResolveConflictsWhileMining(t *testing.T) {
+	//
+	params := DefaultParams()
+	params.Difficulty = 1
+	source := NewBlockchain(wallet.NewWallet().BlockchainAddress(), 5000, WithParams(params))
+	for range 3 {
+		addTransfer(t, source, wallet.NewWallet().BlockchainAddress(), 1)
+		source.Mining()
+	}
+	//
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/chain" {
+			return
+		}
+		<-release
+		m, _ := source.MarshalJSON()
+		w.Write(m)
+	}))
+	defer srv.Close()
+	//
+	params.Peers = []string{srv.Listener.Addr().String()}
+	bc := NewBlockchain(wallet.NewWallet().BlockchainAddress(), 5000, WithParams(params))
+	replaced := make(chan struct{})
+	events, cancel := bc.Subscribe()
+	defer cancel()
+	go func() {
+		for e := range events {
+			if e.Type == EVENT_REORG {
+				close(replaced)
+				return
+			}
+		}
+	}()
+	//
+	assert.True(t, bc.ResolveConflictsInBackground())
+	assert.False(t, bc.ResolveConflictsInBackground())
+	assert.False(t, bc.ResolveConflicts())
+	bc.AddTransaction(MINING_SENDER, wallet.NewWallet().BlockchainAddress(), 1, nil, nil)
+	assert.True(t, bc.Mining())
+	close(release)
+	//
+	<-replaced
+	assert.Equal(t, 3, bc.Height())
+}
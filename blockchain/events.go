@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"sync"
+)
+
+type EventType string
+
+const (
+	EVENT_NEW_BLOCK      EventType = "new_block"
+	EVENT_REORG          EventType = "reorg"
+	EVENT_MEMPOOL_ADD    EventType = "mempool_add"
+	EVENT_MEMPOOL_REMOVE EventType = "mempool_remove"
+
+	EVENT_BUFFER = 64
+)
+
+type Event struct {
+	Type         EventType      `json:"type"`
+	Height       int            `json:"height"`
+	Block        *Block         `json:"block,omitempty"`
+	Transactions []*Transaction `json:"transactions,omitempty"`
+}
+
+// Involves reports whether the event concerns the given address. Reorgs
+// can change any balance, so they involve every address.
+func (e *Event) Involves(address string) bool {
+
+	if e.Type == EVENT_REORG {
+		return true
+	}
+
+	transactions := e.Transactions
+	if e.Block != nil {
+		transactions = e.Block.transactions
+	}
+
+	for _, t := range transactions {
+		if t.senderBlockchainAddress == address || t.recipientBlockchainAddress == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ------------------------------------------------------------------
+
+// EventBus fans events out to subscribers. Publishing never blocks: a
+// subscriber that falls EVENT_BUFFER events behind misses events.
+type EventBus struct {
+	mux  sync.Mutex
+	subs map[chan *Event]struct{}
+}
+
+func (eb *EventBus) Subscribe() (<-chan *Event, func()) {
+
+	ch := make(chan *Event, EVENT_BUFFER)
+
+	eb.mux.Lock()
+	if eb.subs == nil {
+		eb.subs = make(map[chan *Event]struct{})
+	}
+	eb.subs[ch] = struct{}{}
+	eb.mux.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			eb.mux.Lock()
+			delete(eb.subs, ch)
+			eb.mux.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
+
+func (eb *EventBus) Publish(e *Event) {
+	eb.mux.Lock()
+	defer eb.mux.Unlock()
+	for ch := range eb.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000)
+	events, cancel := bc.Subscribe()
+	defer cancel()
+	//
+	bc.AddTransaction(MINING_SENDER, "alice", 10, nil, nil)
+	e := <-events
+	assert.Equal(t, EVENT_MEMPOOL_ADD, e.Type)
+	assert.True(t, e.Involves("alice"))
+	assert.False(t, e.Involves("bob"))
+	//
+	bc.CreateBlock(0, bc.LastBlock().Hash())
+	e = <-events
+	assert.Equal(t, EVENT_NEW_BLOCK, e.Type)
+	assert.Equal(t, 1, e.Height)
+	assert.True(t, e.Involves("alice"))
+	e = <-events
+	assert.Equal(t, EVENT_MEMPOOL_REMOVE, e.Type)
+	assert.Len(t, e.Transactions, 1)
+}
+
+func TestEventBusCancel(t *testing.T) {
+	//
+	var eb EventBus
+	events, cancel := eb.Subscribe()
+	cancel()
+	cancel()
+	//
+	eb.Publish(&Event{Type: EVENT_REORG})
+	_, ok := <-events
+	assert.False(t, ok)
+}
@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+// Nodes shake hands before they peer: each proves its node ID by signing
+// a hello carrying its protocol version, chain and genesis block. Peers
+// this node calls are then scored by node ID as well as "host:port".
+
+// PROTOCOL_VERSION is sent in the handshake. Nodes only peer with nodes
+// of the same version.
+const PROTOCOL_VERSION = 1
+
+var (
+	ErrSelfPeer = errors.New("peer is this node")
+	ErrProtocol = errors.New("protocol version mismatch")
+	ErrGenesis  = errors.New("genesis block mismatch")
+)
+
+// WithIdentity signs the chain's requests to peers as id and has it shake
+// hands with peers it discovers. Without one, peers are not identified.
+func WithIdentity(id *utils.NodeIdentity) Option {
+	return func(bc *Blockchain) {
+		bc.identity = id
+	}
+}
+
+func (bc *Blockchain) Identity() *utils.NodeIdentity {
+	return bc.identity
+}
+
+// Hello is the unsigned hello of this chain answering nonce.
+func (bc *Blockchain) Hello(nonce string) *client.Hello {
+	return &client.Hello{
+		Version:     PROTOCOL_VERSION,
+		ChainID:     bc.id,
+		GenesisHash: bc.genesisHash(),
+		Height:      bc.Height(),
+		Nonce:       nonce,
+	}
+}
+
+func (bc *Blockchain) genesisHash() string {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return fmt.Sprintf("%x", bc.chain[0].Hash())
+}
+
+// CheckHello verifies the hello of a peer: signed by its node ID, from
+// another node, and for the same protocol version, chain and genesis
+// block.
+func (bc *Blockchain) CheckHello(h *client.Hello) error {
+
+	if err := h.Verify(); err != nil {
+		return err
+	}
+	if bc.identity != nil && h.NodeID == bc.identity.ID() {
+		return ErrSelfPeer
+	}
+	if h.Version != PROTOCOL_VERSION {
+		return fmt.Errorf("%w: %d, want %d", ErrProtocol, h.Version, PROTOCOL_VERSION)
+	}
+	if h.ChainID != bc.id {
+		return fmt.Errorf("chain %q, want %q", h.ChainID, bc.id)
+	}
+	if h.GenesisHash != bc.genesisHash() {
+		return ErrGenesis
+	}
+
+	return nil
+}
+
+// Handshake exchanges hellos with peer and records its node ID.
+func (bc *Blockchain) Handshake(peer string) (string, error) {
+
+	if bc.identity == nil {
+		return "", errors.New("no node identity")
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+
+	hello := bc.Hello(hex.EncodeToString(nonce))
+	if err := hello.Sign(bc.identity); err != nil {
+		return "", err
+	}
+
+	theirs, err := bc.peerClient(peer).Handshake(context.Background(), hello)
+	if err != nil {
+		return "", err
+	}
+	if theirs.Nonce != hello.Nonce {
+		return "", errors.New("hello does not answer the nonce sent")
+	}
+	if err := bc.CheckHello(theirs); err != nil {
+		return "", err
+	}
+
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	bc.peerIDs[peer] = theirs.NodeID
+
+	return theirs.NodeID, nil
+}
+
+// PeerID is the node ID of peer, or empty before a handshake.
+func (bc *Blockchain) PeerID(peer string) string {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	return bc.peerIDs[peer]
+}
+
+// PeerIDs maps the peers that completed a handshake to their node IDs.
+func (bc *Blockchain) PeerIDs() map[string]string {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+
+	ids := make(map[string]string, len(bc.peers))
+	for _, p := range bc.peers {
+		if id, ok := bc.peerIDs[p]; ok {
+			ids[p] = id
+		}
+	}
+	return ids
+}
+
+// HasPeerID reports whether a current peer completed a handshake as id.
+func (bc *Blockchain) HasPeerID(id string) bool {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+
+	for _, p := range bc.peers {
+		if bc.peerIDs[p] == id {
+			return true
+		}
+	}
+	return false
+}
+
+// identify shakes hands with the peers not yet identified. Discovered
+// peers that fail are dropped, as the port scan finds any listening
+// service; static peers are kept unless they turn out to be this node.
+func (bc *Blockchain) identify(peers []string) []string {
+
+	if bc.identity == nil {
+		return peers
+	}
+
+	bc.muxNeighbors.Lock()
+	static := make(map[string]bool, len(bc.staticPeers))
+	for p := range bc.staticPeers {
+		static[p] = true
+	}
+	bc.muxNeighbors.Unlock()
+
+	kept := make([]string, 0, len(peers))
+	for _, p := range peers {
+
+		if bc.PeerID(p) != "" {
+			kept = append(kept, p)
+			continue
+		}
+
+		_, err := bc.Handshake(p)
+		switch {
+		case err == nil:
+			kept = append(kept, p)
+		case static[p] && !errors.Is(err, ErrSelfPeer):
+			bc.logger().Warn("handshake failed", "peer", p, "error", err)
+			kept = append(kept, p)
+		default:
+			bc.logger().Debug("handshake failed", "peer", p, "error", err)
+		}
+	}
+
+	return kept
+}
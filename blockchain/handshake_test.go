@@ -0,0 +1,81 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helloServer answers handshakes as bc.
+func helloServer(t *testing.T, bc *Blockchain) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var theirs client.Hello
+		json.NewDecoder(req.Body).Decode(&theirs)
+		mine := bc.Hello(theirs.Nonce)
+		mine.Sign(bc.identity)
+		json.NewEncoder(w).Encode(mine)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String()
+}
+
+func newIdentity(t *testing.T) *utils.NodeIdentity {
+	id, err := utils.GenerateNodeIdentity()
+	require.NoError(t, err)
+	return id
+}
+
+func TestHandshake(t *testing.T) {
+	//
+	scores := NewPeerScores(BAN_SCORE, time.Hour)
+	bc := NewBlockchain("miner", 5000, WithIdentity(newIdentity(t)), WithPeerScores(scores))
+	peer := NewBlockchain("miner", 5001, WithIdentity(newIdentity(t)))
+	addr := helloServer(t, peer)
+	//
+	id, err := bc.Handshake(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, peer.identity.ID(), id)
+	assert.Equal(t, id, bc.PeerID(addr))
+	//
+	// Misbehavior is scored against the node ID as well as the address.
+	bc.misbehaved(addr, SCORE_INVALID_CHAIN, "invalid chain")
+	assert.True(t, scores.Banned(id))
+	assert.True(t, scores.Banned(addr))
+	//
+	_, err = bc.Handshake(helloServer(t, bc))
+	assert.ErrorIs(t, err, ErrSelfPeer)
+}
+
+func TestCheckHello(t *testing.T) {
+	//
+	bc := NewBlockchain("miner", 5000, WithIdentity(newIdentity(t)))
+	other := NewBlockchain("miner", 5001, WithIdentity(newIdentity(t)))
+	signed := func(h *client.Hello) *client.Hello {
+		require.NoError(t, h.Sign(other.identity))
+		return h
+	}
+	//
+	assert.NoError(t, bc.CheckHello(signed(other.Hello("n"))))
+	//
+	h := signed(other.Hello("n"))
+	h.Height = 7
+	assert.ErrorContains(t, bc.CheckHello(h), "invalid hello signature")
+	//
+	h = other.Hello("n")
+	h.Version = PROTOCOL_VERSION + 1
+	assert.ErrorIs(t, bc.CheckHello(signed(h)), ErrProtocol)
+	//
+	h = other.Hello("n")
+	h.ChainID = "test"
+	assert.ErrorContains(t, bc.CheckHello(signed(h)), `chain "test"`)
+	//
+	other.chain[0] = NewBlock(0, (&Block{}).Hash(), []*Transaction{})
+	assert.ErrorIs(t, bc.CheckHello(signed(other.Hello("n"))), ErrGenesis)
+}
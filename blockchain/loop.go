@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+)
+
+// loop runs a function repeatedly on a timer until stopped. It replaces
+// timers that reschedule themselves forever, so the miner and peer sync
+// can be switched off and shutdown can wait for a run in progress.
+type loop struct {
+	mux   sync.Mutex
+	timer *time.Timer
+
+	// busy is held while fn runs.
+	busy sync.Mutex
+}
+
+// start runs fn after delay and then every interval. Starting a running
+// loop does nothing.
+func (l *loop) start(delay time.Duration, interval time.Duration, fn func()) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.timer != nil {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		l.busy.Lock()
+		defer l.busy.Unlock()
+
+		// A stop, or a stop and restart, before this run ends this loop.
+		// timer is only read under mux, which start holds while setting it.
+		l.mux.Lock()
+		current := l.timer == timer
+		l.mux.Unlock()
+		if !current {
+			return
+		}
+
+		fn()
+
+		l.mux.Lock()
+		defer l.mux.Unlock()
+
+		if l.timer == timer {
+			timer.Reset(interval)
+		}
+	})
+	l.timer = timer
+}
+
+// stop cancels the loop and waits for a run in progress to finish.
+func (l *loop) stop() {
+	l.mux.Lock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.mux.Unlock()
+
+	l.busy.Lock()
+	defer l.busy.Unlock()
+}
+
+func (l *loop) running() bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.timer != nil
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects Prometheus metrics for every chain created with
+// WithMetrics, labelled by chain ID. Chain state such as the height is
+// read when scraped; events such as rejected transactions are counted as
+// they happen. A nil *Metrics records nothing.
+type Metrics struct {
+	mux    sync.Mutex
+	chains []*Blockchain
+
+	height       *prometheus.Desc
+	mempool      *prometheus.Desc
+	mempoolBytes *prometheus.Desc
+	peers        *prometheus.Desc
+	syncLag      *prometheus.Desc
+
+	blockInterval *prometheus.HistogramVec
+	hashrate      *prometheus.GaugeVec
+	rejected      *prometheus.CounterVec
+	peerFailures  *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+
+	chain := []string{"chain"}
+
+	return &Metrics{
+		height:       prometheus.NewDesc("blockchain_height", "Height of the chain tip.", chain, nil),
+		mempool:      prometheus.NewDesc("blockchain_mempool_transactions", "Transactions waiting in the pool.", chain, nil),
+		mempoolBytes: prometheus.NewDesc("blockchain_mempool_bytes", "JSON size of the transactions waiting in the pool.", chain, nil),
+		peers:        prometheus.NewDesc("blockchain_peers", "Known peers.", chain, nil),
+		syncLag:      prometheus.NewDesc("blockchain_sync_lag_blocks", "Blocks between the highest peer tip seen and the local tip.", chain, nil),
+
+		blockInterval: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "blockchain_block_interval_seconds",
+			Help:    "Time between the timestamps of consecutive blocks added to the chain.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		}, chain),
+		hashrate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "blockchain_hashrate",
+			Help: "Hashes per second of the last proof of work mined here.",
+		}, chain),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "blockchain_rejected_transactions_total",
+			Help: "Transactions refused by the pool, by reason.",
+		}, []string{"chain", "reason"}),
+		peerFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "blockchain_peer_request_failures_total",
+			Help: "Failed requests to peers, by peer and operation.",
+		}, []string{"chain", "peer", "operation"}),
+	}
+}
+
+// WithMetrics reports the chain's metrics to m.
+func WithMetrics(m *Metrics) Option {
+	return func(bc *Blockchain) {
+		bc.metrics = m
+	}
+}
+
+func (m *Metrics) add(bc *Blockchain) {
+	if m == nil {
+		return
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.chains = append(m.chains, bc)
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.height
+	ch <- m.mempool
+	ch <- m.mempoolBytes
+	ch <- m.peers
+	ch <- m.syncLag
+	m.blockInterval.Describe(ch)
+	m.hashrate.Describe(ch)
+	m.rejected.Describe(ch)
+	m.peerFailures.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+
+	m.mux.Lock()
+	chains := append([]*Blockchain{}, m.chains...)
+	m.mux.Unlock()
+
+	for _, bc := range chains {
+
+		pool, height := bc.TransactionPool(), bc.Height()
+		poolBytes := 0
+		for _, t := range pool {
+			b, _ := json.Marshal(t)
+			poolBytes += len(b)
+		}
+
+		ch <- prometheus.MustNewConstMetric(m.height, prometheus.GaugeValue, float64(height), bc.id)
+		ch <- prometheus.MustNewConstMetric(m.mempool, prometheus.GaugeValue, float64(len(pool)), bc.id)
+		ch <- prometheus.MustNewConstMetric(m.mempoolBytes, prometheus.GaugeValue, float64(poolBytes), bc.id)
+		ch <- prometheus.MustNewConstMetric(m.peers, prometheus.GaugeValue, float64(len(bc.Peers())), bc.id)
+		ch <- prometheus.MustNewConstMetric(m.syncLag, prometheus.GaugeValue, float64(bc.SyncLag()), bc.id)
+	}
+
+	m.blockInterval.Collect(ch)
+	m.hashrate.Collect(ch)
+	m.rejected.Collect(ch)
+	m.peerFailures.Collect(ch)
+}
+
+// ------------------------------------------------------------------
+
+func (m *Metrics) observeBlock(id string, previous *Block, b *Block) {
+	if m == nil || previous.timestamp == 0 {
+		return
+	}
+	interval := time.Duration(b.timestamp - previous.timestamp)
+	m.blockInterval.WithLabelValues(id).Observe(interval.Seconds())
+}
+
+func (m *Metrics) observeProofOfWork(id string, hashes int, elapsed time.Duration) {
+	if m == nil || elapsed <= 0 {
+		return
+	}
+	m.hashrate.WithLabelValues(id).Set(float64(hashes) / elapsed.Seconds())
+}
+
+func (m *Metrics) rejectTransaction(id string, err error) {
+	if m == nil {
+		return
+	}
+	m.rejected.WithLabelValues(id, rejectReason(err)).Inc()
+}
+
+func (m *Metrics) peerFailure(id string, peer string, operation string) {
+	if m == nil {
+		return
+	}
+	m.peerFailures.WithLabelValues(id, peer, operation).Inc()
+}
+
+// rejectReason maps a witness error to a short metric label.
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingWitness):
+		return "missing_witness"
+	case errors.Is(err, wallet.ErrInvalidAddress):
+		return "invalid_address"
+	case errors.Is(err, wallet.ErrAddressMismatch):
+		return "address_mismatch"
+	case errors.Is(err, utils.ErrInvalidPublicKey):
+		return "invalid_public_key"
+	case errors.Is(err, utils.ErrInvalidSignature),
+		errors.Is(err, wallet.ErrInvalidCosignature),
+		errors.Is(err, wallet.ErrUnknownCosigner):
+		return "invalid_signature"
+	case errors.Is(err, wallet.ErrThresholdNotMet):
+		return "threshold_not_met"
+	case errors.Is(err, wallet.ErrInvalidMultisig):
+		return "invalid_multisig"
+	}
+	return "other"
+}
@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// Params are the network and miner settings of a chain. The package
+// constants are the defaults.
+type Params struct {
+	Difficulty     int
+	Reward         float32
+	MiningInterval time.Duration
+
+	SyncInterval   time.Duration
+	PeerTimeout    time.Duration
+	PortRangeStart uint16
+	PortRangeEnd   uint16
+	IPRangeStart   uint8
+	IPRangeEnd     uint8
+
+	// Peers are "host:port" addresses kept across neighbor rescans, as if
+	// added with AddPeer.
+	Peers []string
+
+	// PeerToken authenticates this node to its peers.
+	PeerToken string
+	// PeerTLS makes requests to peers over https, verifying them and, for
+	// mutual TLS, presenting this node's certificate. Nil means http.
+	PeerTLS *tls.Config
+}
+
+func DefaultParams() Params {
+	return Params{
+		Difficulty:     MINING_DIFFICULTY,
+		Reward:         MINING_REWARD,
+		MiningInterval: time.Second * MINING_TIMER,
+		SyncInterval:   time.Second * BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC,
+		PeerTimeout:    PEER_REQUEST_TIMEOUT,
+		PortRangeStart: BLOCKCHAIN_PORT_RANGE_START,
+		PortRangeEnd:   BLOCKCHAIN_PORT_RANGE_END,
+		IPRangeStart:   NEIGHBOR_IP_RANGE_START,
+		IPRangeEnd:     NEIGHBOR_IP_RANGE_END,
+	}
+}
+
+type Option func(*Blockchain)
+
+func WithParams(p Params) Option {
+	return func(bc *Blockchain) {
+		bc.params = p
+	}
+}
+
+// WithID names the chain when a node hosts several.
+func WithID(id string) Option {
+	return func(bc *Blockchain) {
+		bc.id = id
+	}
+}
+
+// WithPeerPath is appended to peer addresses to reach this chain's API
+// on them, such as "/chains/test".
+func WithPeerPath(path string) Option {
+	return func(bc *Blockchain) {
+		bc.peerPath = path
+	}
+}
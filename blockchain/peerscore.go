@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Misbehavior points. A peer is banned once its points reach the ban
+// score, which is BAN_SCORE by default: one invalid chain, two invalid
+// blocks or five invalid transactions.
+const (
+	BAN_SCORE    = 100
+	BAN_DURATION = 24 * time.Hour
+
+	SCORE_INVALID_CHAIN       = 100
+	SCORE_INVALID_BLOCK       = 50
+	SCORE_INVALID_TRANSACTION = 20
+)
+
+// PeerScores tracks misbehavior by peer and bans peers that reach the
+// ban score. Peers are keyed by how they were seen: "host:port" and, once
+// known, the node ID for peers this node calls, and the node ID or IP for
+// callers.
+// Points are forgotten once a peer has behaved for the ban duration. A
+// nil *PeerScores bans no one.
+type PeerScores struct {
+	banScore    int
+	banDuration time.Duration
+	now         func() time.Time
+
+	mux    sync.Mutex
+	scores map[string]*peerScore
+}
+
+type peerScore struct {
+	points      int
+	last        time.Time
+	bannedUntil time.Time
+	reason      string
+}
+
+// Ban is a peer refused until Until.
+type Ban struct {
+	Peer   string
+	Until  time.Time
+	Reason string
+}
+
+func NewPeerScores(banScore int, banDuration time.Duration) *PeerScores {
+	return &PeerScores{
+		banScore:    banScore,
+		banDuration: banDuration,
+		now:         time.Now,
+		scores:      make(map[string]*peerScore),
+	}
+}
+
+// WithPeerScores shares a misbehavior tracker between chains, so a peer
+// banned on one is banned on all.
+func WithPeerScores(s *PeerScores) Option {
+	return func(bc *Blockchain) {
+		bc.peerScores = s
+	}
+}
+
+// Misbehaved adds points to peer and reports whether it is now banned.
+func (s *PeerScores) Misbehaved(peer string, points int, reason string) bool {
+
+	if s == nil {
+		return false
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	ps, ok := s.scores[peer]
+	if !ok {
+		ps = &peerScore{}
+		s.scores[peer] = ps
+	}
+	if now.Before(ps.bannedUntil) {
+		return true
+	}
+
+	ps.points += points
+	ps.last = now
+	ps.reason = reason
+
+	if ps.points < s.banScore {
+		slog.Info("peer misbehaved", "peer", peer, "reason", reason, "score", ps.points)
+		return false
+	}
+
+	ps.bannedUntil = now.Add(s.banDuration)
+	slog.Warn("peer banned", "peer", peer, "reason", reason, "until", ps.bannedUntil)
+	return true
+}
+
+// prune forgets peers that are not banned and have behaved for the ban
+// duration.
+func (s *PeerScores) prune(now time.Time) {
+	for peer, ps := range s.scores {
+		if !now.Before(ps.bannedUntil) && now.Sub(ps.last) > s.banDuration {
+			delete(s.scores, peer)
+		}
+	}
+}
+
+func (s *PeerScores) Banned(peer string) bool {
+
+	if s == nil {
+		return false
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	ps, ok := s.scores[peer]
+	return ok && s.now().Before(ps.bannedUntil)
+}
+
+// Bans lists the peers banned now, by peer.
+func (s *PeerScores) Bans() []Ban {
+
+	bans := make([]Ban, 0)
+	if s == nil {
+		return bans
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := s.now()
+	for peer, ps := range s.scores {
+		if now.Before(ps.bannedUntil) {
+			bans = append(bans, Ban{Peer: peer, Until: ps.bannedUntil, Reason: ps.reason})
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Peer < bans[j].Peer })
+
+	return bans
+}
+
+// Unban lifts a ban and clears the peer's points, reporting whether it
+// was banned.
+func (s *PeerScores) Unban(peer string) bool {
+
+	if s == nil {
+		return false
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	ps, ok := s.scores[peer]
+	delete(s.scores, peer)
+	return ok && s.now().Before(ps.bannedUntil)
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerScores(t *testing.T) {
+	//
+	now := time.Unix(1000, 0)
+	s := NewPeerScores(BAN_SCORE, time.Hour)
+	s.now = func() time.Time { return now }
+	//
+	assert.False(t, s.Misbehaved("10.0.0.1", SCORE_INVALID_BLOCK, "invalid block"))
+	assert.False(t, s.Banned("10.0.0.1"))
+	assert.True(t, s.Misbehaved("10.0.0.1", SCORE_INVALID_BLOCK, "invalid block"))
+	assert.True(t, s.Banned("10.0.0.1"))
+	assert.False(t, s.Banned("10.0.0.2"))
+	assert.Equal(t, []Ban{{Peer: "10.0.0.1", Until: now.Add(time.Hour), Reason: "invalid block"}}, s.Bans())
+	//
+	now = now.Add(time.Hour + time.Second)
+	assert.False(t, s.Banned("10.0.0.1"))
+	assert.False(t, s.Misbehaved("10.0.0.1", SCORE_INVALID_TRANSACTION, "invalid transaction"))
+	//
+	s.Misbehaved("10.0.0.3", SCORE_INVALID_CHAIN, "invalid chain")
+	assert.True(t, s.Unban("10.0.0.3"))
+	assert.False(t, s.Banned("10.0.0.3"))
+	assert.False(t, s.Unban("10.0.0.3"))
+	//
+	var none *PeerScores
+	assert.False(t, none.Misbehaved("10.0.0.1", BAN_SCORE, "invalid chain"))
+	assert.Empty(t, none.Bans())
+}
+
+func TestResolveConflictsBansInvalidChain(t *testing.T) {
+	//
+	evil := NewBlockchain("miner", 5000)
+	forged := NewTransaction(wallet.NewWallet().BlockchainAddress(), wallet.NewWallet().BlockchainAddress(), 50)
+	b := NewBlock(0, evil.LastBlock().Hash(), []*Transaction{forged})
+	for !evil.ValidProof(b.nonce, b.previousHash, b.transactions, MINING_DIFFICULTY) {
+		b.nonce++
+	}
+	evil.chain = append(evil.chain, b)
+	//
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m, _ := evil.MarshalJSON()
+		w.Write(m)
+	}))
+	defer srv.Close()
+	peer := srv.Listener.Addr().String()
+	//
+	p := DefaultParams()
+	p.Peers = []string{peer}
+	scores := NewPeerScores(BAN_SCORE, time.Hour)
+	bc := NewBlockchain("miner", 5000, WithParams(p), WithPeerScores(scores))
+	//
+	assert.ErrorIs(t, bc.VerifyBlock(b), ErrMissingWitness)
+	assert.False(t, bc.ResolveConflicts())
+	assert.Equal(t, 0, bc.Height())
+	assert.Equal(t, 0, bc.SyncLag())
+	assert.True(t, scores.Banned(peer))
+	assert.Empty(t, bc.Peers())
+}
+
+func TestResolveConflictsBansMalformedChain(t *testing.T) {
+	//
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"blocks":[{"previous_hash":"00"},{}]}`))
+	}))
+	defer srv.Close()
+	peer := srv.Listener.Addr().String()
+	//
+	p := DefaultParams()
+	p.Peers = []string{peer}
+	scores := NewPeerScores(BAN_SCORE, time.Hour)
+	bc := NewBlockchain("miner", 5000, WithParams(p), WithPeerScores(scores))
+	//
+	assert.False(t, bc.ResolveConflicts())
+	assert.Equal(t, 0, bc.Height())
+	assert.True(t, scores.Banned(peer))
+}
@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrInvalidChain = errors.New("invalid chain")
+
+// CHAIN_FILE is the name of the saved chain inside a node's data
+// directory.
+const CHAIN_FILE = "chain.json"
+
+type snapshot struct {
+	Blocks       []*Block       `json:"blocks"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// Save writes the chain and transaction pool to path. The file is
+// replaced atomically, so a crash mid-write leaves the previous save.
+func (bc *Blockchain) Save(path string) error {
+
+	bc.mux.RLock()
+	m, err := json.Marshal(&snapshot{Blocks: bc.chain, Transactions: bc.transactionPool})
+	bc.mux.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(m); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Load replaces the chain and pool with those saved at path. The chain
+// must be valid, and pool transactions whose witness no longer verifies
+// are dropped. A missing file returns an error wrapping os.ErrNotExist.
+func (bc *Blockchain) Load(path string) error {
+
+	m, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(m, &s); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(s.Blocks) == 0 || !bc.ValidChain(s.Blocks) {
+		return fmt.Errorf("%s: %w", path, ErrInvalidChain)
+	}
+
+	pool := make([]*Transaction, 0, len(s.Transactions))
+	for _, t := range s.Transactions {
+		if err := verifyWitness(t); err == nil {
+			pool = append(pool, t)
+		}
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	bc.chain = s.Blocks
+	bc.transactionPool = pool
+
+	return nil
+}
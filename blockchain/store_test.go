@@ -0,0 +1,73 @@
+package blockchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoad(t *testing.T) {
+	//
+	path := filepath.Join(t.TempDir(), CHAIN_FILE)
+	alice := wallet.NewWallet().BlockchainAddress()
+	//
+	bc := NewBlockchain("miner", 5000)
+	bc.AddTransaction(MINING_SENDER, alice, MINING_REWARD, nil, nil)
+	bc.CreateBlock(bc.ProofOfWork(), bc.LastBlock().Hash())
+	bc.AddTransaction(MINING_SENDER, alice, 5, nil, nil)
+	require.NoError(t, bc.Save(path))
+	//
+	restored := NewBlockchain("miner", 5000)
+	require.NoError(t, restored.Load(path))
+	assert.Equal(t, bc.LastBlock().Hash(), restored.LastBlock().Hash())
+	assert.Len(t, restored.TransactionPool(), 1)
+	assert.Equal(t, float32(MINING_REWARD), restored.CalculateTotalAmount(alice))
+	//
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(t, entries, 1)
+	//
+	fresh := NewBlockchain("miner", 5000)
+	assert.ErrorIs(t, fresh.Load(filepath.Join(t.TempDir(), CHAIN_FILE)), os.ErrNotExist)
+	//
+	bc.LastBlock().nonce++
+	require.NoError(t, bc.Save(path))
+	assert.ErrorIs(t, fresh.Load(path), ErrInvalidChain)
+	assert.Equal(t, 0, fresh.Height())
+}
+
+func TestStopWaitsForLoops(t *testing.T) {
+	//
+	var l loop
+	runs := make(chan int, 10)
+	release := make(chan struct{})
+	n := 0
+	l.start(0, time.Millisecond, func() {
+		n++
+		runs <- n
+		<-release
+	})
+	assert.True(t, l.running())
+	<-runs
+	//
+	stopped := make(chan struct{})
+	go func() {
+		l.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stop returned while a run was in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	//
+	assert.False(t, l.running())
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, runs, 0)
+}
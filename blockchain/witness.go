@@ -0,0 +1,193 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+var ErrMissingWitness = errors.New("transaction is not signed")
+
+// Witness authorizes a transaction and travels with it into blocks so
+// peers can check it again in ValidChain. A single-key witness sets
+// PublicKey and Signature; a multisig witness sets Threshold and
+// PublicKeys, with Signatures aligned to PublicKeys and empty for keys
+// that did not sign.
+type Witness struct {
+	PublicKey  string   `json:"public_key,omitempty"`
+	Signature  string   `json:"signature,omitempty"`
+	Threshold  int      `json:"threshold,omitempty"`
+	PublicKeys []string `json:"public_keys,omitempty"`
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+func (w *Witness) IsMultisig() bool {
+	return len(w.PublicKeys) > 0
+}
+
+// WitnessFromRequest checks the keys, signatures and sender address of an
+// API request and returns its witness in canonical form, so a relay cannot
+// change a transaction's ID without invalidating it. Keys and signatures
+// are re-encoded the way they parse; a multisig witness lists its keys in
+// sorted order and keeps only the first Threshold signatures among them.
+func WitnessFromRequest(tr *client.TransactionRequest) (*Witness, error) {
+
+	if tr.IsMultisig() {
+
+		ms, err := wallet.ParseMultisig(*tr.Threshold, tr.PublicKeys)
+		if err != nil {
+			return nil, err
+		}
+		if ms.Address() != *tr.SenderBlockchainAddress {
+			return nil, fmt.Errorf("%w: %s", wallet.ErrAddressMismatch, *tr.SenderBlockchainAddress)
+		}
+		if len(tr.Signatures) != len(tr.PublicKeys) {
+			return nil, fmt.Errorf("%w: %d signatures for %d keys", wallet.ErrInvalidMultisig, len(tr.Signatures), len(tr.PublicKeys))
+		}
+
+		signatures := make(map[string]string)
+		for i, s := range tr.Signatures {
+			if s == "" {
+				continue
+			}
+			sig, err := utils.ParseSignature(s)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, _ := utils.ParsePublicKey(tr.PublicKeys[i])
+			signatures[publicKey.String()] = sig.String()
+		}
+
+		w := &Witness{
+			Threshold:  ms.Threshold(),
+			PublicKeys: ms.PublicKeyStrs(),
+			Signatures: make([]string, len(tr.PublicKeys)),
+		}
+		signed := 0
+		for i, k := range w.PublicKeys {
+			if s, ok := signatures[k]; ok && signed < w.Threshold {
+				w.Signatures[i] = s
+				signed++
+			}
+		}
+
+		return w, nil
+	}
+
+	publicKey, signature, err := tr.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Witness{PublicKey: publicKey.String(), Signature: signature.String()}, nil
+}
+
+// Request returns the API form of a transaction, as relayed to peers.
+func (t *Transaction) Request() *client.TransactionRequest {
+
+	tr := &client.TransactionRequest{
+		SenderBlockchainAddress:    &t.senderBlockchainAddress,
+		RecipientBlockchainAddress: &t.recipientBlockchainAddress,
+		Value:                      &t.value,
+	}
+
+	w := t.witness
+	if w == nil {
+		return tr
+	}
+
+	if w.IsMultisig() {
+		tr.Threshold = &w.Threshold
+		tr.PublicKeys = w.PublicKeys
+		tr.Signatures = w.Signatures
+	} else {
+		tr.SenderPublicKey = &w.PublicKey
+		tr.Signature = &w.Signature
+	}
+
+	return tr
+}
+
+// signingHash covers the sender, recipient and value only; the witness
+// cannot sign itself.
+func (t *Transaction) signingHash() [32]byte {
+	m, _ := json.Marshal(NewTransaction(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value))
+	return sha256.Sum256(m)
+}
+
+// verifyWitness checks that t is authorized by its sender. Mining rewards
+// carry no witness.
+func verifyWitness(t *Transaction) error {
+
+	if t.senderBlockchainAddress == MINING_SENDER {
+		if t.witness != nil {
+			return errors.New("mining reward must not carry a witness")
+		}
+		return nil
+	}
+
+	if err := wallet.ValidateAddress(t.recipientBlockchainAddress); err != nil {
+		return err
+	}
+
+	w := t.witness
+	if w == nil {
+		return ErrMissingWitness
+	}
+
+	hash := t.signingHash()
+
+	if !w.IsMultisig() {
+
+		publicKey, err := utils.ParsePublicKey(w.PublicKey)
+		if err != nil {
+			return err
+		}
+		signature, err := utils.ParseSignature(w.Signature)
+		if err != nil {
+			return err
+		}
+		if err := wallet.VerifyAddress(t.senderBlockchainAddress, publicKey); err != nil {
+			return err
+		}
+		if signature.Algorithm != publicKey.Algorithm() || !publicKey.Verify(hash[:], signature) {
+			return fmt.Errorf("%w: bad signature", utils.ErrInvalidSignature)
+		}
+
+		return nil
+	}
+
+	ms, err := wallet.ParseMultisig(w.Threshold, w.PublicKeys)
+	if err != nil {
+		return err
+	}
+	if ms.Address() != t.senderBlockchainAddress {
+		return fmt.Errorf("%w: %s", wallet.ErrAddressMismatch, t.senderBlockchainAddress)
+	}
+	if len(w.Signatures) != len(w.PublicKeys) {
+		return fmt.Errorf("%w: %d signatures for %d keys", wallet.ErrInvalidMultisig, len(w.Signatures), len(w.PublicKeys))
+	}
+
+	signatures := make(map[string]*utils.Signature)
+	for i, s := range w.Signatures {
+		if s == "" {
+			continue
+		}
+		sig, err := utils.ParseSignature(s)
+		if err != nil {
+			return err
+		}
+		publicKey, err := utils.ParsePublicKey(w.PublicKeys[i])
+		if err != nil {
+			return err
+		}
+		signatures[publicKey.String()] = sig
+	}
+
+	return ms.Verify(hash[:], signatures)
+}
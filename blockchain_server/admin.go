@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+// Operator endpoints used by blockchainctl: chain summary, block and
+// transaction lookup, peer management and the mining loop switch.
+
+func chainInfo(bc *blockchain.Blockchain) *client.ChainInfoResponse {
+
+	genesis, _ := bc.BlockByHeight(0)
+
+	return &client.ChainInfoResponse{
+		ChainID:     bc.ID(),
+		Height:      bc.Height(),
+		TipHash:     fmt.Sprintf("%x", bc.LastBlock().Hash()),
+		GenesisHash: fmt.Sprintf("%x", genesis.Hash()),
+		Difficulty:  bc.Params().Difficulty,
+		MempoolSize: len(bc.TransactionPool()),
+		PeerCount:   len(bc.Peers()),
+		Mining:      bc.IsMining(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	m, _ := json.Marshal(v)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(m)
+}
+
+func writeStatus(w http.ResponseWriter, status int, message string) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, string(utils.JsonStatus(message)))
+}
+
+func (bcs *BlockchainServer) ChainInfo(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, chainInfo(bcs.chain(req)))
+}
+
+// GetBlock serves /v1/blocks/{id}, where id is a height or a hex hash.
+func (bcs *BlockchainServer) GetBlock(w http.ResponseWriter, req *http.Request) {
+
+	bc := bcs.chain(req)
+	id := req.PathValue("id")
+
+	var (
+		b      *blockchain.Block
+		height int
+		ok     bool
+	)
+
+	if h, err := strconv.Atoi(id); err == nil {
+		height = h
+		b, ok = bc.BlockByHeight(h)
+	} else {
+		b, height, ok = bc.BlockByHash(id)
+	}
+
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "block not found")
+		return
+	}
+
+	m, _ := b.MarshalJSON()
+
+	writeJSON(w, http.StatusOK, &client.BlockResponse{
+		Height: height,
+		Hash:   fmt.Sprintf("%x", b.Hash()),
+		Block:  m,
+	})
+}
+
+func (bcs *BlockchainServer) GetTransaction(w http.ResponseWriter, req *http.Request) {
+
+	hash := req.PathValue("hash")
+
+	t, height, ok := bcs.chain(req).FindTransaction(hash)
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+
+	m, _ := t.MarshalJSON()
+	tr := &client.TransactionLookupResponse{
+		Hash:        hash,
+		Status:      "pending",
+		Transaction: m,
+	}
+	if height >= 0 {
+		tr.Status = "confirmed"
+		tr.Height = &height
+	}
+
+	writeJSON(w, http.StatusOK, tr)
+}
+
+func (bcs *BlockchainServer) GetPeers(w http.ResponseWriter, req *http.Request) {
+	bc := bcs.chain(req)
+	writeJSON(w, http.StatusOK, &client.PeersResponse{Peers: bc.Peers(), NodeIDs: bc.PeerIDs()})
+}
+
+func (bcs *BlockchainServer) AddPeer(w http.ResponseWriter, req *http.Request) {
+
+	var pr client.PeerRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&pr); err != nil || !utils.ValidHostPort(pr.Address) {
+		writeStatus(w, http.StatusBadRequest, "address must be host:port")
+		return
+	}
+
+	bcs.chain(req).AddPeer(pr.Address)
+	writeStatus(w, http.StatusOK, "success")
+}
+
+func (bcs *BlockchainServer) RemovePeer(w http.ResponseWriter, req *http.Request) {
+
+	if !bcs.chain(req).RemovePeer(req.PathValue("address")) {
+		writeStatus(w, http.StatusNotFound, "peer not found")
+		return
+	}
+
+	writeStatus(w, http.StatusOK, "success")
+}
+
+func (bcs *BlockchainServer) StopMine(w http.ResponseWriter, req *http.Request) {
+	bcs.chain(req).StopMining()
+	writeStatus(w, http.StatusOK, "success")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(testConfig())
+	srv := httptest.NewServer(bcs.AdminRouter())
+	defer srv.Close()
+	c := client.New(srv.URL, client.WithRetries(0, 0))
+	ctx := context.Background()
+	//
+	info, err := c.ChainInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, bcs.GetBlockchain().Height(), info.Height)
+	assert.Equal(t, blockchain.MINING_DIFFICULTY, info.Difficulty)
+	//
+	genesis, err := c.Block(ctx, "0")
+	require.NoError(t, err)
+	assert.Equal(t, 0, genesis.Height)
+	assert.Equal(t, info.GenesisHash, genesis.Hash)
+	//
+	byHash, err := c.Block(ctx, genesis.Hash)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(genesis.Block), string(byHash.Block))
+	//
+	_, err = c.Block(ctx, strconv.Itoa(info.Height+1))
+	assert.True(t, client.IsStatus(err, http.StatusNotFound))
+	//
+	recipient := wallet.NewWallet().BlockchainAddress()
+	bc := bcs.GetBlockchain()
+	bc.AddTransaction(blockchain.MINING_SENDER, recipient, 1, nil, nil)
+	pending := bc.TransactionPool()[len(bc.TransactionPool())-1]
+	hash := fmt.Sprintf("%x", pending.Hash())
+	//
+	tr, err := c.Transaction(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", tr.Status)
+	assert.Nil(t, tr.Height)
+	//
+	require.NoError(t, c.Mine(ctx))
+	tr, err = c.Transaction(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, "confirmed", tr.Status)
+	assert.Equal(t, bc.Height(), *tr.Height)
+	//
+	_, err = c.Transaction(ctx, "00")
+	assert.True(t, client.IsStatus(err, http.StatusNotFound))
+}
+
+func TestAdminPeersAndMiner(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(testConfig())
+	srv := httptest.NewServer(bcs.AdminRouter())
+	defer srv.Close()
+	c := client.New(srv.URL, client.WithRetries(0, 0))
+	ctx := context.Background()
+	//
+	require.NoError(t, c.AddPeer(ctx, "127.0.0.1:5999"))
+	peers, err := c.Peers(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, peers.Peers, "127.0.0.1:5999")
+	//
+	err = c.AddPeer(ctx, "not a peer")
+	assert.True(t, client.IsStatus(err, http.StatusBadRequest))
+	//
+	require.NoError(t, c.RemovePeer(ctx, "127.0.0.1:5999"))
+	peers, err = c.Peers(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, peers.Peers, "127.0.0.1:5999")
+	//
+	err = c.RemovePeer(ctx, "127.0.0.1:5999")
+	assert.True(t, client.IsStatus(err, http.StatusNotFound))
+	//
+	require.NoError(t, c.StartMining(ctx))
+	info, err := c.ChainInfo(ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Mining)
+	//
+	require.NoError(t, c.StopMining(ctx))
+	info, err = c.ChainInfo(ctx)
+	require.NoError(t, err)
+	assert.False(t, info.Mining)
+}
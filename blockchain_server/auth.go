@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/i101dev/blockchain-api/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Roles gate every endpoint but the health checks and the API spec:
+// read for queries, submit for new transactions, peer for the calls other
+// nodes make, and admin for mining and peer management, which are only
+// served on the admin listener.
+
+// grpcRoles are the roles the gRPC methods require.
+var grpcRoles = map[string]utils.Role{
+	nodepb.Node_GetPeerInfo_FullMethodName:      utils.ROLE_READ,
+	nodepb.Node_GetHeaders_FullMethodName:       utils.ROLE_READ,
+	nodepb.Node_GetBlocks_FullMethodName:        utils.ROLE_READ,
+	nodepb.Node_AnnounceBlock_FullMethodName:    utils.ROLE_PEER,
+	nodepb.Node_RelayTransaction_FullMethodName: utils.ROLE_PEER,
+}
+
+// authorizeGRPC authenticates the "authorization" metadata of a call,
+// read the same way as the HTTP header, or else the client certificate or
+// node signature, against the public authenticator, then applies the bans
+// and rate limits.
+func (bcs *BlockchainServer) authorizeGRPC(ctx context.Context, method string) error {
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			token, _ = strings.CutPrefix(v[0], "Bearer ")
+		} else if v := md.Get(strings.ToLower(utils.API_KEY_HEADER)); len(v) > 0 {
+			token = v[0]
+		}
+	}
+
+	p, err := bcs.auth.AuthenticateCaller(ctx, strings.TrimSpace(token), grpcTLSState(ctx))
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	role, ok := grpcRoles[method]
+	if !ok {
+		role = utils.ROLE_ADMIN
+	}
+	if !p.Has(role) {
+		if p.Name == "" {
+			return status.Errorf(codes.Unauthenticated, "%s role required", role)
+		}
+		return status.Errorf(codes.PermissionDenied, "%s role required", role)
+	}
+	if role == utils.ROLE_PEER && bcs.cfg.Auth.RequirePeerSignatures && utils.NodeIDFromContext(ctx) == "" {
+		return status.Error(codes.Unauthenticated, "peer calls must be signed")
+	}
+
+	return bcs.limitGRPC(ctx, p)
+}
+
+// grpcTLSState is the TLS connection of a call, or nil over plain TCP.
+func grpcTLSState(ctx context.Context) *tls.ConnectionState {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			return &info.State
+		}
+	}
+	return nil
+}
+
+func (bcs *BlockchainServer) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := bcs.verifyGRPC(ctx, info.FullMethod, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcs.authorizeGRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (bcs *BlockchainServer) streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := bcs.authorizeGRPC(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
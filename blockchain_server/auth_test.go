@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	OPS_TOKEN  = "ops-token-0123456789"
+	FEED_TOKEN = "feed-token-0123456789"
+	PEER_TOKEN = "peer-token-0123456789"
+)
+
+func authConfig() *config.Node {
+	cfg := testConfig()
+	cfg.Auth.Keys = config.APIKeys{
+		{Name: "ops", Token: OPS_TOKEN, Roles: []string{"admin"}},
+		{Name: "feed", Token: FEED_TOKEN, Roles: []string{"submit"}},
+	}
+	cfg.Auth.Anonymous = []string{"read"}
+	cfg.Auth.AdminAnonymous = []string{"read"}
+	cfg.Auth.PeerToken = PEER_TOKEN
+	return cfg
+}
+
+func serve(h http.Handler, method string, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthRoles(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(authConfig())
+	public := bcs.Router()
+	admin := bcs.AdminRouter()
+	//
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", "").Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/healthz", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(public, "GET", "/v1/chain", "guess").Code)
+	//
+	assert.Equal(t, http.StatusUnauthorized, serve(public, "PUT", "/v1/consensus", "").Code)
+	assert.Equal(t, http.StatusForbidden, serve(public, "PUT", "/v1/consensus", FEED_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(public, "PUT", "/v1/consensus", PEER_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(public, "DELETE", "/v1/transactions", OPS_TOKEN).Code)
+	//
+	rec := serve(public, "POST", "/v1/transactions", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "submit role required")
+	assert.Equal(t, http.StatusBadRequest, serve(public, "POST", "/v1/transactions", FEED_TOKEN).Code)
+	//
+	assert.Equal(t, http.StatusNotFound, serve(public, "POST", "/v1/mine/stop", OPS_TOKEN).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(admin, "POST", "/v1/mine/stop", "").Code)
+	assert.Equal(t, http.StatusForbidden, serve(admin, "POST", "/v1/mine/stop", PEER_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(admin, "POST", "/v1/mine/stop", OPS_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(admin, "POST", "/chains/main/v1/mine/stop", OPS_TOKEN).Code)
+}
+
+func TestPeerRoutesFailClosed(t *testing.T) {
+	//
+	peer, err := utils.GenerateNodeIdentity()
+	require.NoError(t, err)
+	stranger, err := utils.GenerateNodeIdentity()
+	require.NoError(t, err)
+	cfg := testConfig()
+	cfg.Auth.TrustedNodes = []string{peer.ID()}
+	public := NewBlockchainServer(cfg).Router()
+	//
+	assert.Equal(t, http.StatusUnauthorized, serve(public, "PUT", "/v1/consensus", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(public, "DELETE", "/v1/transactions", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveSigned(public, stranger, "PUT", "/v1/consensus").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveSigned(public, stranger, "DELETE", "/v1/transactions").Code)
+	assert.Equal(t, http.StatusOK, serveSigned(public, peer, "PUT", "/v1/consensus").Code)
+	assert.Equal(t, http.StatusOK, serveSigned(public, peer, "DELETE", "/v1/transactions").Code)
+}
+
+func TestAuthRPC(t *testing.T) {
+	//
+	h := NewBlockchainServer(authConfig()).Router()
+	body := `{"jsonrpc":"2.0","method":"sendRawTransaction","params":[{}],"id":1}`
+	//
+	rec := rpcCall(h, body)
+	assert.Contains(t, rec.Body.String(), `"code":-32001`)
+	assert.Contains(t, rec.Body.String(), "submit role required")
+	//
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+FEED_TOKEN)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Contains(t, rec.Body.String(), `"code":-32602`)
+	//
+	rec = rpcCall(h, `{"jsonrpc":"2.0","method":"getChainInfo","id":2}`)
+	assert.NotContains(t, rec.Body.String(), `"error"`)
+}
+
+func TestAuthGRPC(t *testing.T) {
+	//
+	nc := dialNode(t, NewBlockchainServer(authConfig()))
+	ctx := context.Background()
+	//
+	_, err := nc.GetPeerInfo(ctx, &nodepb.PeerInfoRequest{})
+	assert.NoError(t, err)
+	//
+	_, err = nc.RelayTransaction(ctx, &nodepb.Transaction{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	//
+	feed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+FEED_TOKEN)
+	_, err = nc.RelayTransaction(feed, &nodepb.Transaction{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	//
+	peer := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+PEER_TOKEN)
+	_, err = nc.AnnounceBlock(peer, &nodepb.BlockAnnouncement{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	//
+	guess := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer guess")
+	_, err = nc.GetPeerInfo(guess, &nodepb.PeerInfoRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
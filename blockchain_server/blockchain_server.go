@@ -1,285 +1,556 @@
 package main
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"strconv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/config"
 	"github.com/i101dev/blockchain-api/utils"
-	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-var cache map[string]*blockchain.Blockchain = make(map[string]*blockchain.Blockchain)
+const (
+	REQUEST_TIMEOUT = 30 * time.Second
+	MAX_BODY_BYTES  = 1 << 20
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
 
 type BlockchainServer struct {
-	port uint16
+	cfg *config.Node
+
+	// chains holds every hosted chain by ID; chainIDs keeps the
+	// configured order, the first being the default chain.
+	chains   map[string]*blockchain.Blockchain
+	chainIDs []string
+
+	// shutdown is closed when Run starts shutting down, ending event
+	// streams that would otherwise hold it up.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+
+	// loaded and synced gate /readyz: the chains have been read from the
+	// data directory, and the initial peer sync has finished.
+	loaded atomic.Bool
+	synced atomic.Bool
+
+	registry    *prometheus.Registry
+	httpMetrics *utils.HTTPMetrics
+
+	// auth serves the public HTTP and gRPC listeners, adminAuth the admin
+	// listener. They share the API keys but not the anonymous roles.
+	auth      *utils.Authenticator
+	adminAuth *utils.Authenticator
+
+	limiter     *utils.RateLimiter
+	peerLimiter *utils.RateLimiter
+	verify      *utils.Limiter
+	peerScores  *blockchain.PeerScores
+
+	identity *utils.NodeIdentity
+	replays  *utils.ReplayCache
 }
 
-func NewBlockchainServer(port uint16) *BlockchainServer {
-	return &BlockchainServer{port}
-}
+// NewBlockchainServer builds the node described by cfg. Without
+// WithIdentity it generates an identity, and panics if it cannot, as the
+// node signs every request to its peers.
+func NewBlockchainServer(cfg *config.Node, opts ...Option) *BlockchainServer {
+
+	bcs := &BlockchainServer{
+		cfg:      cfg,
+		chains:   make(map[string]*blockchain.Blockchain),
+		shutdown: make(chan struct{}),
+		registry: utils.NewRegistry(),
+		replays:  utils.NewReplayCache(),
+	}
+	for _, opt := range opts {
+		opt(bcs)
+	}
+	if bcs.identity == nil {
+		id, err := utils.GenerateNodeIdentity()
+		if err != nil {
+			panic(fmt.Sprintf("generate node identity: %v", err))
+		}
+		bcs.identity = id
+	}
+	bcs.httpMetrics = utils.NewHTTPMetrics(bcs.registry)
+	bcs.auth = utils.NewAuthenticator(cfg.Auth.APIKeys(), config.Roles(cfg.Auth.Anonymous)).
+		WithClientCerts([]utils.Role{utils.ROLE_PEER}).
+		WithNodeSignatures([]utils.Role{utils.ROLE_PEER}, bcs.trustedNode)
+	bcs.adminAuth = utils.NewAuthenticator(cfg.Auth.APIKeys(), config.Roles(cfg.Auth.AdminAnonymous))
+
+	l := cfg.Limits
+	bcs.limiter = utils.NewRateLimiter(float64(l.Rate), l.Burst)
+	bcs.peerLimiter = utils.NewRateLimiter(float64(l.PeerRate), l.PeerBurst)
+	if l.MaxVerifications == 0 {
+		l.MaxVerifications = runtime.NumCPU()
+	}
+	bcs.verify = utils.NewLimiter(l.MaxVerifications)
+	bcs.peerScores = blockchain.NewPeerScores(l.BanScore, l.BanDuration)
+
+	metrics := blockchain.NewMetrics()
+	bcs.registry.MustRegister(metrics)
+
+	for i, c := range cfg.Chains {
+		opts := []blockchain.Option{
+			blockchain.WithParams(cfg.Params(c)),
+			blockchain.WithID(c.ID),
+			blockchain.WithMetrics(metrics),
+			blockchain.WithPeerScores(bcs.peerScores),
+			blockchain.WithIdentity(bcs.identity),
+		}
+		if i > 0 {
+			opts = append(opts, blockchain.WithPeerPath(chainPrefix(c.ID)))
+		}
+		bcs.chains[c.ID] = blockchain.NewBlockchain(cfg.MinerAddress(c), cfg.API.Port, opts...)
+		bcs.chainIDs = append(bcs.chainIDs, c.ID)
+	}
 
-func (bcs *BlockchainServer) Port() uint16 {
-	return bcs.port
+	return bcs
 }
 
-func (bcs *BlockchainServer) GetBlockchain() *blockchain.Blockchain {
+func (bcs *BlockchainServer) Config() *config.Node {
+	return bcs.cfg
+}
 
-	ID := "blockchain"
+func (bcs *BlockchainServer) Port() uint16 {
+	return bcs.cfg.API.Port
+}
 
-	bc, ok := cache[ID]
+func (bcs *BlockchainServer) GRPCPort() uint16 {
+	return bcs.cfg.API.GRPCPort
+}
 
-	if !ok {
-		minerWallet := wallet.NewWallet()
-		bc = blockchain.NewBlockchain(minerWallet.BlockchainAddress(), bcs.Port())
-		cache[ID] = bc
-		log.Printf("\nAddress: %s", minerWallet.BlockchainAddress())
-	}
+func (bcs *BlockchainServer) AdminPort() uint16 {
+	return bcs.cfg.API.AdminPort
+}
 
-	return bc
+// GetBlockchain returns the default chain.
+func (bcs *BlockchainServer) GetBlockchain() *blockchain.Blockchain {
+	return bcs.chains[bcs.chainIDs[0]]
 }
 
 func (bcs *BlockchainServer) GetChainData(w http.ResponseWriter, req *http.Request) {
-	switch req.Method {
-	case http.MethodGet:
-		w.Header().Add("Content-Type", "application/json")
-		bc := bcs.GetBlockchain()
-		m, _ := bc.MarshalJSON()
-		io.WriteString(w, string(m[:]))
-	default:
-		log.Printf("ERROR: Invalid HTTP Method")
-	}
+	w.Header().Add("Content-Type", "application/json")
+	bc := bcs.chain(req)
+	m, _ := bc.MarshalJSON()
+	io.WriteString(w, string(m[:]))
 }
 
-func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Request) {
-
-	switch req.Method {
-	case http.MethodGet:
-		w.Header().Add("Content-Type", "application/json")
-
-		bc := bcs.GetBlockchain()
-		transactions := bc.TransactionPool()
+func (bcs *BlockchainServer) GetTransactions(w http.ResponseWriter, req *http.Request) {
 
-		m, _ := json.Marshal(struct {
-			Transactions []*blockchain.Transaction `json:"transactions"`
-			Length       int                       `json:"length"`
-		}{
-			Transactions: transactions,
-			Length:       len(transactions),
-		})
+	w.Header().Add("Content-Type", "application/json")
 
-		io.WriteString(w, string(m[:]))
+	bc := bcs.chain(req)
+	transactions := bc.TransactionPool()
 
-	case http.MethodPost:
+	m, _ := json.Marshal(struct {
+		Transactions []*blockchain.Transaction `json:"transactions"`
+		Length       int                       `json:"length"`
+	}{
+		Transactions: transactions,
+		Length:       len(transactions),
+	})
 
-		var txn blockchain.TransactionRequest
-
-		decoder := json.NewDecoder(req.Body)
-		err := decoder.Decode(&txn)
-
-		if err != nil {
-			log.Printf("ERROR: %+v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		// fmt.Printf("%+v", txn)
+	io.WriteString(w, string(m[:]))
+}
 
-		if !txn.Validate() {
-			log.Println("ERROR: Missing field(s)")
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+func (bcs *BlockchainServer) PostTransaction(w http.ResponseWriter, req *http.Request) {
 
-		publicKey := utils.PublicKeyFromString(*txn.SenderPublicKey)
-		signature := utils.SignatureFromString(*txn.Signature)
-		bc := bcs.GetBlockchain()
-		// _ = publicKey
-		// _ = signature
-		// _ = bc
+	var txn client.TransactionRequest
 
-		isCreated := bc.CreateTransaction(*txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, *txn.Value, publicKey, signature)
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&txn)
 
-		w.Header().Add("Content-Type", "application/json")
+	if err != nil {
+		utils.RequestLogger(req).Info("malformed transaction request", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-		if !isCreated {
-			w.WriteHeader(http.StatusBadRequest)
-		} else {
-			w.WriteHeader(http.StatusCreated)
-		}
+	if err := txn.Validate(); err != nil {
+		utils.RequestLogger(req).Info("invalid transaction request", "error", err)
+		bcs.chain(req).RejectTransaction(err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
 
-	case http.MethodPut:
+	witness, err := blockchain.WitnessFromRequest(&txn)
+	if err != nil {
+		utils.RequestLogger(req).Info("invalid transaction witness", "error", err)
+		bcs.chain(req).RejectTransaction(err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+	bc := bcs.chain(req)
 
-		var txn blockchain.TransactionRequest
+	isCreated := bc.CreateWitnessedTransaction(*txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, *txn.Value, witness)
 
-		decoder := json.NewDecoder(req.Body)
-		err := decoder.Decode(&txn)
+	w.Header().Add("Content-Type", "application/json")
 
-		if err != nil {
-			log.Printf("ERROR: %+v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+	if !isCreated {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+	} else {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	}
+}
 
-		// fmt.Printf("%+v", txn)
+func (bcs *BlockchainServer) PutTransaction(w http.ResponseWriter, req *http.Request) {
 
-		if !txn.Validate() {
-			log.Println("ERROR: Missing field(s)")
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+	var txn client.TransactionRequest
 
-		publicKey := utils.PublicKeyFromString(*txn.SenderPublicKey)
-		signature := utils.SignatureFromString(*txn.Signature)
-		bc := bcs.GetBlockchain()
-		// _ = publicKey
-		// _ = signature
-		// _ = bc
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&txn)
 
-		isUpdated := bc.AddTransaction(*txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, *txn.Value, publicKey, signature)
+	if err != nil {
+		utils.RequestLogger(req).Info("malformed transaction request", "error", err)
+		bcs.misbehaved(req, blockchain.SCORE_INVALID_TRANSACTION, "malformed transaction")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-		w.Header().Add("Content-Type", "application/json")
+	if err := txn.Validate(); err != nil {
+		utils.RequestLogger(req).Info("invalid transaction request", "error", err)
+		bcs.chain(req).RejectTransaction(err)
+		bcs.misbehaved(req, blockchain.SCORE_INVALID_TRANSACTION, "invalid transaction")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
 
-		var m []byte
-		if !isUpdated {
-			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JsonStatus("fail")
-		} else {
-			m = utils.JsonStatus("success")
-			w.WriteHeader(http.StatusOK)
-		}
+	witness, err := blockchain.WitnessFromRequest(&txn)
+	if err != nil {
+		utils.RequestLogger(req).Info("invalid transaction witness", "error", err)
+		bcs.chain(req).RejectTransaction(err)
+		bcs.misbehaved(req, blockchain.SCORE_INVALID_TRANSACTION, "invalid transaction")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+	bc := bcs.chain(req)
 
-		io.WriteString(w, string(m))
+	isUpdated := bc.AddWitnessedTransaction(*txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, *txn.Value, witness)
 
-	case http.MethodDelete:
-		bc := bcs.GetBlockchain()
-		bc.ClearTransactionPool()
-		io.WriteString(w, string(utils.JsonStatus("success")))
+	w.Header().Add("Content-Type", "application/json")
 
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
+	var m []byte
+	if !isUpdated {
+		bcs.misbehaved(req, blockchain.SCORE_INVALID_TRANSACTION, "invalid transaction")
 		w.WriteHeader(http.StatusBadRequest)
+		m = utils.JsonStatus("fail")
+	} else {
+		m = utils.JsonStatus("success")
+		w.WriteHeader(http.StatusOK)
 	}
+
+	io.WriteString(w, string(m))
+}
+
+func (bcs *BlockchainServer) DeleteTransactions(w http.ResponseWriter, req *http.Request) {
+	bc := bcs.chain(req)
+	bc.ClearTransactionPool()
+	w.Header().Add("Content-Type", "application/json")
+	io.WriteString(w, string(utils.JsonStatus("success")))
 }
 
 func (bcs *BlockchainServer) Mine(w http.ResponseWriter, req *http.Request) {
 
-	switch req.Method {
-	case http.MethodGet:
-		bc := bcs.GetBlockchain()
-		isMined := bc.Mining()
+	bc := bcs.chain(req)
+	isMined := bc.Mining()
 
-		if !isMined {
-			w.WriteHeader(http.StatusBadRequest)
-		} else {
-			w.WriteHeader(http.StatusOK)
-		}
+	w.Header().Add("Content-Type", "application/json")
 
-		w.Header().Add("Content-Type", "application/json")
-
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
+	if !isMined {
 		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+	} else {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, string(utils.JsonStatus("success")))
 	}
 }
 
 func (bcs *BlockchainServer) StartMine(w http.ResponseWriter, req *http.Request) {
-
-	switch req.Method {
-	case http.MethodGet:
-		bc := bcs.GetBlockchain()
-		bc.StartMining()
+	bc := bcs.chain(req)
+	if bc.MinerAddress() == "" {
 		w.Header().Add("Content-Type", "application/json")
-
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("no miner address configured")))
+		return
 	}
+	bc.StartMining()
+	w.Header().Add("Content-Type", "application/json")
+	io.WriteString(w, string(utils.JsonStatus("success")))
 }
 
 func (bcs *BlockchainServer) Amount(w http.ResponseWriter, req *http.Request) {
 
-	switch req.Method {
-	case http.MethodGet:
+	blockchainAddress := req.URL.Query().Get("blockchain_address")
+	amount := bcs.chain(req).CalculateTotalAmount(blockchainAddress)
 
-		blockchainAddress := req.URL.Query().Get("blockchain_address")
-		amount := bcs.GetBlockchain().CalculateTotalAmount(blockchainAddress)
+	m, _ := json.Marshal(&client.AmountResponse{Amount: amount})
 
-		ar := &blockchain.AmountResponse{Amount: amount}
-		m, _ := ar.MarshalJSON()
+	w.Header().Add("Content-Type", "application/json")
+	io.WriteString(w, string(m[:]))
+}
 
-		w.Header().Add("Content-Type", "app")
-		io.WriteString(w, string(m[:]))
+func (bcs *BlockchainServer) Valid(w http.ResponseWriter, req *http.Request) {
 
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+	isValid := "FALSE"
+
+	bc := bcs.chain(req)
+
+	if bc.ValidChain(bc.Chain()) {
+		isValid = "TRUE"
 	}
+
+	w.Header().Add("Content-Type", "text/plain")
+	io.WriteString(w, isValid)
 }
 
-func (bcs *BlockchainServer) Valid(w http.ResponseWriter, req *http.Request) {
+func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, req *http.Request) {
 
-	switch req.Method {
-	case http.MethodGet:
+	bc := bcs.chain(req)
+	replaced := bc.ResolveConflicts()
 
-		isValid := "FALSE"
+	w.Header().Add("Content-Type", "text/plain")
 
-		bc := bcs.GetBlockchain()
+	if replaced {
+		io.WriteString(w, "Consensus SUCCESS")
+	} else {
+		io.WriteString(w, "Consensus FAIL")
+	}
+}
 
-		if bc.ValidChain(bc.Chain()) {
-			isValid = "TRUE"
-		}
+func (bcs *BlockchainServer) OpenAPI(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
 
-		w.Header().Add("Content-Type", "app")
-		io.WriteString(w, isValid)
+// Router serves the public API: the default chain at the root paths and
+// every chain, the default included, under /chains/{id}.
+func (bcs *BlockchainServer) Router() http.Handler {
+	return bcs.router(bcs.auth, false, bcs.limit)
+}
 
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
-	}
+// AdminRouter serves the public API plus the admin endpoints, for the
+// admin listener.
+func (bcs *BlockchainServer) AdminRouter() http.Handler {
+	return bcs.router(bcs.adminAuth, true)
 }
 
-func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, req *http.Request) {
+// router authenticates requests with auth, then runs middlewares.
+func (bcs *BlockchainServer) router(auth *utils.Authenticator, admin bool, middlewares ...utils.Middleware) http.Handler {
 
-	switch req.Method {
-	case http.MethodPut:
+	root := http.NewServeMux()
 
-		bc := bcs.GetBlockchain()
-		replaced := bc.ResolveConflicts()
+	for _, id := range bcs.chainIDs {
+		prefix := chainPrefix(id)
+		root.Handle(prefix+"/", http.StripPrefix(prefix, bcs.chainRouter(bcs.chains[id], admin)))
+	}
+	root.Handle("/", bcs.chainRouter(bcs.GetBlockchain(), admin))
+	root.Handle("GET /v1/chains", utils.Require(utils.ROLE_READ, bcs.ListChains))
+	root.Handle("GET /metrics", utils.Require(utils.ROLE_READ, utils.MetricsHandler(bcs.registry).ServeHTTP))
+
+	middlewares = append([]utils.Middleware{
+		utils.RequestID,
+		bcs.httpMetrics.Middleware,
+		utils.Logger,
+		utils.Recoverer,
+		utils.VerifyNodeSignatures(MAX_BODY_BYTES, bcs.replays),
+		auth.Middleware,
+	}, middlewares...)
+
+	return utils.Chain(root, append(middlewares, utils.Route)...)
+}
 
-		w.Header().Add("Content-Type", "application/json")
+// chainRouter serves the API of one chain, with the admin endpoints if
+// admin is set.
+func (bcs *BlockchainServer) chainRouter(bc *blockchain.Blockchain, admin bool) http.Handler {
 
-		if replaced {
-			io.WriteString(w, "Consensus SUCCESS")
-		} else {
-			io.WriteString(w, "Consensus FAIL")
+	mux := http.NewServeMux()
+	handle := func(pattern string, role utils.Role, h http.HandlerFunc) {
+		if role == utils.ROLE_PEER {
+			h = bcs.requireSigned(h)
 		}
+		mux.Handle(pattern, utils.Require(role, h))
+	}
+	// verifying caps a transaction body and checks its signature in a
+	// verify slot.
+	verifying := func(h http.HandlerFunc) http.HandlerFunc {
+		return utils.Chain(h, utils.MaxBodySize(MAX_TRANSACTION_BYTES), bcs.verify.Middleware).ServeHTTP
+	}
 
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+	mux.HandleFunc("GET /v1/openapi.yaml", bcs.OpenAPI)
+	mux.HandleFunc("GET /healthz", bcs.Healthz)
+	mux.HandleFunc("GET /readyz", bcs.Readyz)
+	handle("GET /info", utils.ROLE_READ, bcs.Info)
+
+	handle("GET /v1/chain", utils.ROLE_READ, bcs.GetChainData)
+	handle("GET /v1/chain/info", utils.ROLE_READ, bcs.ChainInfo)
+	handle("GET /v1/blocks/{id}", utils.ROLE_READ, bcs.GetBlock)
+	handle("GET /v1/transactions", utils.ROLE_READ, bcs.GetTransactions)
+	handle("GET /v1/transactions/{hash}", utils.ROLE_READ, bcs.GetTransaction)
+	handle("POST /v1/transactions", utils.ROLE_SUBMIT, verifying(bcs.PostTransaction))
+	handle("PUT /v1/transactions", utils.ROLE_PEER, verifying(bcs.PutTransaction))
+	handle("DELETE /v1/transactions", utils.ROLE_PEER, bcs.DeleteTransactions)
+	handle("GET /v1/amount", utils.ROLE_READ, bcs.Amount)
+
+	handle("GET /v1/valid", utils.ROLE_READ, bcs.Valid)
+	handle("PUT /v1/consensus", utils.ROLE_PEER, bcs.Consensus)
+
+	handle("GET /v1/peers", utils.ROLE_READ, bcs.GetPeers)
+	handle("POST /v1/handshake", utils.ROLE_READ, bcs.Handshake)
+
+	// Methods are checked against their own roles by RPC.
+	handle("POST /rpc", utils.ROLE_READ, bcs.RPC)
+
+	if admin {
+		handle("POST /v1/mine", utils.ROLE_ADMIN, bcs.Mine)
+		handle("POST /v1/mine/start", utils.ROLE_ADMIN, bcs.StartMine)
+		handle("POST /v1/mine/stop", utils.ROLE_ADMIN, bcs.StopMine)
+		handle("POST /v1/peers", utils.ROLE_ADMIN, bcs.AddPeer)
+		handle("DELETE /v1/peers/{address}", utils.ROLE_ADMIN, bcs.RemovePeer)
+		handle("GET /v1/bans", utils.ROLE_ADMIN, bcs.Bans)
+		handle("DELETE /v1/bans/{peer}", utils.ROLE_ADMIN, bcs.Unban)
 	}
+
+	// Streams are long-lived, so they bypass the timeout and body limits.
+	root := http.NewServeMux()
+	root.Handle("/", utils.Chain(mux,
+		utils.Timeout(REQUEST_TIMEOUT),
+		utils.MaxBodySize(MAX_BODY_BYTES),
+		utils.Route,
+	))
+	root.Handle("GET /v1/events", utils.Require(utils.ROLE_READ, bcs.Events))
+
+	return withChain(bc, utils.Route(root))
 }
 
-func (bcs *BlockchainServer) Run() {
+// Run loads every chain, serves the public HTTP and admin HTTP listeners,
+// and the gRPC listener if enabled, and runs the initial peer sync, after
+// which /readyz reports ready. It serves until ctx is cancelled or a server fails. It then
+// stops accepting connections, waits up to the shutdown timeout for
+// requests in flight, stops the miners and peer sync, and saves every
+// chain to the data directory it was loaded from.
+func (bcs *BlockchainServer) Run(ctx context.Context) error {
+
+	for _, id := range bcs.chainIDs {
+		if err := bcs.loadChain(id); err != nil {
+			return err
+		}
+	}
+	bcs.loaded.Store(true)
+
+	lis, err := net.Listen("tcp", bcs.cfg.API.Addr())
+	if err != nil {
+		return err
+	}
+	adminLis, err := net.Listen("tcp", bcs.cfg.API.AdminAddr())
+	if err != nil {
+		lis.Close()
+		return err
+	}
+	var grpcLis net.Listener
+	if bcs.cfg.API.GRPC {
+		if grpcLis, err = net.Listen("tcp", bcs.cfg.API.GRPCAddr()); err != nil {
+			lis.Close()
+			adminLis.Close()
+			return err
+		}
+	}
+
+	httpServer := &http.Server{Handler: bcs.Router(), TLSConfig: bcs.cfg.TLS.Server()}
+	httpServer.RegisterOnShutdown(func() {
+		bcs.shutdownOnce.Do(func() { close(bcs.shutdown) })
+	})
+	adminServer := &http.Server{Handler: bcs.AdminRouter(), TLSConfig: bcs.cfg.TLS.Server()}
+	adminServer.RegisterOnShutdown(func() {
+		bcs.shutdownOnce.Do(func() { close(bcs.shutdown) })
+	})
+	grpcServer := bcs.GRPCServer()
+
+	errc := make(chan error, 3)
+	go func() { errc <- serveHTTP(httpServer, lis) }()
+	go func() { errc <- serveHTTP(adminServer, adminLis) }()
+	grpcAddr := "off"
+	if grpcLis != nil {
+		grpcAddr = grpcLis.Addr().String()
+		go func() { errc <- grpcServer.Serve(grpcLis) }()
+	}
+
+	for _, id := range bcs.chainIDs {
+		bc := bcs.chains[id]
+		bc.Run()
+		if bcs.cfg.Miner.Enabled {
+			bc.StartMining()
+		}
+	}
+	bcs.synced.Store(true)
+
+	slog.Info("listening", "http", lis.Addr().String(), "admin", adminLis.Addr().String(), "grpc", grpcAddr,
+		"tls", bcs.cfg.TLS.Enabled(), "mtls", bcs.cfg.TLS.PeerCAFile != "", "chains", bcs.chainIDs)
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errc:
+	}
+
+	slog.Info("shutting down")
 
-	bcs.GetBlockchain().Run()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), bcs.cfg.API.ShutdownTimeout)
+	defer cancel()
 
-	http.HandleFunc("/", bcs.GetChainData)
-	http.HandleFunc("/transactions", bcs.Transactions)
-	http.HandleFunc("/mine", bcs.Mine)
-	http.HandleFunc("/mine/start", bcs.StartMine)
-	http.HandleFunc("/amount", bcs.Amount)
+	for _, s := range []*http.Server{httpServer, adminServer} {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("drain HTTP requests", "error", err)
+			s.Close()
+		}
+	}
 
-	http.HandleFunc("/valid", bcs.Valid)
-	http.HandleFunc("/consensus", bcs.Consensus)
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		slog.Warn("drain gRPC requests", "error", shutdownCtx.Err())
+		grpcServer.Stop()
+	}
 
-	hostURL := "0.0.0.0:" + strconv.Itoa(int(bcs.Port()))
+	errs := []error{serveErr}
+	for _, id := range bcs.chainIDs {
+		bcs.chains[id].Stop()
+		errs = append(errs, bcs.saveChain(id))
+	}
 
-	fmt.Println("Blockchain Server is live @:", hostURL)
-	log.Fatal(http.ListenAndServe(hostURL, nil))
+	return errors.Join(errs...)
+}
+
+// serveHTTP serves s on lis, over TLS if s has a TLS config.
+func serveHTTP(s *http.Server, lis net.Listener) error {
+	if s.TLSConfig != nil {
+		return s.ServeTLS(lis, "", "")
+	}
+	return s.Serve(lis)
 }
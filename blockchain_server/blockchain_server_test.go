@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig() *config.Node {
+	cfg := config.DefaultNode()
+	cfg.API.GRPCPort = 6000
+	cfg.Miner.Address = wallet.NewWallet().BlockchainAddress()
+	return cfg
+}
+
+func TestRouter(t *testing.T) {
+	//
+	h := NewBlockchainServer(testConfig()).Router()
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/chain", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"blocks"`)
+	assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+	//
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/chain", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	//
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/chain", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPostTransactionMalformedKey(t *testing.T) {
+	//
+	h := NewBlockchainServer(testConfig()).Router()
+	alice := wallet.NewWallet().BlockchainAddress()
+	bob := wallet.NewWallet().BlockchainAddress()
+	//
+	for _, key := range []string{"ab", "zz", strings.Repeat("01", 64)} {
+		body := `{"sender_blockchain_address":"` + alice + `","recipient_blockchain_address":"` + bob + `",` +
+			`"sender_public_key":"` + key + `","signature":"` + strings.Repeat("01", 64) + `","value":1}`
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/transactions", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, key)
+		assert.Contains(t, rec.Body.String(), "invalid public key")
+	}
+}
+
+func TestPostTransactionAddressChecks(t *testing.T) {
+	//
+	h := NewBlockchainServer(testConfig()).Router()
+	sender := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	//
+	post := func(from string, to string) *httptest.ResponseRecorder {
+		txn := wallet.NewWalletTransaction(sender.PrivateKey(), sender.PublicKey(), from, to, 1)
+		body := `{"sender_blockchain_address":"` + from + `","recipient_blockchain_address":"` + to + `",` +
+			`"sender_public_key":"` + sender.PublicKeyStr() + `","signature":"` + txn.GenerateSignature().String() + `","value":1}`
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/transactions", strings.NewReader(body)))
+		return rec
+	}
+	//
+	typo := []byte(recipient)
+	typo[len(typo)-1] ^= 1
+	//
+	rec := post(sender.BlockchainAddress(), string(typo))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "recipient_blockchain_address")
+	//
+	rec = post(recipient, sender.BlockchainAddress())
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "does not match public key")
+	//
+	rec = post(sender.BlockchainAddress(), recipient)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestEventsStream(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(testConfig())
+	srv := httptest.NewServer(bcs.Router())
+	defer srv.Close()
+	//
+	resp, err := http.Get(srv.URL + "/v1/events?address=alice&types=mempool_add")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	//
+	bc := bcs.GetBlockchain()
+	bc.AddTransaction(blockchain.MINING_SENDER, "bob", 1, nil, nil)
+	bc.AddTransaction(blockchain.MINING_SENDER, "alice", 2, nil, nil)
+	//
+	scanner := bufio.NewScanner(resp.Body)
+	assert.True(t, scanner.Scan())
+	assert.Equal(t, "event: mempool_add", scanner.Text())
+	assert.True(t, scanner.Scan())
+	assert.Contains(t, scanner.Text(), `"recipient_blockchain_address":"alice"`)
+}
+
+func TestMetrics(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(testConfig())
+	h := bcs.Router()
+	bc := bcs.GetBlockchain()
+	bc.AddPeer("127.0.0.1:1")
+	sender := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	//
+	post := func(from string) int {
+		txn := wallet.NewWalletTransaction(sender.PrivateKey(), sender.PublicKey(), from, recipient, 1)
+		body := `{"sender_blockchain_address":"` + from + `","recipient_blockchain_address":"` + recipient + `",` +
+			`"sender_public_key":"` + sender.PublicKeyStr() + `","signature":"` + txn.GenerateSignature().String() + `","value":1}`
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/transactions", strings.NewReader(body)))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, post(recipient))
+	assert.Equal(t, http.StatusCreated, post(sender.BlockchainAddress()))
+	assert.True(t, bc.Mining())
+	// The first interval is timed from block 1, as genesis has no timestamp.
+	txn := wallet.NewWalletTransaction(sender.PrivateKey(), sender.PublicKey(), sender.BlockchainAddress(), recipient, 2)
+	assert.True(t, bc.AddTransaction(sender.BlockchainAddress(), recipient, 2, sender.PublicKey(), txn.GenerateSignature()))
+	assert.True(t, bc.Mining())
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `blockchain_height{chain="main"} 2`)
+	assert.Contains(t, body, `blockchain_mempool_transactions{chain="main"} 0`)
+	assert.Contains(t, body, `blockchain_peers{chain="main"} 1`)
+	assert.Contains(t, body, `blockchain_sync_lag_blocks{chain="main"} 0`)
+	assert.Contains(t, body, `blockchain_block_interval_seconds_count{chain="main"} 1`)
+	assert.Contains(t, body, `blockchain_hashrate{chain="main"}`)
+	assert.Contains(t, body, `blockchain_rejected_transactions_total{chain="main",reason="address_mismatch"} 1`)
+	assert.Contains(t, body, `blockchain_peer_request_failures_total{chain="main",operation="relay_transaction",peer="127.0.0.1:1"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{code="201",route="POST /v1/transactions"} 1`)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+)
+
+// A node hosts one or more independent chains. Each is served under
+// /chains/{id}, and the first configured chain also at the root paths.
+
+type chainKey struct{}
+
+func chainPrefix(id string) string {
+	return "/chains/" + id
+}
+
+// withChain makes bc the chain that handlers below h operate on.
+func withChain(bc *blockchain.Blockchain, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), chainKey{}, bc)))
+	})
+}
+
+// chain returns the chain the request was routed to, or the default
+// chain for a handler called directly.
+func (bcs *BlockchainServer) chain(req *http.Request) *blockchain.Blockchain {
+	if bc, ok := req.Context().Value(chainKey{}).(*blockchain.Blockchain); ok {
+		return bc
+	}
+	return bcs.GetBlockchain()
+}
+
+// BlockchainByID returns a hosted chain; an empty id is the default chain.
+func (bcs *BlockchainServer) BlockchainByID(id string) (*blockchain.Blockchain, bool) {
+	if id == "" {
+		return bcs.GetBlockchain(), true
+	}
+	bc, ok := bcs.chains[id]
+	return bc, ok
+}
+
+func (bcs *BlockchainServer) ListChains(w http.ResponseWriter, req *http.Request) {
+
+	cr := &client.ChainsResponse{Chains: make([]client.ChainSummary, 0, len(bcs.chainIDs))}
+
+	for i, id := range bcs.chainIDs {
+		bc := bcs.chains[id]
+		cr.Chains = append(cr.Chains, client.ChainSummary{
+			ID:      id,
+			Path:    chainPrefix(id),
+			Height:  bc.Height(),
+			Default: i == 0,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, cr)
+}
+
+// chainPath is where a chain is saved: <data_dir>/<id>/chain.json.
+func (bcs *BlockchainServer) chainPath(id string) string {
+	return filepath.Join(bcs.cfg.Node.DataDir, id, blockchain.CHAIN_FILE)
+}
+
+func (bcs *BlockchainServer) loadChain(id string) error {
+
+	path := bcs.chainPath(id)
+	bc := bcs.chains[id]
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	switch err := bc.Load(path); {
+	case err == nil:
+		slog.Info("chain loaded", "chain", id, "height", bc.Height(), "path", path)
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("load chain %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (bcs *BlockchainServer) saveChain(id string) error {
+
+	path := bcs.chainPath(id)
+	bc := bcs.chains[id]
+
+	if err := bc.Save(path); err != nil {
+		return fmt.Errorf("save chain %s: %w", id, err)
+	}
+
+	slog.Info("chain saved", "chain", id, "height", bc.Height(), "path", path)
+	return nil
+}
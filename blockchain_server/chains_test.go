@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMultipleChains(t *testing.T) {
+	//
+	cfg := testConfig()
+	cfg.Chains = config.Chains{{ID: "main"}, {ID: "test", Difficulty: 1}}
+	bcs := NewBlockchainServer(cfg)
+	srv := httptest.NewServer(bcs.AdminRouter())
+	defer srv.Close()
+	ctx := context.Background()
+	//
+	root := client.New(srv.URL, client.WithRetries(0, 0))
+	test := client.New(srv.URL+"/chains/test", client.WithRetries(0, 0))
+	//
+	cr, err := root.Chains(ctx)
+	require.NoError(t, err)
+	require.Len(t, cr.Chains, 2)
+	assert.True(t, cr.Chains[0].Default)
+	assert.Equal(t, "/chains/test", cr.Chains[1].Path)
+	//
+	testChain, ok := bcs.BlockchainByID("test")
+	require.True(t, ok)
+	assert.Equal(t, 1, testChain.Params().Difficulty)
+	testChain.AddTransaction(blockchain.MINING_SENDER, wallet.NewWallet().BlockchainAddress(), 1, nil, nil)
+	require.NoError(t, test.Mine(ctx))
+	//
+	info, err := test.ChainInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "test", info.ChainID)
+	assert.Equal(t, 1, info.Height)
+	//
+	info, err = root.ChainInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "main", info.ChainID)
+	assert.Equal(t, 0, info.Height)
+	//
+	amt, err := test.Amount(ctx, cfg.Miner.Address)
+	require.NoError(t, err)
+	assert.Equal(t, float32(blockchain.MINING_REWARD), amt.Amount)
+	amt, err = root.Amount(ctx, cfg.Miner.Address)
+	require.NoError(t, err)
+	assert.Zero(t, amt.Amount)
+	//
+	_, err = client.New(srv.URL+"/chains/nope", client.WithRetries(0, 0)).ChainInfo(ctx)
+	assert.True(t, client.IsStatus(err, http.StatusNotFound))
+	//
+	node := dialNode(t, bcs)
+	pi, err := node.GetPeerInfo(metadata.AppendToOutgoingContext(ctx, CHAIN_ID_METADATA, "test"), &nodepb.PeerInfoRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), pi.GetHeight())
+	_, err = node.GetPeerInfo(metadata.AppendToOutgoingContext(ctx, CHAIN_ID_METADATA, "nope"), &nodepb.PeerInfoRequest{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestMiningNeedsAddress(t *testing.T) {
+	//
+	cfg := testConfig()
+	cfg.Miner.Address = ""
+	bcs := NewBlockchainServer(cfg)
+	h := bcs.AdminRouter()
+	//
+	bcs.GetBlockchain().AddTransaction(blockchain.MINING_SENDER, wallet.NewWallet().BlockchainAddress(), 1, nil, nil)
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/mine", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 0, bcs.GetBlockchain().Height())
+	//
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/mine/start", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no miner address")
+	assert.False(t, bcs.GetBlockchain().IsMining())
+}
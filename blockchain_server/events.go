@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+const EVENTS_KEEPALIVE = 15 * time.Second
+
+// Events streams chain and mempool events as Server-Sent Events. The
+// optional `address` query parameter limits the stream to events touching
+// that address and `types` takes a comma separated list of event types.
+// Streams end when the server shuts down.
+func (bcs *BlockchainServer) Events(w http.ResponseWriter, req *http.Request) {
+
+	address := req.URL.Query().Get("address")
+
+	types := make(map[blockchain.EventType]bool)
+	for _, t := range strings.Split(req.URL.Query().Get("types"), ",") {
+		if t != "" {
+			types[blockchain.EventType(t)] = true
+		}
+	}
+
+	events, cancel := bcs.chain(req).Subscribe()
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		utils.RequestLogger(req).Error("streaming unsupported", "error", err)
+		return
+	}
+
+	keepalive := time.NewTicker(EVENTS_KEEPALIVE)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+
+		case <-bcs.shutdown:
+			return
+
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if len(types) > 0 && !types[e.Type] {
+				continue
+			}
+			if address != "" && !e.Involves(address) {
+				continue
+			}
+
+			m, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, m)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/i101dev/blockchain-api/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type NodeService struct {
+	nodepb.UnimplementedNodeServer
+	bcs *BlockchainServer
+}
+
+func (bcs *BlockchainServer) GRPCServer() *grpc.Server {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(bcs.unaryAuth), grpc.StreamInterceptor(bcs.streamAuth)}
+	if c := bcs.cfg.TLS.Server(); c != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(c)))
+	}
+	s := grpc.NewServer(opts...)
+	nodepb.RegisterNodeServer(s, &NodeService{bcs: bcs})
+	return s
+}
+
+// CHAIN_ID_METADATA selects the chain a gRPC call is for. Calls without
+// it use the default chain.
+const CHAIN_ID_METADATA = "chain-id"
+
+func (ns *NodeService) chain(ctx context.Context) (*blockchain.Blockchain, error) {
+
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(CHAIN_ID_METADATA); len(v) > 0 {
+			id = v[0]
+		}
+	}
+
+	bc, ok := ns.bcs.BlockchainByID(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown chain %q", id)
+	}
+	return bc, nil
+}
+
+// ------------------------------------------------------------------
+
+func (ns *NodeService) GetPeerInfo(ctx context.Context, req *nodepb.PeerInfoRequest) (*nodepb.PeerInfo, error) {
+
+	bc, err := ns.chain(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genesis, _ := bc.BlockByHeight(0)
+	genesisHash := genesis.Hash()
+	tipHash := bc.LastBlock().Hash()
+
+	return &nodepb.PeerInfo{
+		Address:     fmt.Sprintf("%s:%d", utils.GetHost(), ns.bcs.Port()),
+		GenesisHash: genesisHash[:],
+		Height:      uint64(bc.Height()),
+		TipHash:     tipHash[:],
+		Peers:       bc.Peers(),
+	}, nil
+}
+
+func (ns *NodeService) AnnounceBlock(ctx context.Context, req *nodepb.BlockAnnouncement) (*nodepb.AnnounceResponse, error) {
+
+	if req.GetBlock() == nil {
+		return nil, status.Error(codes.InvalidArgument, "block required")
+	}
+
+	bc, err := ns.chain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := blockFromPB(req.GetBlock())
+	if err != nil {
+		ns.bcs.misbehavedGRPC(ctx, blockchain.SCORE_INVALID_BLOCK, "malformed block")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if !ns.bcs.verify.TryAcquire() {
+		return nil, status.Error(codes.ResourceExhausted, "server busy")
+	}
+	err = bc.VerifyBlock(b)
+	ns.bcs.verify.Release()
+	if err != nil {
+		ns.bcs.misbehavedGRPC(ctx, blockchain.SCORE_INVALID_BLOCK, "invalid block")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// The announced height is only trusted once the block extends our
+	// tip; a longer chain is observed when consensus fetches and validates it.
+	if bc.AddVerifiedBlock(b) {
+		bc.ObservePeerHeight(int(req.GetBlock().GetHeight()))
+		return &nodepb.AnnounceResponse{Accepted: true, Height: uint64(bc.Height())}, nil
+	}
+
+	// The announcer is ahead of us by more than one block, so pull its
+	// chain rather than rejecting outright.
+	if req.GetBlock().GetHeight() > uint64(bc.Height()) {
+		slog.Info("announced block ahead of tip", "chain", bc.ID(), "peer", req.GetFrom(), "height", req.GetBlock().GetHeight())
+		bc.ResolveConflictsInBackground()
+	}
+
+	return &nodepb.AnnounceResponse{Accepted: false, Height: uint64(bc.Height())}, nil
+}
+
+func (ns *NodeService) GetHeaders(req *nodepb.HeadersRequest, stream nodepb.Node_GetHeadersServer) error {
+
+	bc, err := ns.chain(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	sent := uint32(0)
+
+	for h := req.GetFromHeight(); h <= uint64(bc.Height()); h++ {
+
+		if req.GetLimit() > 0 && sent >= req.GetLimit() {
+			break
+		}
+
+		b, ok := bc.BlockByHeight(int(h))
+		if !ok {
+			break
+		}
+
+		hash := b.Hash()
+		previousHash := b.PreviousHash()
+
+		err := stream.Send(&nodepb.BlockHeader{
+			Height:           h,
+			Hash:             hash[:],
+			PreviousHash:     previousHash[:],
+			Timestamp:        b.Timestamp(),
+			Nonce:            int64(b.Nonce()),
+			TransactionCount: uint32(len(b.Transactions())),
+		})
+		if err != nil {
+			return err
+		}
+
+		sent++
+	}
+
+	return nil
+}
+
+func (ns *NodeService) GetBlocks(req *nodepb.BlocksRequest, stream nodepb.Node_GetBlocksServer) error {
+
+	bc, err := ns.chain(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	to := req.GetToHeight()
+	if to == 0 || to > uint64(bc.Height()) {
+		to = uint64(bc.Height())
+	}
+
+	for h := req.GetFromHeight(); h <= to; h++ {
+
+		b, ok := bc.BlockByHeight(int(h))
+		if !ok {
+			break
+		}
+
+		if err := stream.Send(blockToPB(h, b)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ns *NodeService) RelayTransaction(ctx context.Context, req *nodepb.Transaction) (*nodepb.RelayResponse, error) {
+
+	bc, err := ns.chain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := transactionFromPB(req)
+	tr := txn.Request()
+
+	if err := tr.Validate(); err != nil {
+		bc.RejectTransaction(err)
+		ns.bcs.misbehavedGRPC(ctx, blockchain.SCORE_INVALID_TRANSACTION, "invalid transaction")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	witness, err := blockchain.WitnessFromRequest(tr)
+	if err != nil {
+		bc.RejectTransaction(err)
+		ns.bcs.misbehavedGRPC(ctx, blockchain.SCORE_INVALID_TRANSACTION, "invalid transaction")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if !ns.bcs.verify.TryAcquire() {
+		return nil, status.Error(codes.ResourceExhausted, "server busy")
+	}
+	isAdded := bc.AddWitnessedTransaction(txn.SenderBlockchainAddress(), txn.RecipientBlockchainAddress(), txn.Value(), witness)
+	ns.bcs.verify.Release()
+
+	if !isAdded {
+		ns.bcs.misbehavedGRPC(ctx, blockchain.SCORE_INVALID_TRANSACTION, "invalid transaction")
+	}
+
+	return &nodepb.RelayResponse{Accepted: isAdded}, nil
+}
+
+// ------------------------------------------------------------------
+
+func blockToPB(height uint64, b *blockchain.Block) *nodepb.Block {
+
+	hash := b.Hash()
+	previousHash := b.PreviousHash()
+
+	transactions := make([]*nodepb.Transaction, 0, len(b.Transactions()))
+	for _, t := range b.Transactions() {
+		transactions = append(transactions, transactionToPB(t))
+	}
+
+	return &nodepb.Block{
+		Height:       height,
+		Hash:         hash[:],
+		PreviousHash: previousHash[:],
+		Timestamp:    b.Timestamp(),
+		Nonce:        int64(b.Nonce()),
+		Transactions: transactions,
+	}
+}
+
+func blockFromPB(pb *nodepb.Block) (*blockchain.Block, error) {
+
+	if len(pb.GetPreviousHash()) != 32 {
+		return nil, fmt.Errorf("previous hash must be 32 bytes")
+	}
+
+	var previousHash [32]byte
+	copy(previousHash[:], pb.GetPreviousHash())
+
+	transactions := make([]*blockchain.Transaction, 0, len(pb.GetTransactions()))
+	for _, t := range pb.GetTransactions() {
+		transactions = append(transactions, transactionFromPB(t))
+	}
+
+	return blockchain.RestoreBlock(pb.GetTimestamp(), int(pb.GetNonce()), previousHash, transactions), nil
+}
+
+func transactionToPB(t *blockchain.Transaction) *nodepb.Transaction {
+
+	pb := &nodepb.Transaction{
+		SenderBlockchainAddress:    t.SenderBlockchainAddress(),
+		RecipientBlockchainAddress: t.RecipientBlockchainAddress(),
+		Value:                      t.Value(),
+	}
+
+	if w := t.Witness(); w != nil {
+		pb.SenderPublicKey = w.PublicKey
+		pb.Signature = w.Signature
+		pb.Threshold = uint32(w.Threshold)
+		pb.PublicKeys = w.PublicKeys
+		pb.Signatures = w.Signatures
+	}
+
+	return pb
+}
+
+func transactionFromPB(pb *nodepb.Transaction) *blockchain.Transaction {
+
+	var witness *blockchain.Witness
+	if pb.GetSenderPublicKey() != "" || pb.GetSignature() != "" || len(pb.GetPublicKeys()) > 0 {
+		witness = &blockchain.Witness{
+			PublicKey:  pb.GetSenderPublicKey(),
+			Signature:  pb.GetSignature(),
+			Threshold:  int(pb.GetThreshold()),
+			PublicKeys: pb.GetPublicKeys(),
+			Signatures: pb.GetSignatures(),
+		}
+	}
+
+	return blockchain.NewWitnessedTransaction(pb.GetSenderBlockchainAddress(), pb.GetRecipientBlockchainAddress(), pb.GetValue(), witness)
+}
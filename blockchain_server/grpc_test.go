@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialNode(t *testing.T, bcs *BlockchainServer) nodepb.NodeClient {
+
+	lis := bufconn.Listen(1 << 20)
+	srv := bcs.GRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return nodepb.NewNodeClient(conn)
+}
+
+func TestGRPCSync(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(testConfig())
+	nc := dialNode(t, bcs)
+	ctx := context.Background()
+	//
+	bc := bcs.GetBlockchain()
+	bc.AddTransaction(blockchain.MINING_SENDER, "alice", 10, nil, nil)
+	bc.CreateBlock(bc.ProofOfWork(), bc.LastBlock().Hash())
+	//
+	info, err := nc.GetPeerInfo(ctx, &nodepb.PeerInfoRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(bc.Height()), info.GetHeight())
+	//
+	headers, err := nc.GetHeaders(ctx, &nodepb.HeadersRequest{FromHeight: 0})
+	assert.NoError(t, err)
+	count := 0
+	for {
+		_, err := headers.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, bc.Height()+1, count)
+	//
+	blocks, err := nc.GetBlocks(ctx, &nodepb.BlocksRequest{FromHeight: uint64(bc.Height())})
+	assert.NoError(t, err)
+	pb, err := blocks.Recv()
+	assert.NoError(t, err)
+	b, err := blockFromPB(pb)
+	assert.NoError(t, err)
+	tip := bc.LastBlock().Hash()
+	assert.Equal(t, tip[:], pb.GetHash())
+	assert.Equal(t, tip, b.Hash())
+}
+
+func TestGRPCAnnounceBlock(t *testing.T) {
+	//
+	target := NewBlockchainServer(authConfig())
+	nc := dialNode(t, target)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+PEER_TOKEN)
+	bc := target.GetBlockchain()
+	//
+	transactions := []*blockchain.Transaction{blockchain.NewTransaction(blockchain.MINING_SENDER, "alice", blockchain.MINING_REWARD)}
+	previousHash := bc.LastBlock().Hash()
+	nonce := 0
+	for !bc.ValidProof(nonce, previousHash, transactions, blockchain.MINING_DIFFICULTY) {
+		nonce++
+	}
+	b := blockchain.NewBlock(nonce, previousHash, transactions)
+	height := uint64(bc.Height() + 1)
+	//
+	resp, err := nc.AnnounceBlock(ctx, &nodepb.BlockAnnouncement{Block: blockToPB(height, b), From: "test"})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetAccepted())
+	assert.Equal(t, height, resp.GetHeight())
+	assert.Equal(t, b.Hash(), bc.LastBlock().Hash())
+	//
+	resp, err = nc.AnnounceBlock(ctx, &nodepb.BlockAnnouncement{Block: blockToPB(height, b), From: "test"})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetAccepted())
+	//
+	resp, err = nc.AnnounceBlock(ctx, &nodepb.BlockAnnouncement{Block: blockToPB(1000, b), From: "test"})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetAccepted())
+	assert.Equal(t, 0, bc.SyncLag())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/i101dev/blockchain-api/client"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// Healthz reports that the process is serving. It never checks
+// dependencies, so a node that is still syncing is alive.
+func (bcs *BlockchainServer) Healthz(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, &client.HealthResponse{Message: "ok"})
+}
+
+// Readyz reports whether the node should receive traffic: every chain
+// has been loaded from storage, the initial peer sync has finished, and
+// the node is not shutting down.
+func (bcs *BlockchainServer) Readyz(w http.ResponseWriter, req *http.Request) {
+
+	checks := bcs.readiness()
+
+	if !isReady(checks) {
+		writeJSON(w, http.StatusServiceUnavailable, &client.HealthResponse{Message: "not ready", Checks: checks})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &client.HealthResponse{Message: "ready", Checks: checks})
+}
+
+// readiness maps each readiness check to "ok" or the reason it fails.
+func (bcs *BlockchainServer) readiness() map[string]string {
+
+	checks := map[string]string{
+		"storage":  "ok",
+		"sync":     "ok",
+		"shutdown": "ok",
+	}
+
+	if !bcs.loaded.Load() {
+		checks["storage"] = "loading"
+	}
+	if !bcs.synced.Load() {
+		checks["sync"] = "initial sync in progress"
+	}
+	select {
+	case <-bcs.shutdown:
+		checks["shutdown"] = "shutting down"
+	default:
+	}
+
+	return checks
+}
+
+func isReady(checks map[string]string) bool {
+	for _, v := range checks {
+		if v != "ok" {
+			return false
+		}
+	}
+	return true
+}
+
+// Info describes the node and the chain of the request.
+func (bcs *BlockchainServer) Info(w http.ResponseWriter, req *http.Request) {
+
+	bc := bcs.chain(req)
+	height := bc.Height()
+	lag := bc.SyncLag()
+
+	progress := 1.0
+	if lag > 0 {
+		progress = float64(height) / float64(height+lag)
+	}
+
+	writeJSON(w, http.StatusOK, &client.NodeInfoResponse{
+		Version:           version,
+		Node:              bcs.cfg.Node.Name,
+		NodeID:            bcs.identity.ID(),
+		ChainInfoResponse: *chainInfo(bc),
+		Ready:             isReady(bcs.readiness()),
+		SyncLag:           lag,
+		SyncProgress:      progress,
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	//
+	cfg := testConfig()
+	cfg.Chains = append(cfg.Chains, cfg.Chains[0])
+	cfg.Chains[1].ID = "test"
+	bcs := NewBlockchainServer(cfg)
+	srv := httptest.NewServer(bcs.Router())
+	defer srv.Close()
+	c := client.New(srv.URL, client.WithRetries(0, 0))
+	ctx := context.Background()
+	//
+	resp, err := http.Get(srv.URL + "/healthz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	//
+	err = c.Ready(ctx)
+	assert.True(t, client.IsStatus(err, http.StatusServiceUnavailable))
+	info, err := c.Info(ctx)
+	require.NoError(t, err)
+	assert.False(t, info.Ready)
+	//
+	bcs.loaded.Store(true)
+	bcs.synced.Store(true)
+	require.NoError(t, c.Ready(ctx))
+	//
+	info, err = c.Info(ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Ready)
+	assert.Equal(t, version, info.Version)
+	assert.Equal(t, cfg.Node.Name, info.Node)
+	assert.Equal(t, "main", info.ChainID)
+	assert.Equal(t, 0, info.Height)
+	assert.NotEmpty(t, info.GenesisHash)
+	assert.Equal(t, info.GenesisHash, info.TipHash)
+	assert.Equal(t, 1.0, info.SyncProgress)
+	//
+	bcs.chains["test"].ObservePeerHeight(3)
+	tc := client.New(srv.URL+"/chains/test", client.WithRetries(0, 0))
+	require.NoError(t, tc.Ready(ctx))
+	info, err = tc.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "test", info.ChainID)
+	assert.Equal(t, 3, info.SyncLag)
+	assert.Equal(t, 0.0, info.SyncProgress)
+	//
+	close(bcs.shutdown)
+	err = c.Ready(ctx)
+	assert.True(t, client.IsStatus(err, http.StatusServiceUnavailable))
+}
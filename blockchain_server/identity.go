@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// The node signs its requests to peers with its identity key, and checks
+// the signatures of requests it receives: a signed request is attributed
+// to the signing node, which is banned by node ID if it misbehaves.
+
+type Option func(*BlockchainServer)
+
+// WithIdentity sets the identity the node signs with. Without one the node
+// generates an identity that lasts until it stops. Nil is ignored.
+func WithIdentity(id *utils.NodeIdentity) Option {
+	return func(bcs *BlockchainServer) {
+		bcs.identity = id
+	}
+}
+
+// requireSigned refuses unsigned requests when the node requires peer
+// calls to be signed.
+func (bcs *BlockchainServer) requireSigned(next http.HandlerFunc) http.HandlerFunc {
+
+	if !bcs.cfg.Auth.RequirePeerSignatures {
+		return next
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		if utils.NodeIDFromContext(req.Context()) == "" {
+			writeStatus(w, http.StatusUnauthorized, "peer calls must be signed")
+			return
+		}
+		next(w, req)
+	}
+}
+
+// trustedNode reports whether id may act as a peer when it signs a
+// request: it is listed in auth.trusted_nodes, or pinned by a handshake
+// this node made with a peer it chose to call, whether static, added by
+// an admin or discovered in the configured scan ranges.
+func (bcs *BlockchainServer) trustedNode(id string) bool {
+	if slices.Contains(bcs.cfg.Auth.TrustedNodes, id) {
+		return true
+	}
+	for _, bc := range bcs.chains {
+		if bc.HasPeerID(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handshake checks the hello of a peer and answers with this node's.
+func (bcs *BlockchainServer) Handshake(w http.ResponseWriter, req *http.Request) {
+
+	var theirs client.Hello
+	if err := json.NewDecoder(req.Body).Decode(&theirs); err != nil {
+		writeStatus(w, http.StatusBadRequest, "malformed hello")
+		return
+	}
+
+	bc := bcs.chain(req)
+	if err := bc.CheckHello(&theirs); err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, blockchain.ErrSelfPeer) || errors.Is(err, blockchain.ErrProtocol) || errors.Is(err, blockchain.ErrGenesis) {
+			code = http.StatusConflict
+		}
+		utils.RequestLogger(req).Debug("handshake refused", "node_id", theirs.NodeID, "error", err)
+		writeStatus(w, code, err.Error())
+		return
+	}
+
+	mine := bc.Hello(theirs.Nonce)
+	if err := mine.Sign(bcs.identity); err != nil {
+		writeStatus(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mine)
+}
+
+// ------------------------------------------------------------------
+
+// verifyGRPC checks the signature metadata of a unary call, if any, over
+// the deterministic encoding of its request, refuses replayed signatures
+// and records the signer's node ID in the context.
+func (bcs *BlockchainServer) verifyGRPC(ctx context.Context, method string, req any) (context.Context, error) {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	ms := &utils.MessageSignature{
+		Key:       get(utils.NODE_KEY_HEADER),
+		Timestamp: get(utils.NODE_TIMESTAMP_HEADER),
+		Nonce:     get(utils.NODE_NONCE_HEADER),
+		Signature: get(utils.NODE_SIGNATURE_HEADER),
+	}
+	if *ms == (utils.MessageSignature{}) {
+		return ctx, nil
+	}
+
+	m, ok := req.(proto.Message)
+	if !ok {
+		return ctx, status.Error(codes.Internal, "request is not a message")
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return ctx, status.Error(codes.Internal, err.Error())
+	}
+
+	now := time.Now()
+	id, err := ms.Verify(http.MethodPost, method, body, now)
+	if err == nil {
+		err = bcs.replays.Check(ms, now)
+	}
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return utils.WithNodeID(ctx, id), nil
+}
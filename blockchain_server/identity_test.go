@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// serveSigned serves a request signed by id.
+func serveSigned(h http.Handler, id *utils.NodeIdentity, method string, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	id.SignRequest(req, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandshakeEndpoint(t *testing.T) {
+	//
+	node := NewBlockchainServer(testConfig())
+	srv := httptest.NewServer(node.Router())
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+	//
+	peer := NewBlockchainServer(testConfig())
+	id, err := peer.GetBlockchain().Handshake(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, node.identity.ID(), id)
+	//
+	_, err = node.GetBlockchain().Handshake(addr)
+	assert.True(t, client.IsStatus(err, http.StatusConflict))
+	//
+	info, err := client.New(addr).Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, node.identity.ID(), info.NodeID)
+}
+
+func TestHandshakePinsPeer(t *testing.T) {
+	//
+	node := NewBlockchainServer(testConfig())
+	srv := httptest.NewServer(node.Router())
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+	//
+	caller := NewBlockchainServer(testConfig())
+	public := caller.Router()
+	assert.Equal(t, http.StatusUnauthorized, serveSigned(public, node.identity, "PUT", "/v1/consensus").Code)
+	//
+	caller.GetBlockchain().AddPeer(addr)
+	_, err := caller.GetBlockchain().Handshake(addr)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, serveSigned(public, node.identity, "PUT", "/v1/consensus").Code)
+	//
+	caller.GetBlockchain().RemovePeer(addr)
+	assert.Equal(t, http.StatusUnauthorized, serveSigned(public, node.identity, "PUT", "/v1/consensus").Code)
+}
+
+func TestRequirePeerSignatures(t *testing.T) {
+	//
+	peer, err := utils.GenerateNodeIdentity()
+	require.NoError(t, err)
+	cfg := testConfig()
+	cfg.Auth.RequirePeerSignatures = true
+	cfg.Auth.TrustedNodes = []string{peer.ID()}
+	bcs := NewBlockchainServer(cfg)
+	public := bcs.Router()
+	//
+	assert.Equal(t, http.StatusUnauthorized, serve(public, "PUT", "/v1/consensus", "").Code)
+	assert.Equal(t, http.StatusOK, serveSigned(public, peer, "PUT", "/v1/consensus").Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", "").Code)
+	//
+	// A trusted node is banned by its node ID alone, so other callers from
+	// its IP are not.
+	for range blockchain.BAN_SCORE / blockchain.SCORE_INVALID_TRANSACTION {
+		assert.Equal(t, http.StatusBadRequest, serveSigned(public, peer, "PUT", "/v1/transactions").Code)
+	}
+	assert.Equal(t, http.StatusForbidden, serveSigned(public, peer, "GET", "/v1/chain").Code)
+	fresh, err := utils.GenerateNodeIdentity()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, serveSigned(public, fresh, "GET", "/v1/chain").Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", "").Code)
+	rec := serve(bcs.AdminRouter(), "GET", "/v1/bans", "")
+	assert.Contains(t, rec.Body.String(), `"peer":"`+peer.ID()+`"`)
+	assert.NotContains(t, rec.Body.String(), `"peer":"192.0.2.1"`)
+	//
+	assert.Equal(t, http.StatusOK, serve(bcs.AdminRouter(), "DELETE", "/v1/bans/"+peer.ID(), "").Code)
+	assert.Equal(t, http.StatusOK, serveSigned(public, peer, "GET", "/v1/chain").Code)
+}
+
+func TestRequirePeerSignaturesGRPC(t *testing.T) {
+	//
+	peer, err := utils.GenerateNodeIdentity()
+	require.NoError(t, err)
+	cfg := testConfig()
+	cfg.Auth.RequirePeerSignatures = true
+	cfg.Auth.TrustedNodes = []string{peer.ID()}
+	nc := dialNode(t, NewBlockchainServer(cfg))
+	req := &nodepb.Transaction{}
+	//
+	_, err = nc.RelayTransaction(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	//
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	require.NoError(t, err)
+	ms, err := peer.SignMessage(http.MethodPost, nodepb.Node_RelayTransaction_FullMethodName, body, time.Now())
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		utils.NODE_KEY_HEADER, ms.Key, utils.NODE_TIMESTAMP_HEADER, ms.Timestamp,
+		utils.NODE_NONCE_HEADER, ms.Nonce, utils.NODE_SIGNATURE_HEADER, ms.Signature)
+	_, err = nc.RelayTransaction(ctx, req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = nc.RelayTransaction(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
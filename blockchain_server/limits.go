@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The public listeners refuse banned peers and rate limit every caller
+// but admins. A caller signing as a trusted node is scored and banned by
+// its node ID, and any other by IP, except loopback and configured peer
+// addresses: nodes sharing a host share those, so one misbehaving node
+// must not ban its siblings. Rate limits stay per IP. Signature checks
+// run in a bounded number of slots, and ResolveConflicts runs once at a
+// time per chain.
+
+const (
+	// MAX_TRANSACTION_BYTES caps transaction bodies well below
+	// MAX_BODY_BYTES; a multisig witness takes a few KiB.
+	MAX_TRANSACTION_BYTES = 64 << 10
+	// MAX_RPC_BATCH caps the calls in one JSON-RPC batch.
+	MAX_RPC_BATCH = 100
+)
+
+// limiterFor picks the rate limit bucket of a caller: peers by IP against
+// the peer rate, API keys by name and anonymous callers by IP against the
+// client rate. Admins are not limited.
+func (bcs *BlockchainServer) limiterFor(p *utils.Principal, ip string) (*utils.RateLimiter, string) {
+	switch {
+	case p.Has(utils.ROLE_ADMIN):
+		return nil, ""
+	case p.Name != "" && p.Has(utils.ROLE_PEER):
+		return bcs.peerLimiter, ip
+	case p.Name != "":
+		return bcs.limiter, "key:" + p.Name
+	}
+	return bcs.limiter, ip
+}
+
+// limit refuses banned callers with 403 and callers over their rate with
+// 429. It runs after authentication.
+func (bcs *BlockchainServer) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		ip := utils.ClientIP(req)
+		if bcs.banned(ip, utils.NodeIDFromContext(req.Context())) {
+			writeStatus(w, http.StatusForbidden, "banned")
+			return
+		}
+
+		rl, key := bcs.limiterFor(utils.PrincipalFromContext(req.Context()), ip)
+		if !rl.Allow(key) {
+			utils.TooManyRequests(w, rl.RetryAfter())
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// misbehaved scores the caller of a peer endpoint.
+func (bcs *BlockchainServer) misbehaved(req *http.Request, points int, reason string) {
+	bcs.scoreCaller(utils.ClientIP(req), utils.NodeIDFromContext(req.Context()), points, reason)
+}
+
+// scoreCaller scores a caller under its ban key. A caller without one
+// is only logged.
+func (bcs *BlockchainServer) scoreCaller(ip string, nodeID string, points int, reason string) {
+	key := bcs.banKey(ip, nodeID)
+	if key == "" {
+		slog.Info("caller misbehaved", "ip", ip, "reason", reason)
+		return
+	}
+	bcs.peerScores.Misbehaved(key, points, reason)
+}
+
+// banned reports whether a caller is banned under its ban key.
+func (bcs *BlockchainServer) banned(ip string, nodeID string) bool {
+	key := bcs.banKey(ip, nodeID)
+	return key != "" && bcs.peerScores.Banned(key)
+}
+
+// banKey is what a caller is scored and banned by: the node ID it signed
+// with if that node is trusted, else its IP unless that is shared.
+func (bcs *BlockchainServer) banKey(ip string, nodeID string) string {
+	switch {
+	case nodeID != "" && bcs.trustedNode(nodeID):
+		return nodeID
+	case bcs.sharedIP(ip):
+		return ""
+	}
+	return ip
+}
+
+// sharedIP reports whether ip is loopback or the host of a configured
+// peer, which other nodes may share.
+func (bcs *BlockchainServer) sharedIP(ip string) bool {
+	if addr := net.ParseIP(ip); addr != nil && addr.IsLoopback() {
+		return true
+	}
+	for _, p := range bcs.cfg.Network.Peers {
+		if host, _, err := net.SplitHostPort(p); err == nil && host == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------------
+
+// grpcPeerIP is the IP of a gRPC caller.
+func grpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// limitGRPC applies the HTTP bans and rate limits to a gRPC call by p.
+func (bcs *BlockchainServer) limitGRPC(ctx context.Context, p *utils.Principal) error {
+
+	ip := grpcPeerIP(ctx)
+	if bcs.banned(ip, utils.NodeIDFromContext(ctx)) {
+		return status.Error(codes.PermissionDenied, "banned")
+	}
+
+	rl, key := bcs.limiterFor(p, ip)
+	if !rl.Allow(key) {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return nil
+}
+
+func (bcs *BlockchainServer) misbehavedGRPC(ctx context.Context, points int, reason string) {
+	bcs.scoreCaller(grpcPeerIP(ctx), utils.NodeIDFromContext(ctx), points, reason)
+}
+
+// ------------------------------------------------------------------
+
+func (bcs *BlockchainServer) Bans(w http.ResponseWriter, req *http.Request) {
+
+	br := &client.BansResponse{Bans: make([]client.Ban, 0)}
+	for _, b := range bcs.peerScores.Bans() {
+		br.Bans = append(br.Bans, client.Ban{Peer: b.Peer, Until: b.Until, Reason: b.Reason})
+	}
+
+	writeJSON(w, http.StatusOK, br)
+}
+
+func (bcs *BlockchainServer) Unban(w http.ResponseWriter, req *http.Request) {
+
+	if !bcs.peerScores.Unban(req.PathValue("peer")) {
+		writeStatus(w, http.StatusNotFound, "peer not banned")
+		return
+	}
+
+	writeStatus(w, http.StatusOK, "success")
+}
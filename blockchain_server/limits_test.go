@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimit(t *testing.T) {
+	//
+	cfg := authConfig()
+	cfg.Limits.Rate = 1
+	cfg.Limits.Burst = 2
+	bcs := NewBlockchainServer(cfg)
+	public := bcs.Router()
+	//
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", "").Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", "").Code)
+	rec := serve(public, "GET", "/v1/chain", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	//
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", FEED_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", PEER_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", OPS_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(bcs.AdminRouter(), "GET", "/v1/chain", "").Code)
+}
+
+func TestBanInvalidRelays(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(authConfig())
+	public := bcs.Router()
+	admin := bcs.AdminRouter()
+	//
+	for range blockchain.BAN_SCORE / blockchain.SCORE_INVALID_TRANSACTION {
+		assert.Equal(t, http.StatusBadRequest, serve(public, "PUT", "/v1/transactions", PEER_TOKEN).Code)
+	}
+	rec := serve(public, "GET", "/v1/chain", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "banned")
+	//
+	rec = serve(admin, "GET", "/v1/bans", OPS_TOKEN)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"peer":"192.0.2.1"`)
+	assert.Contains(t, rec.Body.String(), `"reason":"malformed transaction"`)
+	//
+	assert.Equal(t, http.StatusOK, serve(admin, "DELETE", "/v1/bans/192.0.2.1", OPS_TOKEN).Code)
+	assert.Equal(t, http.StatusNotFound, serve(admin, "DELETE", "/v1/bans/192.0.2.1", OPS_TOKEN).Code)
+	assert.Equal(t, http.StatusOK, serve(public, "GET", "/v1/chain", "").Code)
+}
+
+func TestSharedIPsAreNotBanned(t *testing.T) {
+	//
+	cfg := authConfig()
+	cfg.Network.Peers = []string{"192.0.2.7:5001"}
+	bcs := NewBlockchainServer(cfg)
+	public := bcs.Router()
+	//
+	for _, addr := range []string{"127.0.0.1:40000", "[::1]:40000", "192.0.2.7:40000"} {
+		serveFrom := func(method string, path string, token string) int {
+			req := httptest.NewRequest(method, path, nil)
+			req.RemoteAddr = addr
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			public.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		for range blockchain.BAN_SCORE / blockchain.SCORE_INVALID_TRANSACTION {
+			assert.Equal(t, http.StatusBadRequest, serveFrom("PUT", "/v1/transactions", PEER_TOKEN), addr)
+		}
+		assert.Equal(t, http.StatusOK, serveFrom("GET", "/v1/chain", ""), addr)
+	}
+	assert.Empty(t, bcs.peerScores.Bans())
+}
+
+func TestRPCBatchLimit(t *testing.T) {
+	//
+	h := NewBlockchainServer(testConfig()).Router()
+	call := `{"jsonrpc":"2.0","method":"getPeers","id":1}`
+	//
+	rec := rpcCall(h, "["+strings.Repeat(call+",", MAX_RPC_BATCH)+call+"]")
+	assert.Contains(t, rec.Body.String(), `"code":-32600`)
+	assert.Contains(t, rec.Body.String(), "batch larger than 100 calls")
+}
+
+func TestGRPCBansInvalidBlocks(t *testing.T) {
+	//
+	bcs := NewBlockchainServer(authConfig())
+	nc := dialNode(t, bcs)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+PEER_TOKEN)
+	bc := bcs.GetBlockchain()
+	//
+	b := blockchain.NewBlock(0, bc.LastBlock().Hash(), nil)
+	for nonce := 1; bc.ValidProof(b.Nonce(), b.PreviousHash(), nil, blockchain.MINING_DIFFICULTY); nonce++ {
+		b = blockchain.NewBlock(nonce, bc.LastBlock().Hash(), nil)
+	}
+	announcement := &nodepb.BlockAnnouncement{Block: blockToPB(1, b), From: "test"}
+	//
+	_, err := nc.AnnounceBlock(ctx, announcement)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = nc.AnnounceBlock(ctx, announcement)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	//
+	_, err = nc.GetPeerInfo(ctx, &nodepb.PeerInfoRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Len(t, bcs.peerScores.Bans(), 1)
+}
@@ -1,23 +1,56 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
-)
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
-func init() {
-	log.SetPrefix("Blockchain: ")
-}
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/utils"
+)
 
 func main() {
 
-	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server")
-	flag.Parse()
+	cfg, err := config.LoadNode(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+
+	logger, err := cfg.Log.Logger(os.Stderr)
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger.With("service", "blockchain_server", "node", cfg.Node.Name))
+
+	if err := os.MkdirAll(cfg.Node.DataDir, 0700); err != nil {
+		slog.Error("create data dir", "path", cfg.Node.DataDir, "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// fmt.Println(port)
-	// fmt.Println(*	port)
+	identity, err := utils.LoadNodeIdentity(cfg.KeyFile())
+	if err != nil {
+		slog.Error("load node identity", "path", cfg.KeyFile(), "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(slog.Default().With("node_id", identity.ID()))
 
-	app := NewBlockchainServer(uint16(*port))
+	app := NewBlockchainServer(cfg, WithIdentity(identity))
 
-	app.Run()
+	if err := app.Run(ctx); err != nil {
+		slog.Error("stopped", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("stopped")
 }
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+const (
+	RPC_PARSE_ERROR      = -32700
+	RPC_INVALID_REQUEST  = -32600
+	RPC_METHOD_NOT_FOUND = -32601
+	RPC_INVALID_PARAMS   = -32602
+	RPC_INTERNAL_ERROR   = -32603
+	RPC_TX_REJECTED      = -32000
+	RPC_FORBIDDEN        = -32001
+	RPC_BUSY             = -32002
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcMethod lists its parameter names so positional params can be bound
+// the same way as named ones, the role a caller needs, and whether it
+// checks a signature and so needs a verify slot.
+type rpcMethod struct {
+	params   []string
+	role     utils.Role
+	verifies bool
+	call     func(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError)
+}
+
+var rpcMethods = map[string]rpcMethod{
+	"getBlockByHeight":   {[]string{"height"}, utils.ROLE_READ, false, rpcGetBlockByHeight},
+	"getBalance":         {[]string{"address"}, utils.ROLE_READ, false, rpcGetBalance},
+	"sendRawTransaction": {[]string{"transaction"}, utils.ROLE_SUBMIT, true, rpcSendRawTransaction},
+	"getMempool":         {nil, utils.ROLE_READ, false, rpcGetMempool},
+	"getPeers":           {nil, utils.ROLE_READ, false, rpcGetPeers},
+	"getChainInfo":       {nil, utils.ROLE_READ, false, rpcGetChainInfo},
+}
+
+// ------------------------------------------------------------------
+
+func (bcs *BlockchainServer) RPC(w http.ResponseWriter, req *http.Request) {
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		utils.RequestLogger(req).Info("unreadable RPC body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("unreadable body")))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	body = bytes.TrimSpace(body)
+	bc := bcs.chain(req)
+	p := utils.PrincipalFromContext(req.Context())
+
+	if len(body) > 0 && body[0] == '[' {
+
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeRPC(w, rpcFail(nil, RPC_PARSE_ERROR, "parse error"))
+			return
+		}
+		if len(batch) == 0 {
+			writeRPC(w, rpcFail(nil, RPC_INVALID_REQUEST, "empty batch"))
+			return
+		}
+		if len(batch) > MAX_RPC_BATCH {
+			writeRPC(w, rpcFail(nil, RPC_INVALID_REQUEST, fmt.Sprintf("batch larger than %d calls", MAX_RPC_BATCH)))
+			return
+		}
+
+		responses := make([]*rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			if resp := bcs.handleRPC(bc, p, raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeRPC(w, responses)
+		return
+	}
+
+	resp := bcs.handleRPC(bc, p, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeRPC(w, resp)
+}
+
+// handleRPC calls a method as p. It returns nil for notifications, which
+// get no response.
+func (bcs *BlockchainServer) handleRPC(bc *blockchain.Blockchain, p *utils.Principal, raw json.RawMessage) *rpcResponse {
+
+	var r rpcRequest
+
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return rpcFail(nil, RPC_PARSE_ERROR, "parse error")
+	}
+
+	if r.JSONRPC != "2.0" || r.Method == "" {
+		return rpcFail(r.ID, RPC_INVALID_REQUEST, "invalid request")
+	}
+
+	method, ok := rpcMethods[r.Method]
+
+	var result any
+	var rerr *rpcError
+
+	if !ok {
+		rerr = &rpcError{RPC_METHOD_NOT_FOUND, "method not found"}
+	} else if !p.Has(method.role) {
+		rerr = &rpcError{RPC_FORBIDDEN, string(method.role) + " role required"}
+	} else if params, err := bindParams(method.params, r.Params); err != nil {
+		rerr = &rpcError{RPC_INVALID_PARAMS, err.Error()}
+	} else if !method.verifies {
+		result, rerr = method.call(bc, params)
+	} else if bcs.verify.TryAcquire() {
+		result, rerr = method.call(bc, params)
+		bcs.verify.Release()
+	} else {
+		rerr = &rpcError{RPC_BUSY, "server busy"}
+	}
+
+	if r.ID == nil {
+		return nil
+	}
+
+	if rerr != nil {
+		return rpcFail(r.ID, rerr.Code, rerr.Message)
+	}
+
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: r.ID}
+}
+
+// bindParams turns positional params into an object keyed by name.
+func bindParams(names []string, raw json.RawMessage) (json.RawMessage, error) {
+
+	raw = bytes.TrimSpace(raw)
+
+	if len(raw) == 0 || raw[0] != '[' {
+		if len(raw) == 0 {
+			return json.RawMessage("{}"), nil
+		}
+		return raw, nil
+	}
+
+	var positional []json.RawMessage
+	if err := json.Unmarshal(raw, &positional); err != nil {
+		return nil, err
+	}
+
+	if len(positional) > len(names) {
+		return nil, fmt.Errorf("expected at most %d params, got %d", len(names), len(positional))
+	}
+
+	named := make(map[string]json.RawMessage, len(positional))
+	for i, p := range positional {
+		named[names[i]] = p
+	}
+
+	return json.Marshal(named)
+}
+
+func rpcFail(id json.RawMessage, code int, message string) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{code, message}, ID: id}
+}
+
+func writeRPC(w http.ResponseWriter, v any) {
+	m, _ := json.Marshal(v)
+	io.WriteString(w, string(m))
+}
+
+// ------------------------------------------------------------------
+
+func rpcGetBlockByHeight(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError) {
+
+	var p struct {
+		Height *int `json:"height"`
+	}
+
+	if err := json.Unmarshal(params, &p); err != nil || p.Height == nil {
+		return nil, &rpcError{RPC_INVALID_PARAMS, "height required"}
+	}
+
+	b, ok := bc.BlockByHeight(*p.Height)
+	if !ok {
+		return nil, &rpcError{RPC_INVALID_PARAMS, "block not found"}
+	}
+
+	return b, nil
+}
+
+func rpcGetBalance(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError) {
+
+	var p struct {
+		Address string `json:"address"`
+	}
+
+	if err := json.Unmarshal(params, &p); err != nil || p.Address == "" {
+		return nil, &rpcError{RPC_INVALID_PARAMS, "address required"}
+	}
+
+	return &client.AmountResponse{Amount: bc.CalculateTotalAmount(p.Address)}, nil
+}
+
+func rpcSendRawTransaction(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError) {
+
+	var p struct {
+		Transaction client.TransactionRequest `json:"transaction"`
+	}
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{RPC_INVALID_PARAMS, "transaction required"}
+	}
+
+	txn := p.Transaction
+	if err := txn.Validate(); err != nil {
+		bc.RejectTransaction(err)
+		return nil, &rpcError{RPC_INVALID_PARAMS, err.Error()}
+	}
+	witness, err := blockchain.WitnessFromRequest(&txn)
+	if err != nil {
+		bc.RejectTransaction(err)
+		return nil, &rpcError{RPC_INVALID_PARAMS, err.Error()}
+	}
+
+	if !bc.CreateWitnessedTransaction(*txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, *txn.Value, witness) {
+		return nil, &rpcError{RPC_TX_REJECTED, "transaction rejected"}
+	}
+
+	return true, nil
+}
+
+func rpcGetMempool(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError) {
+	transactions := bc.TransactionPool()
+	return struct {
+		Transactions []*blockchain.Transaction `json:"transactions"`
+		Length       int                       `json:"length"`
+	}{
+		Transactions: transactions,
+		Length:       len(transactions),
+	}, nil
+}
+
+func rpcGetPeers(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError) {
+	return bc.Peers(), nil
+}
+
+func rpcGetChainInfo(bc *blockchain.Blockchain, params json.RawMessage) (any, *rpcError) {
+	return chainInfo(bc), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rpcCall(h http.Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+	return rec
+}
+
+func TestRPC(t *testing.T) {
+	//
+	h := NewBlockchainServer(testConfig()).Router()
+	//
+	rec := rpcCall(h, `{"jsonrpc":"2.0","method":"getBlockByHeight","params":[0],"id":1}`)
+	var resp struct {
+		Result map[string]any `json:"result"`
+		Error  *rpcError      `json:"error"`
+		ID     int            `json:"id"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, 1, resp.ID)
+	assert.Contains(t, resp.Result, "previous_hash")
+	//
+	rec = rpcCall(h, `{"jsonrpc":"2.0","method":"getBalance","params":{"address":"nobody"},"id":"a"}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"amount":0},"id":"a"}`, rec.Body.String())
+	//
+	rec = rpcCall(h, `{"jsonrpc":"2.0","method":"nope","id":2}`)
+	assert.Contains(t, rec.Body.String(), `"code":-32601`)
+	//
+	rec = rpcCall(h, `{"jsonrpc":"2.0","method":"getBlockByHeight","params":[999],"id":3}`)
+	assert.Contains(t, rec.Body.String(), `"code":-32602`)
+	//
+	rec = rpcCall(h, `{bad json`)
+	assert.Contains(t, rec.Body.String(), `"code":-32700`)
+}
+
+func TestRPCBatch(t *testing.T) {
+	//
+	h := NewBlockchainServer(testConfig()).Router()
+	//
+	rec := rpcCall(h, `[
+		{"jsonrpc":"2.0","method":"getChainInfo","id":1},
+		{"jsonrpc":"2.0","method":"getPeers"},
+		{"jsonrpc":"2.0","method":"getMempool","id":2}
+	]`)
+	var batch []map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+	assert.Len(t, batch, 2)
+	//
+	rec = rpcCall(h, `[{"jsonrpc":"2.0","method":"getPeers"}]`)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	//
+	rec = rpcCall(h, `[]`)
+	assert.Contains(t, rec.Body.String(), `"code":-32600`)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T) uint16 {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	return uint16(lis.Addr().(*net.TCPAddr).Port)
+}
+
+func TestRunShutdown(t *testing.T) {
+	//
+	cfg := testConfig()
+	cfg.Node.DataDir = t.TempDir()
+	cfg.API.Host = "127.0.0.1"
+	cfg.API.Port = freePort(t)
+	cfg.API.GRPCPort = freePort(t)
+	cfg.API.ShutdownTimeout = 5 * time.Second
+	bcs := NewBlockchainServer(cfg)
+	//
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bcs.Run(ctx) }()
+	//
+	base := fmt.Sprintf("http://%s", cfg.API.Addr())
+	c := client.New(base, client.WithRetries(0, 0))
+	require.Eventually(t, func() bool {
+		return c.Ready(context.Background()) == nil
+	}, 10*time.Second, 50*time.Millisecond)
+	//
+	// An open event stream must not hold up shutdown.
+	resp, err := http.Get(base + "/v1/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	//
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(cfg.API.ShutdownTimeout):
+		t.Fatal("Run did not return")
+	}
+	assert.Less(t, time.Since(start), 3*time.Second)
+	//
+	_, err = bufio.NewReader(resp.Body).ReadString('\x00')
+	assert.Error(t, err)
+	//
+	chainPath := filepath.Join(cfg.Node.DataDir, "main", blockchain.CHAIN_FILE)
+	_, err = os.Stat(chainPath)
+	require.NoError(t, err)
+	restored := blockchain.NewBlockchain("miner", 5000)
+	require.NoError(t, restored.Load(chainPath))
+	assert.Equal(t, bcs.GetBlockchain().LastBlock().Hash(), restored.LastBlock().Hash())
+	assert.False(t, bcs.GetBlockchain().IsMining())
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/nodepb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testCA signs node certificates for 127.0.0.1 that serve and call peers.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	ca.write(t, "ca.pem", "CERTIFICATE", der)
+
+	return ca
+}
+
+func (ca *testCA) write(t *testing.T, name string, typ string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return path
+}
+
+// node issues a certificate named name and returns its cert and key files.
+func (ca *testCA) node(t *testing.T, name string) (string, string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	return ca.write(t, name+".pem", "CERTIFICATE", der), ca.write(t, name+"-key.pem", "PRIVATE KEY", keyDER)
+}
+
+// tlsConfig returns a node config with mutual TLS under ca.
+func tlsConfig(t *testing.T, ca *testCA, name string) *config.Node {
+	cfg := testConfig()
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = ca.node(t, name)
+	cfg.TLS.PeerCAFile = filepath.Join(ca.dir, "ca.pem")
+	assert.NoError(t, cfg.Validate())
+	return cfg
+}
+
+func serveTLS(t *testing.T, bcs *BlockchainServer) string {
+	srv := httptest.NewUnstartedServer(bcs.Router())
+	srv.TLS = bcs.Config().TLS.Server()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String()
+}
+
+func TestMutualTLS(t *testing.T) {
+	//
+	ca := newTestCA(t)
+	bcs := NewBlockchainServer(tlsConfig(t, ca, "node-a"))
+	addr := serveTLS(t, bcs)
+	ctx := context.Background()
+	//
+	peer := tlsConfig(t, ca, "node-b")
+	assert.Len(t, peer.Params(peer.Chains[0]).PeerTLS.Certificates, 1)
+	//
+	// A peer's certificate grants the peer role; a bare host:port is https.
+	ok, err := client.New(addr, client.WithTLS(peer.TLS.Peer())).Consensus(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	//
+	// Without one the peer endpoints are closed, though reads stay open.
+	anon := &tls.Config{RootCAs: peer.TLS.Peer().RootCAs}
+	_, err = client.New(addr, client.WithTLS(anon)).Consensus(ctx)
+	assert.True(t, client.IsStatus(err, http.StatusUnauthorized))
+	_, err = client.New(addr, client.WithTLS(anon)).Chain(ctx)
+	assert.NoError(t, err)
+	//
+	// Certificates of another CA are refused, and so is plain HTTP.
+	stranger := tlsConfig(t, newTestCA(t), "node-c").TLS.Peer()
+	stranger.RootCAs = anon.RootCAs
+	_, err = client.New(addr, client.WithTLS(stranger)).Chain(ctx)
+	assert.Error(t, err)
+	_, err = client.New(addr, client.WithRetries(0, 0)).Chain(ctx)
+	assert.Error(t, err)
+}
+
+func TestMutualTLSGRPC(t *testing.T) {
+	//
+	ca := newTestCA(t)
+	bcs := NewBlockchainServer(tlsConfig(t, ca, "node-a"))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := bcs.GRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	//
+	dial := func(c *tls.Config) nodepb.NodeClient {
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(c)))
+		assert.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return nodepb.NewNodeClient(conn)
+	}
+	req := &nodepb.Transaction{}
+	//
+	peer := tlsConfig(t, ca, "node-b").TLS.Peer()
+	_, err = dial(peer).RelayTransaction(context.Background(), req)
+	assert.NotEqual(t, codes.Unauthenticated, status.Code(err))
+	//
+	_, err = dial(&tls.Config{RootCAs: peer.RootCAs}).RelayTransaction(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
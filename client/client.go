@@ -0,0 +1,430 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+const (
+	DEFAULT_TIMEOUT = 10 * time.Second
+	DEFAULT_RETRIES = 2
+	DEFAULT_BACKOFF = 200 * time.Millisecond
+
+	// MAX_RESPONSE_BYTES bounds what a node, which may be a hostile peer,
+	// can make the client buffer. Full chains are the largest responses.
+	MAX_RESPONSE_BYTES = 256 << 20
+)
+
+var ErrResponseTooLarge = errors.New("response too large")
+
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("blockchain api: %d %s", e.StatusCode, e.Message)
+}
+
+// IsStatus reports whether err is an *APIError with the given status code.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// ------------------------------------------------------------------
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	token      string
+	tls        bool
+	identity   *utils.NodeIdentity
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests with a copy of hc, so WithTimeout and
+// WithTLS never change the caller's client, which may be
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		copied := *hc
+		c.httpClient = &copied
+	}
+}
+
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// WithToken authenticates every request with "Authorization: Bearer
+// <token>".
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// transports holds one transport per TLS config, so the short-lived
+// clients a node makes per peer request share their connections.
+var transports sync.Map // *tls.Config -> *http.Transport
+
+// WithTLS verifies the node with cfg, which may carry a client
+// certificate for mutual TLS, and makes a bare "host:port" an https://
+// URL. It replaces the transport of WithHTTPClient, so pass it after. A
+// nil cfg keeps the defaults.
+func WithTLS(cfg *tls.Config) Option {
+	return func(c *Client) {
+
+		if cfg == nil {
+			return
+		}
+
+		t, ok := transports.Load(cfg)
+		if !ok {
+			ht := http.DefaultTransport.(*http.Transport).Clone()
+			ht.TLSClientConfig = cfg
+			t, _ = transports.LoadOrStore(cfg, ht)
+		}
+
+		c.httpClient.Transport = t.(*http.Transport)
+		c.tls = true
+	}
+}
+
+// WithIdentity signs every request as the node id, for peers to
+// attribute it. A nil id sends unsigned requests.
+func WithIdentity(id *utils.NodeIdentity) Option {
+	return func(c *Client) {
+		c.identity = id
+	}
+}
+
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New returns a client for the node at baseURL. A bare "host:port", as
+// used for peers, is treated as an http:// URL, or https:// with WithTLS.
+func New(baseURL string, opts ...Option) *Client {
+
+	c := &Client{
+		httpClient: &http.Client{Timeout: DEFAULT_TIMEOUT},
+		retries:    DEFAULT_RETRIES,
+		backoff:    DEFAULT_BACKOFF,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if !strings.Contains(baseURL, "://") {
+		if c.tls {
+			baseURL = "https://" + baseURL
+		} else {
+			baseURL = "http://" + baseURL
+		}
+	}
+	c.baseURL = strings.TrimRight(baseURL, "/")
+
+	return c
+}
+
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// ------------------------------------------------------------------
+
+func (c *Client) Chain(ctx context.Context) (*ChainResponse, error) {
+	var cr ChainResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/chain", nil, nil, &cr); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+func (c *Client) Transactions(ctx context.Context) (*TransactionsResponse, error) {
+	var tr TransactionsResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/transactions", nil, nil, &tr); err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+func (c *Client) SubmitTransaction(ctx context.Context, txn *TransactionRequest) error {
+	return c.do(ctx, http.MethodPost, "/v1/transactions", nil, txn, nil)
+}
+
+func (c *Client) RelayTransaction(ctx context.Context, txn *TransactionRequest) error {
+	return c.do(ctx, http.MethodPut, "/v1/transactions", nil, txn, nil)
+}
+
+func (c *Client) ClearTransactions(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/v1/transactions", nil, nil, nil)
+}
+
+func (c *Client) Mine(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/mine", nil, nil, nil)
+}
+
+func (c *Client) StartMining(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/mine/start", nil, nil, nil)
+}
+
+func (c *Client) StopMining(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/mine/stop", nil, nil, nil)
+}
+
+// Chains lists the chains hosted by the node. It must be called on the
+// node's root URL.
+func (c *Client) Chains(ctx context.Context) (*ChainsResponse, error) {
+	var cr ChainsResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/chains", nil, nil, &cr); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+func (c *Client) ChainInfo(ctx context.Context) (*ChainInfoResponse, error) {
+	var ci ChainInfoResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/chain/info", nil, nil, &ci); err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// Info describes the node and the chain the client points at.
+func (c *Client) Info(ctx context.Context) (*NodeInfoResponse, error) {
+	var ni NodeInfoResponse
+	if err := c.do(ctx, http.MethodGet, "/info", nil, nil, &ni); err != nil {
+		return nil, err
+	}
+	return &ni, nil
+}
+
+// Ready returns nil once the node has loaded its chains and finished its
+// initial sync, and an *APIError with status 503 before then.
+func (c *Client) Ready(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/readyz", nil, nil, nil)
+}
+
+// Block fetches a block by its height or hex hash.
+func (c *Client) Block(ctx context.Context, id string) (*BlockResponse, error) {
+	var br BlockResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/blocks/"+url.PathEscape(id), nil, nil, &br); err != nil {
+		return nil, err
+	}
+	return &br, nil
+}
+
+func (c *Client) Transaction(ctx context.Context, hash string) (*TransactionLookupResponse, error) {
+	var tr TransactionLookupResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/transactions/"+url.PathEscape(hash), nil, nil, &tr); err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+func (c *Client) Peers(ctx context.Context) (*PeersResponse, error) {
+	var pr PeersResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/peers", nil, nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+func (c *Client) AddPeer(ctx context.Context, address string) error {
+	return c.do(ctx, http.MethodPost, "/v1/peers", nil, &PeerRequest{Address: address}, nil)
+}
+
+func (c *Client) RemovePeer(ctx context.Context, address string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/peers/"+url.PathEscape(address), nil, nil, nil)
+}
+
+func (c *Client) Bans(ctx context.Context) (*BansResponse, error) {
+	var br BansResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/bans", nil, nil, &br); err != nil {
+		return nil, err
+	}
+	return &br, nil
+}
+
+func (c *Client) Unban(ctx context.Context, peer string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/bans/"+url.PathEscape(peer), nil, nil, nil)
+}
+
+func (c *Client) Amount(ctx context.Context, blockchainAddress string) (*AmountResponse, error) {
+	var ar AmountResponse
+	q := url.Values{"blockchain_address": {blockchainAddress}}
+	if err := c.do(ctx, http.MethodGet, "/v1/amount", q, nil, &ar); err != nil {
+		return nil, err
+	}
+	return &ar, nil
+}
+
+func (c *Client) Valid(ctx context.Context) (bool, error) {
+	var body string
+	if err := c.do(ctx, http.MethodGet, "/v1/valid", nil, nil, &body); err != nil {
+		return false, err
+	}
+	return body == "TRUE", nil
+}
+
+// Handshake sends this node's signed hello and returns the peer's, which
+// the caller must verify.
+func (c *Client) Handshake(ctx context.Context, hello *Hello) (*Hello, error) {
+	var h Hello
+	if err := c.do(ctx, http.MethodPost, "/v1/handshake", nil, hello, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (c *Client) Consensus(ctx context.Context) (bool, error) {
+	var body string
+	if err := c.do(ctx, http.MethodPut, "/v1/consensus", nil, nil, &body); err != nil {
+		return false, err
+	}
+	return body == "Consensus SUCCESS", nil
+}
+
+// ------------------------------------------------------------------
+
+// do sends the request, retrying transport failures and 5xx responses
+// for GET and HEAD only: a failed PUT or DELETE, such as a relayed
+// transaction, may already have taken effect. out may be a *string to receive a plain-text
+// body, or any other value to receive a decoded JSON body.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, in any, out any) error {
+
+	var payload []byte
+
+	if in != nil {
+		m, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		payload = m
+	}
+
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	attempts := 1
+	if method == http.MethodGet || method == http.MethodHead {
+		attempts += c.retries
+	}
+
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.backoff * time.Duration(attempt)):
+			}
+		}
+
+		var retry bool
+		retry, err = c.once(ctx, method, endpoint, payload, out)
+
+		if !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (c *Client) once(ctx context.Context, method string, endpoint string, payload []byte, out any) (bool, error) {
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return false, err
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.identity != nil {
+		if err := c.identity.SignRequest(req, payload); err != nil {
+			return false, err
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MAX_RESPONSE_BYTES+1))
+	if err != nil {
+		return true, err
+	}
+	if len(data) > MAX_RESPONSE_BYTES {
+		return false, ErrResponseTooLarge
+	}
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, decodeError(resp.StatusCode, data)
+	}
+
+	switch v := out.(type) {
+	case nil:
+		return false, nil
+	case *string:
+		*v = string(data)
+		return false, nil
+	default:
+		return false, json.Unmarshal(data, out)
+	}
+}
+
+func decodeError(status int, data []byte) error {
+
+	var sr StatusResponse
+
+	if err := json.Unmarshal(data, &sr); err == nil && sr.Message != "" {
+		return &APIError{StatusCode: status, Message: sr.Message}
+	}
+
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	return &APIError{StatusCode: status, Message: message}
+}
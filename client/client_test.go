@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetriesServerErrors(t *testing.T) {
+	//
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"amount": 42}`))
+	}))
+	defer srv.Close()
+	//
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+	ar, err := c.Amount(context.Background(), "addr")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(42), ar.Amount)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDoesNotRetryWrites(t *testing.T) {
+	//
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	//
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+	err := c.Mine(context.Background())
+	assert.True(t, IsStatus(err, http.StatusInternalServerError))
+	assert.Equal(t, 1, calls)
+	//
+	err = c.RelayTransaction(context.Background(), &TransactionRequest{})
+	assert.True(t, IsStatus(err, http.StatusInternalServerError))
+	assert.Equal(t, 2, calls)
+	//
+	err = c.ClearTransactions(context.Background())
+	assert.True(t, IsStatus(err, http.StatusInternalServerError))
+	assert.Equal(t, 3, calls)
+}
+
+func TestOptionsDoNotChangeCallerClient(t *testing.T) {
+	//
+	hc := &http.Client{Timeout: time.Minute}
+	c := New("127.0.0.1:1", WithHTTPClient(hc), WithTimeout(time.Second), WithTLS(&tls.Config{}))
+	assert.Equal(t, time.Minute, hc.Timeout)
+	assert.Nil(t, hc.Transport)
+	assert.Equal(t, time.Second, c.httpClient.Timeout)
+	assert.NotNil(t, c.httpClient.Transport)
+}
+
+func TestSendsToken(t *testing.T) {
+	//
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		w.Write([]byte(`{"amount": 0}`))
+	}))
+	defer srv.Close()
+	//
+	_, err := New(srv.URL, WithToken("secret")).Amount(context.Background(), "addr")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", auth)
+	//
+	_, err = New(srv.URL).Amount(context.Background(), "addr")
+	assert.NoError(t, err)
+	assert.Empty(t, auth)
+}
+
+func TestTLS(t *testing.T) {
+	//
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"amount": 7}`))
+	}))
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	cfg := &tls.Config{RootCAs: roots}
+	host := srv.Listener.Addr().String()
+	//
+	c := New(host, WithTLS(cfg))
+	assert.Equal(t, "https://"+host, c.BaseURL())
+	amount, err := c.Amount(context.Background(), "addr")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(7), amount.Amount)
+	//
+	assert.Equal(t, "http://"+host, New(host, WithTLS(nil)).BaseURL())
+	_, err = New(srv.URL, WithRetries(0, 0)).Amount(context.Background(), "addr")
+	assert.Error(t, err)
+}
+
+func TestDecodesErrorMessage(t *testing.T) {
+	//
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message": "fail"}`))
+	}))
+	defer srv.Close()
+	//
+	value := float32(1)
+	s := "x"
+	err := New(srv.URL).SubmitTransaction(context.Background(), &TransactionRequest{
+		SenderBlockchainAddress:    &s,
+		RecipientBlockchainAddress: &s,
+		SenderPublicKey:            &s,
+		Signature:                  &s,
+		Value:                      &value,
+	})
+	//
+	apiErr, ok := err.(*APIError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "fail", apiErr.Message)
+}
+
+func TestPeerAddress(t *testing.T) {
+	assert.Equal(t, "http://127.0.0.1:5000", New("127.0.0.1:5000").BaseURL())
+	assert.Equal(t, "https://node.example", New("https://node.example/").BaseURL())
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+// TransactionRequest is signed either by one key, with SenderPublicKey
+// and Signature, or by a multisig account, with Threshold, PublicKeys and
+// Signatures aligned to PublicKeys (empty for keys that did not sign).
+type TransactionRequest struct {
+	SenderBlockchainAddress    *string  `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
+	SenderPublicKey            *string  `json:"sender_public_key,omitempty"`
+	Signature                  *string  `json:"signature,omitempty"`
+	Value                      *float32 `json:"value"`
+
+	Threshold  *int     `json:"threshold,omitempty"`
+	PublicKeys []string `json:"public_keys,omitempty"`
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+func (tr *TransactionRequest) IsMultisig() bool {
+	return len(tr.PublicKeys) > 0
+}
+
+// Validate checks that every field is present and both addresses are
+// well formed.
+func (tr *TransactionRequest) Validate() error {
+
+	if tr.SenderBlockchainAddress == nil ||
+		tr.RecipientBlockchainAddress == nil ||
+		tr.Value == nil {
+		return errors.New("missing field(s)")
+	}
+
+	if tr.IsMultisig() {
+		if tr.Threshold == nil || tr.SenderPublicKey != nil || tr.Signature != nil {
+			return errors.New("multisig requests take threshold, public_keys and signatures only")
+		}
+		if len(tr.Signatures) != len(tr.PublicKeys) {
+			return fmt.Errorf("%d signatures for %d public keys", len(tr.Signatures), len(tr.PublicKeys))
+		}
+	} else if tr.SenderPublicKey == nil || tr.Signature == nil {
+		return errors.New("missing field(s)")
+	}
+
+	if err := wallet.ValidateAddress(*tr.SenderBlockchainAddress); err != nil {
+		return fmt.Errorf("sender_blockchain_address: %w", err)
+	}
+	if err := wallet.ValidateAddress(*tr.RecipientBlockchainAddress); err != nil {
+		return fmt.Errorf("recipient_blockchain_address: %w", err)
+	}
+
+	return nil
+}
+
+// Keys parses the public key and signature of a validated request and
+// checks that the sender address belongs to the public key.
+func (tr *TransactionRequest) Keys() (utils.PublicKey, *utils.Signature, error) {
+
+	if tr.IsMultisig() {
+		return nil, nil, errors.New("multisig request has no single key")
+	}
+
+	publicKey, err := utils.ParsePublicKey(*tr.SenderPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := utils.ParseSignature(*tr.Signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := wallet.VerifyAddress(*tr.SenderBlockchainAddress, publicKey); err != nil {
+		return nil, nil, err
+	}
+
+	return publicKey, signature, nil
+}
+
+// -------------------------------------------------------------------------
+
+type AmountResponse struct {
+	Amount float32 `json:"amount"`
+}
+
+type StatusResponse struct {
+	Message string `json:"message"`
+}
+
+// Blocks and transactions are left raw so callers can decode them into
+// the blockchain package types, which own their wire format.
+type ChainResponse struct {
+	Blocks []json.RawMessage `json:"blocks"`
+}
+
+type TransactionsResponse struct {
+	Transactions []json.RawMessage `json:"transactions"`
+	Length       int               `json:"length"`
+}
+
+type ChainInfoResponse struct {
+	ChainID     string `json:"chain_id"`
+	Height      int    `json:"height"`
+	TipHash     string `json:"tip_hash"`
+	GenesisHash string `json:"genesis_hash"`
+	Difficulty  int    `json:"difficulty"`
+	MempoolSize int    `json:"mempool_size"`
+	PeerCount   int    `json:"peer_count"`
+	Mining      bool   `json:"mining"`
+}
+
+// HealthResponse answers /healthz and /readyz. Checks maps each
+// dependency to "ok" or to why it is not.
+type HealthResponse struct {
+	Message string            `json:"message"`
+	Checks  map[string]string `json:"checks,omitempty"`
+}
+
+// NodeInfoResponse describes the node and one of its chains.
+type NodeInfoResponse struct {
+	Version string `json:"version"`
+	Node    string `json:"node"`
+	NodeID  string `json:"node_id"`
+	ChainInfoResponse
+	Ready        bool    `json:"ready"`
+	SyncLag      int     `json:"sync_lag"`
+	SyncProgress float64 `json:"sync_progress"`
+}
+
+type BlockResponse struct {
+	Height int             `json:"height"`
+	Hash   string          `json:"hash"`
+	Block  json.RawMessage `json:"block"`
+}
+
+// TransactionLookupResponse has Status "confirmed", with the Height of
+// its block, or "pending".
+type TransactionLookupResponse struct {
+	Hash        string          `json:"hash"`
+	Status      string          `json:"status"`
+	Height      *int            `json:"height,omitempty"`
+	Transaction json.RawMessage `json:"transaction"`
+}
+
+// PeersResponse lists peers by "host:port", with the node IDs of those
+// that completed a handshake.
+type PeersResponse struct {
+	Peers   []string          `json:"peers"`
+	NodeIDs map[string]string `json:"node_ids,omitempty"`
+}
+
+type PeerRequest struct {
+	Address string `json:"address"`
+}
+
+// Ban is a peer refused for misbehaving: its node ID if it signed its
+// messages, else "host:port" for a peer the node calls or the IP of a
+// caller.
+type Ban struct {
+	Peer   string    `json:"peer"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+type BansResponse struct {
+	Bans []Ban `json:"bans"`
+}
+
+type ChainSummary struct {
+	ID      string `json:"id"`
+	Path    string `json:"path"`
+	Height  int    `json:"height"`
+	Default bool   `json:"default"`
+}
+
+type ChainsResponse struct {
+	Chains []ChainSummary `json:"chains"`
+}
+
+// Hello introduces a node to a peer in the handshake. Each side signs its
+// own, and the answer echoes the caller's nonce so it cannot be replayed.
+type Hello struct {
+	NodeID      string `json:"node_id"`
+	PublicKey   string `json:"public_key"`
+	Version     int    `json:"version"`
+	ChainID     string `json:"chain_id"`
+	GenesisHash string `json:"genesis_hash"`
+	Height      int    `json:"height"`
+	Nonce       string `json:"nonce"`
+	Signature   string `json:"signature,omitempty"`
+}
+
+func (h *Hello) digest() []byte {
+	unsigned := *h
+	unsigned.Signature = ""
+	b, _ := json.Marshal(&unsigned)
+	hash := sha256.Sum256(b)
+	return hash[:]
+}
+
+// Sign fills in the identity of the node and signs the hello.
+func (h *Hello) Sign(id *utils.NodeIdentity) error {
+
+	h.NodeID = id.ID()
+	h.PublicKey = id.PublicKey().String()
+
+	sig, err := id.Sign(h.digest())
+	if err != nil {
+		return err
+	}
+	h.Signature = sig.String()
+
+	return nil
+}
+
+// Verify checks that the hello was signed by the key of its node ID.
+func (h *Hello) Verify() error {
+
+	pub, err := utils.ParsePublicKey(h.PublicKey)
+	if err != nil {
+		return fmt.Errorf("public_key: %w", err)
+	}
+	if utils.NodeID(pub) != h.NodeID {
+		return errors.New("node_id does not match public_key")
+	}
+	sig, err := utils.ParseSignature(h.Signature)
+	if err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+	if !pub.Verify(h.digest(), sig) {
+		return errors.New("invalid hello signature")
+	}
+
+	return nil
+}
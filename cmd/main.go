@@ -1,11 +1,130 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
 
-	"github.com/i101dev/blockchain-api/utils"
+	"golang.org/x/term"
 )
 
+// command is one subcommand. Each parses its own flags from args and
+// writes results to env.stdout.
+type command struct {
+	summary string
+	run     func(env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"keygen":    {"Create an encrypted key in a keystore directory", runKeygen},
+	"build":     {"Build an unsigned transaction", runBuild},
+	"sign":      {"Sign a transaction offline with an encrypted key file", runSign},
+	"broadcast": {"Submit a signed transaction to a node", runBroadcast},
+	"ping":      {"Check whether a node is listening", runPing},
+	"chains":    {"List the chains a node hosts", runChains},
+	"info":      {"Show chain height, tip, mempool size and peer count", runInfo},
+	"block":     {"Show a block by height or hash", runBlock},
+	"tx":        {"Look up a transaction by hash in the chain or mempool", runTx},
+	"balance":   {"Show the confirmed balance of an address", runBalance},
+	"mempool":   {"List pending transactions", runMempool},
+	"peers":     {"List peers, or add or remove one (peers add|remove <host:port>)", runPeers},
+	"miner":     {"Start or stop the mining loop (miner start|stop)", runMiner},
+	"validate":  {"Validate the node's chain", runValidate},
+}
+
+type env struct {
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// readTerminal reads a line from stdin without echoing it. It is set
+	// only when stdin is a terminal.
+	readTerminal func() ([]byte, error)
+}
+
 func main() {
-	fmt.Println(utils.IsFoundHost("127.0.0.1", 5000))
+	e := &env{stdin: bufio.NewReader(os.Stdin), stdout: os.Stdout, stderr: os.Stderr}
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		e.readTerminal = func() ([]byte, error) { return term.ReadPassword(fd) }
+	}
+	if err := run(e, os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(e *env, args []string) error {
+
+	if len(args) == 0 {
+		usage(e.stderr)
+		return flag.ErrHelp
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(e.stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return cmd.run(e, args[1:])
+}
+
+func usage(w io.Writer) {
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: blockchainctl <command> [flags] [args]")
+	fmt.Fprintln(w)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// readPassword takes the first line of passwordFile, or of stdin when no
+// file is given. A terminal does not echo the password as it is typed.
+func readPassword(e *env, passwordFile string) (string, error) {
+
+	if passwordFile != "" {
+		b, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", err
+		}
+		line, _, _ := strings.Cut(string(b), "\n")
+		return strings.TrimRight(line, "\r"), nil
+	}
+
+	fmt.Fprint(e.stderr, "Password: ")
+
+	// Input already buffered, such as a transaction typed before the
+	// password, means the terminal is read through stdin.
+	if e.readTerminal != nil && e.stdin.Buffered() == 0 {
+		b, err := e.readTerminal()
+		fmt.Fprintln(e.stderr)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return string(b), nil
+	}
+
+	line, err := e.stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
 }
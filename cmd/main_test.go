@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	e := &env{stdin: bufio.NewReader(strings.NewReader(stdin)), stdout: &stdout, stderr: &stderr}
+	err := run(e, args)
+	return stdout.String(), err
+}
+
+func TestOfflineSigning(t *testing.T) {
+	//
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	os.WriteFile(passwordFile, []byte("secret\n"), 0600)
+	//
+	out, err := runCmd(t, "", "keygen", "-keystore", filepath.Join(dir, "keys"), "-algorithm", "secp256k1", "-password-file", passwordFile)
+	assert.NoError(t, err)
+	var key struct {
+		Address string `json:"address"`
+		KeyFile string `json:"key_file"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &key))
+	//
+	recipient := wallet.NewWallet().BlockchainAddress()
+	unsigned := filepath.Join(dir, "tx.json")
+	_, err = runCmd(t, "", "build", "-from", key.Address, "-to", recipient, "-value", "2.5", "-out", unsigned)
+	assert.NoError(t, err)
+	//
+	_, err = runCmd(t, "wrong\n", "sign", "-keyfile", key.KeyFile, "-in", unsigned)
+	assert.ErrorIs(t, err, wallet.ErrWrongPassword)
+	//
+	signed, err := runCmd(t, "secret\n", "sign", "-keyfile", key.KeyFile, "-in", unsigned)
+	assert.NoError(t, err)
+	assert.Contains(t, signed, `"signature": "secp256k1:`)
+	//
+	// The transaction and the password may both come through stdin.
+	raw, err := os.ReadFile(unsigned)
+	require.NoError(t, err)
+	piped, err := runCmd(t, string(raw)+"secret\n", "sign", "-keyfile", key.KeyFile)
+	assert.NoError(t, err)
+	assert.Contains(t, piped, `"signature": "secp256k1:`)
+	_, err = runCmd(t, string(raw)+"wrong\n", "sign", "-keyfile", key.KeyFile)
+	assert.ErrorIs(t, err, wallet.ErrWrongPassword)
+	//
+	var received *blockchain.Witness
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var txn client.TransactionRequest
+		json.NewDecoder(req.Body).Decode(&txn)
+		witness, err := blockchain.WitnessFromRequest(&txn)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = witness
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer node.Close()
+	//
+	out, err = runCmd(t, signed, "broadcast", "-node", node.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "accepted")
+	assert.NotNil(t, received)
+	//
+	_, err = runCmd(t, "", "broadcast", "-node", node.URL, "-in", unsigned)
+	assert.Error(t, err)
+}
+
+func TestBuildRejectsBadInput(t *testing.T) {
+	//
+	good := wallet.NewWallet().BlockchainAddress()
+	//
+	_, err := runCmd(t, "", "build", "-from", "bob", "-to", good, "-value", "1")
+	assert.ErrorIs(t, err, wallet.ErrInvalidAddress)
+	_, err = runCmd(t, "", "build", "-from", good, "-to", good, "-value", "-1")
+	assert.Error(t, err)
+	_, err = runCmd(t, "", "nope")
+	assert.Error(t, err)
+}
+
+func TestReadPasswordFromTerminal(t *testing.T) {
+	//
+	var stderr bytes.Buffer
+	e := &env{stdin: bufio.NewReader(strings.NewReader("")), stdout: io.Discard, stderr: &stderr}
+	e.readTerminal = func() ([]byte, error) { return []byte("secret"), nil }
+	password, err := readPassword(e, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", password)
+	assert.Equal(t, "Password: \n", stderr.String())
+	//
+	// A pipe has no terminal and is read line by line.
+	e = &env{stdin: bufio.NewReader(strings.NewReader("piped\nrest")), stdout: io.Discard, stderr: io.Discard}
+	password, err = readPassword(e, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "piped", password)
+}
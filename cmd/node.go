@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+// Operator commands talk to a running blockchain_server. Each prints a
+// table by default, or the API response as JSON with -o json.
+
+const (
+	OUTPUT_TABLE = "table"
+	OUTPUT_JSON  = "json"
+)
+
+type nodeFlags struct {
+	node    *string
+	admin   *string
+	output  *string
+	timeout *time.Duration
+	token   *string
+	cacert  *string
+
+	tls *tls.Config
+}
+
+func addNodeFlags(fs *flag.FlagSet) *nodeFlags {
+	return &nodeFlags{
+		node:    fs.String("node", "http://127.0.0.1:5000", "Node API URL"),
+		output:  fs.String("o", OUTPUT_TABLE, "Output format: table or json"),
+		timeout: fs.Duration("timeout", client.DEFAULT_TIMEOUT, "Request timeout"),
+		token:   fs.String("token", "", "API key sent to the node"),
+		cacert:  fs.String("cacert", "", "PEM CA that signs the node's certificate"),
+	}
+}
+
+// ADMIN_PORT_OFFSET is how far the admin listener's port is from the
+// public one by default.
+const ADMIN_PORT_OFFSET = 2000
+
+// addAdminFlag adds -admin to commands that call the admin listener.
+func (nf *nodeFlags) addAdminFlag(fs *flag.FlagSet) {
+	nf.admin = fs.String("admin", "", fmt.Sprintf("Node admin listener URL (default: -node with its port + %d)", ADMIN_PORT_OFFSET))
+}
+
+// parseNodeFlags parses args and checks the output format and that
+// exactly nargs positional arguments were given.
+func parseNodeFlags(fs *flag.FlagSet, nf *nodeFlags, args []string, nargs int) error {
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *nf.output != OUTPUT_TABLE && *nf.output != OUTPUT_JSON {
+		return fmt.Errorf("unknown output format %q", *nf.output)
+	}
+
+	if fs.NArg() != nargs {
+		return fmt.Errorf("%s takes %d argument(s), got %d", fs.Name(), nargs, fs.NArg())
+	}
+
+	var err error
+	nf.tls, err = loadCACert(*nf.cacert)
+	return err
+}
+
+func (nf *nodeFlags) client() *client.Client {
+	return client.New(*nf.node, client.WithTLS(nf.tls), client.WithTimeout(*nf.timeout), client.WithToken(*nf.token))
+}
+
+// adminClient calls the admin listener, at -admin or else derived from
+// -node.
+func (nf *nodeFlags) adminClient() (*client.Client, error) {
+
+	admin := *nf.admin
+	if admin == "" {
+		var err error
+		if admin, err = adminURL(*nf.node); err != nil {
+			return nil, err
+		}
+	}
+
+	return client.New(admin, client.WithTLS(nf.tls), client.WithTimeout(*nf.timeout), client.WithToken(*nf.token)), nil
+}
+
+// adminURL is node with its port moved by ADMIN_PORT_OFFSET, which is
+// where the admin listener of a node with the default ports is.
+func adminURL(node string) (string, error) {
+
+	if !strings.Contains(node, "://") {
+		node = "http://" + node
+	}
+	u, err := url.Parse(node)
+	if err != nil {
+		return "", err
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port+ADMIN_PORT_OFFSET > 65535 {
+		return "", fmt.Errorf("cannot derive the admin URL from %q; set -admin", node)
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port+ADMIN_PORT_OFFSET))
+
+	return u.String(), nil
+}
+
+// loadCACert returns a TLS config trusting the certificates of path, or
+// nil for the system roots if path is empty.
+func loadCACert(path string) (*tls.Config, error) {
+
+	if path == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificates", path)
+	}
+
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+func (nf *nodeFlags) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), *nf.timeout)
+}
+
+// print writes v as JSON, or calls table with a tabwriter that is
+// flushed afterwards.
+func (nf *nodeFlags) print(e *env, v any, table func(w io.Writer)) error {
+
+	if *nf.output == OUTPUT_JSON {
+		return writeOut(e, "", v)
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// ------------------------------------------------------------------
+
+func runInfo(e *env, args []string) error {
+
+	fs := newFlagSet(e, "info")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	info, err := nf.client().ChainInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nf.print(e, info, func(w io.Writer) {
+		fmt.Fprintf(w, "CHAIN\t%s\n", info.ChainID)
+		fmt.Fprintf(w, "HEIGHT\t%d\n", info.Height)
+		fmt.Fprintf(w, "TIP\t%s\n", info.TipHash)
+		fmt.Fprintf(w, "GENESIS\t%s\n", info.GenesisHash)
+		fmt.Fprintf(w, "DIFFICULTY\t%d\n", info.Difficulty)
+		fmt.Fprintf(w, "MEMPOOL\t%d\n", info.MempoolSize)
+		fmt.Fprintf(w, "PEERS\t%d\n", info.PeerCount)
+		fmt.Fprintf(w, "MINING\t%t\n", info.Mining)
+	})
+}
+
+func runChains(e *env, args []string) error {
+
+	fs := newFlagSet(e, "chains")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	cr, err := nf.client().Chains(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nf.print(e, cr, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tPATH\tHEIGHT\tDEFAULT")
+		for _, c := range cr.Chains {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%t\n", c.ID, c.Path, c.Height, c.Default)
+		}
+	})
+}
+
+func runBlock(e *env, args []string) error {
+
+	fs := newFlagSet(e, "block")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	br, err := nf.client().Block(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var b blockchain.Block
+	if err := json.Unmarshal(br.Block, &b); err != nil {
+		return fmt.Errorf("decode block: %w", err)
+	}
+
+	return nf.print(e, br, func(w io.Writer) {
+		fmt.Fprintf(w, "HEIGHT\t%d\n", br.Height)
+		fmt.Fprintf(w, "HASH\t%s\n", br.Hash)
+		fmt.Fprintf(w, "PREVIOUS\t%x\n", b.PreviousHash())
+		fmt.Fprintf(w, "TIME\t%s\n", time.Unix(0, b.Timestamp()).UTC().Format(time.RFC3339))
+		fmt.Fprintf(w, "NONCE\t%d\n", b.Nonce())
+		fmt.Fprintln(w)
+		transactionTable(w, b.Transactions())
+	})
+}
+
+func runTx(e *env, args []string) error {
+
+	fs := newFlagSet(e, "tx")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	tr, err := nf.client().Transaction(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var t blockchain.Transaction
+	if err := json.Unmarshal(tr.Transaction, &t); err != nil {
+		return fmt.Errorf("decode transaction: %w", err)
+	}
+
+	return nf.print(e, tr, func(w io.Writer) {
+		fmt.Fprintf(w, "HASH\t%s\n", tr.Hash)
+		fmt.Fprintf(w, "STATUS\t%s\n", tr.Status)
+		if tr.Height != nil {
+			fmt.Fprintf(w, "HEIGHT\t%d\n", *tr.Height)
+		}
+		fmt.Fprintf(w, "FROM\t%s\n", t.SenderBlockchainAddress())
+		fmt.Fprintf(w, "TO\t%s\n", t.RecipientBlockchainAddress())
+		fmt.Fprintf(w, "VALUE\t%g\n", t.Value())
+	})
+}
+
+func runBalance(e *env, args []string) error {
+
+	fs := newFlagSet(e, "balance")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 1); err != nil {
+		return err
+	}
+
+	address := fs.Arg(0)
+	if err := wallet.ValidateAddress(address); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	amt, err := nf.client().Amount(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	return nf.print(e, amt, func(w io.Writer) {
+		fmt.Fprintf(w, "ADDRESS\t%s\n", address)
+		fmt.Fprintf(w, "BALANCE\t%g\n", amt.Amount)
+	})
+}
+
+func runMempool(e *env, args []string) error {
+
+	fs := newFlagSet(e, "mempool")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	tr, err := nf.client().Transactions(ctx)
+	if err != nil {
+		return err
+	}
+
+	txns := make([]*blockchain.Transaction, len(tr.Transactions))
+	for i, raw := range tr.Transactions {
+		txns[i] = new(blockchain.Transaction)
+		if err := json.Unmarshal(raw, txns[i]); err != nil {
+			return fmt.Errorf("decode transaction: %w", err)
+		}
+	}
+
+	return nf.print(e, tr, func(w io.Writer) {
+		transactionTable(w, txns)
+	})
+}
+
+func transactionTable(w io.Writer, txns []*blockchain.Transaction) {
+	fmt.Fprintln(w, "HASH\tFROM\tTO\tVALUE")
+	for _, t := range txns {
+		fmt.Fprintf(w, "%x\t%s\t%s\t%g\n", t.Hash(), t.SenderBlockchainAddress(), t.RecipientBlockchainAddress(), t.Value())
+	}
+}
+
+// runPeers lists peers, or with "add" or "remove" and an address
+// changes the node's peer set. Flags may come before or after the action.
+func runPeers(e *env, args []string) error {
+
+	fs := newFlagSet(e, "peers")
+	nf := addNodeFlags(fs)
+	nf.addAdminFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	action := "list"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+
+	nargs := 1
+	if action == "add" || action == "remove" {
+		nargs = 2
+	} else if action != "list" {
+		return fmt.Errorf("unknown peers action %q (want list, add or remove)", action)
+	}
+	if err := parseNodeFlags(fs, nf, fs.Args()[min(1, fs.NArg()):], nargs-1); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	if action == "list" {
+		pr, err := nf.client().Peers(ctx)
+		if err != nil {
+			return err
+		}
+		return nf.print(e, pr, func(w io.Writer) {
+			fmt.Fprintln(w, "PEER\tNODE ID")
+			for _, p := range pr.Peers {
+				id := pr.NodeIDs[p]
+				if id == "" {
+					id = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\n", p, id)
+			}
+		})
+	}
+
+	c, err := nf.adminClient()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "add":
+		if err := c.AddPeer(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, "added", fs.Arg(0))
+	case "remove":
+		if err := c.RemovePeer(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, "removed", fs.Arg(0))
+	}
+
+	return nil
+}
+
+func runMiner(e *env, args []string) error {
+
+	fs := newFlagSet(e, "miner")
+	nf := addNodeFlags(fs)
+	nf.addAdminFlag(fs)
+	if err := parseNodeFlags(fs, nf, args, 1); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	c, err := nf.adminClient()
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "start":
+		if err := c.StartMining(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, "mining started")
+	case "stop":
+		if err := c.StopMining(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, "mining stopped")
+	default:
+		return fmt.Errorf("unknown miner action %q (want start or stop)", fs.Arg(0))
+	}
+
+	return nil
+}
+
+// errInvalidChain makes validate exit non-zero without hiding the
+// printed result.
+var errInvalidChain = errors.New("chain is invalid")
+
+func runValidate(e *env, args []string) error {
+
+	fs := newFlagSet(e, "validate")
+	nf := addNodeFlags(fs)
+	if err := parseNodeFlags(fs, nf, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := nf.context()
+	defer cancel()
+
+	valid, err := nf.client().Valid(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = nf.print(e, struct {
+		Valid bool `json:"valid"`
+	}{valid}, func(w io.Writer) {
+		fmt.Fprintf(w, "VALID\t%t\n", valid)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return errInvalidChain
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubNode serves canned responses for the operator endpoints and records
+// peer and miner changes.
+func stubNode(t *testing.T, txn *blockchain.Transaction) (*httptest.Server, map[string]string) {
+
+	calls := make(map[string]string)
+	raw, _ := txn.MarshalJSON()
+	height := 3
+
+	write := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/chain/info", func(w http.ResponseWriter, req *http.Request) {
+		write(w, &client.ChainInfoResponse{ChainID: "main", Height: 3, TipHash: "abcd", MempoolSize: 1, Mining: true})
+	})
+	mux.HandleFunc("GET /v1/chains", func(w http.ResponseWriter, req *http.Request) {
+		write(w, &client.ChainsResponse{Chains: []client.ChainSummary{{ID: "main", Path: "/chains/main", Height: 3, Default: true}}})
+	})
+	mux.HandleFunc("GET /v1/transactions", func(w http.ResponseWriter, req *http.Request) {
+		write(w, &client.TransactionsResponse{Transactions: []json.RawMessage{raw}, Length: 1})
+	})
+	mux.HandleFunc("GET /v1/transactions/{hash}", func(w http.ResponseWriter, req *http.Request) {
+		write(w, &client.TransactionLookupResponse{Hash: req.PathValue("hash"), Status: "confirmed", Height: &height, Transaction: raw})
+	})
+	mux.HandleFunc("GET /v1/peers", func(w http.ResponseWriter, req *http.Request) {
+		write(w, &client.PeersResponse{Peers: []string{"10.0.0.1:5000", "10.0.0.3:5000"}, NodeIDs: map[string]string{"10.0.0.1:5000": "4f1c"}})
+	})
+	mux.HandleFunc("POST /v1/peers", func(w http.ResponseWriter, req *http.Request) {
+		var pr client.PeerRequest
+		json.NewDecoder(req.Body).Decode(&pr)
+		calls["add"] = pr.Address
+	})
+	mux.HandleFunc("DELETE /v1/peers/{address}", func(w http.ResponseWriter, req *http.Request) {
+		calls["remove"] = req.PathValue("address")
+	})
+	mux.HandleFunc("POST /v1/mine/stop", func(w http.ResponseWriter, req *http.Request) {
+		calls["miner"] = "stop"
+	})
+	mux.HandleFunc("GET /v1/valid", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "FALSE")
+	})
+
+	node := httptest.NewServer(mux)
+	t.Cleanup(node.Close)
+	return node, calls
+}
+
+func TestNodeCommands(t *testing.T) {
+	//
+	recipient := wallet.NewWallet().BlockchainAddress()
+	txn := blockchain.NewTransaction(blockchain.MINING_SENDER, recipient, 100)
+	hash := fmt.Sprintf("%x", txn.Hash())
+	node, calls := stubNode(t, txn)
+	//
+	out, err := runCmd(t, "", "info", "-node", node.URL)
+	require.NoError(t, err)
+	assert.Regexp(t, `HEIGHT\s+3`, out)
+	assert.Regexp(t, `MINING\s+true`, out)
+	//
+	out, err = runCmd(t, "", "chains", "-node", node.URL)
+	require.NoError(t, err)
+	assert.Regexp(t, `main\s+/chains/main\s+3\s+true`, out)
+	//
+	out, err = runCmd(t, "", "info", "-node", node.URL, "-o", "json")
+	require.NoError(t, err)
+	var info client.ChainInfoResponse
+	require.NoError(t, json.Unmarshal([]byte(out), &info))
+	assert.Equal(t, 3, info.Height)
+	//
+	out, err = runCmd(t, "", "mempool", "-node", node.URL)
+	require.NoError(t, err)
+	assert.Contains(t, out, hash)
+	assert.Contains(t, out, recipient)
+	//
+	out, err = runCmd(t, "", "tx", "-node", node.URL, hash)
+	require.NoError(t, err)
+	assert.Regexp(t, `STATUS\s+confirmed`, out)
+	//
+	out, err = runCmd(t, "", "peers", "-node", node.URL)
+	require.NoError(t, err)
+	assert.Regexp(t, `10\.0\.0\.1:5000\s+4f1c`, out)
+	assert.Regexp(t, `10\.0\.0\.3:5000\s+-`, out)
+	//
+	_, err = runCmd(t, "", "peers", "add", "-admin", node.URL, "10.0.0.2:5000")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:5000", calls["add"])
+	_, err = runCmd(t, "", "peers", "-admin", node.URL, "remove", "10.0.0.1:5000")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:5000", calls["remove"])
+	//
+	_, err = runCmd(t, "", "miner", "-admin", node.URL, "stop")
+	require.NoError(t, err)
+	assert.Equal(t, "stop", calls["miner"])
+	//
+	out, err = runCmd(t, "", "validate", "-node", node.URL)
+	assert.ErrorIs(t, err, errInvalidChain)
+	assert.Regexp(t, `VALID\s+false`, out)
+}
+
+func TestAdminURL(t *testing.T) {
+	//
+	u, err := adminURL("http://127.0.0.1:5000")
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:7000", u)
+	u, err = adminURL("https://node.example:5001/chains/test")
+	require.NoError(t, err)
+	assert.Equal(t, "https://node.example:7001/chains/test", u)
+	u, err = adminURL("[::1]:5000")
+	require.NoError(t, err)
+	assert.Equal(t, "http://[::1]:7000", u)
+	//
+	_, err = adminURL("https://node.example")
+	assert.ErrorContains(t, err, "set -admin")
+}
+
+func TestNodeCommandsRejectBadInput(t *testing.T) {
+	//
+	_, err := runCmd(t, "", "info", "-o", "yaml")
+	assert.Error(t, err)
+	_, err = runCmd(t, "", "block")
+	assert.Error(t, err)
+	_, err = runCmd(t, "", "balance", "bob")
+	assert.ErrorIs(t, err, wallet.ErrInvalidAddress)
+	_, err = runCmd(t, "", "peers", "drop", "10.0.0.1:5000")
+	assert.Error(t, err)
+	_, err = runCmd(t, "", "miner", "pause")
+	assert.Error(t, err)
+}
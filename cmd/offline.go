@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+// The offline flow keeps keys on a machine with no network access:
+//
+//	blockchainctl build -from A -to B -value 5 -out tx.json            (online or offline)
+//	blockchainctl sign -keyfile A.json -in tx.json -out signed.json     (offline)
+//	blockchainctl broadcast -node http://node:5000 -in signed.json      (online)
+//
+// Transactions move between machines as TransactionRequest JSON.
+
+func runKeygen(e *env, args []string) error {
+
+	fs := newFlagSet(e, "keygen")
+	dir := fs.String("keystore", "keystore", "Directory to write the encrypted key to")
+	alg := fs.String("algorithm", string(utils.ALG_P256), "Key algorithm: p256, secp256k1 or ed25519")
+	passwordFile := fs.String("password-file", "", "File whose first line is the password (default: read stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !utils.Algorithm(*alg).Valid() {
+		return fmt.Errorf("unsupported algorithm %q", *alg)
+	}
+
+	password, err := readPassword(e, *passwordFile)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("password required")
+	}
+
+	ks, err := wallet.NewKeystore(*dir)
+	if err != nil {
+		return err
+	}
+
+	w, err := ks.CreateWithAlgorithm(password, utils.Algorithm(*alg))
+	if err != nil {
+		return err
+	}
+
+	return writeOut(e, "", struct {
+		Address   string `json:"address"`
+		PublicKey string `json:"public_key"`
+		KeyFile   string `json:"key_file"`
+	}{
+		Address:   w.BlockchainAddress(),
+		PublicKey: w.PublicKeyStr(),
+		KeyFile:   ks.KeyFilePath(w.BlockchainAddress()),
+	})
+}
+
+func runBuild(e *env, args []string) error {
+
+	fs := newFlagSet(e, "build")
+	from := fs.String("from", "", "Sender address")
+	to := fs.String("to", "", "Recipient address")
+	value := fs.String("value", "", "Amount to send")
+	out := fs.String("out", "", "Write the transaction here instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := wallet.ValidateAddress(*from); err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	if err := wallet.ValidateAddress(*to); err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+
+	v, err := strconv.ParseFloat(*value, 32)
+	if err != nil || v <= 0 {
+		return fmt.Errorf("-value: want a positive number, got %q", *value)
+	}
+	value32 := float32(v)
+
+	return writeOut(e, *out, &client.TransactionRequest{
+		SenderBlockchainAddress:    from,
+		RecipientBlockchainAddress: to,
+		Value:                      &value32,
+	})
+}
+
+// runSign signs with one encrypted key file. A single-key transaction
+// gets the key's public key and signature; with -cosign the output is a
+// co-signature for a multisig proposal instead.
+func runSign(e *env, args []string) error {
+
+	fs := newFlagSet(e, "sign")
+	keyFile := fs.String("keyfile", "", "Encrypted key file written by keygen")
+	in := fs.String("in", "", "Unsigned transaction (default: stdin)")
+	out := fs.String("out", "", "Write the signed transaction here instead of stdout")
+	cosign := fs.Bool("cosign", false, "Output a multisig co-signature rather than a signed transaction")
+	passwordFile := fs.String("password-file", "", "File whose first line is the password (default: read stdin, after the transaction if -in is unset)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *keyFile == "" {
+		return errors.New("-keyfile required")
+	}
+
+	txn, err := readTransaction(e, *in)
+	if err != nil {
+		return err
+	}
+	if txn.SenderBlockchainAddress == nil || txn.RecipientBlockchainAddress == nil || txn.Value == nil {
+		return errors.New("transaction needs sender_blockchain_address, recipient_blockchain_address and value")
+	}
+
+	password, err := readPassword(e, *passwordFile)
+	if err != nil {
+		return err
+	}
+
+	w, err := wallet.ReadKeyFile(*keyFile, password)
+	if err != nil {
+		return err
+	}
+
+	sender, recipient, value := *txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, *txn.Value
+	signature := wallet.NewWalletTransaction(w.PrivateKey(), w.PublicKey(), sender, recipient, value).GenerateSignature()
+
+	if *cosign {
+		return writeOut(e, *out, struct {
+			PublicKey string `json:"public_key"`
+			Signature string `json:"signature"`
+		}{
+			PublicKey: w.PublicKeyStr(),
+			Signature: signature.String(),
+		})
+	}
+
+	if w.BlockchainAddress() != sender {
+		return fmt.Errorf("key file holds %s, transaction is from %s", w.BlockchainAddress(), sender)
+	}
+
+	publicKeyStr, signatureStr := w.PublicKeyStr(), signature.String()
+	txn.SenderPublicKey = &publicKeyStr
+	txn.Signature = &signatureStr
+
+	if err := txn.Validate(); err != nil {
+		return err
+	}
+	if _, _, err := txn.Keys(); err != nil {
+		return err
+	}
+
+	return writeOut(e, *out, txn)
+}
+
+func runBroadcast(e *env, args []string) error {
+
+	fs := newFlagSet(e, "broadcast")
+	node := fs.String("node", "http://127.0.0.1:5000", "Node to submit to")
+	in := fs.String("in", "", "Signed transaction (default: stdin)")
+	timeout := fs.Duration("timeout", client.DEFAULT_TIMEOUT, "Request timeout")
+	token := fs.String("token", "", "API key sent to the node")
+	cacert := fs.String("cacert", "", "PEM CA that signs the node's certificate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	tlsConfig, err := loadCACert(*cacert)
+	if err != nil {
+		return err
+	}
+
+	txn, err := readTransaction(e, *in)
+	if err != nil {
+		return err
+	}
+	if err := txn.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := client.New(*node, client.WithTLS(tlsConfig), client.WithTimeout(*timeout), client.WithToken(*token)).SubmitTransaction(ctx, txn); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(e.stdout, "transaction accepted by", *node)
+	return nil
+}
+
+func runPing(e *env, args []string) error {
+
+	fs := newFlagSet(e, "ping")
+	host := fs.String("host", "127.0.0.1", "Node host")
+	port := fs.Uint("port", 5000, "Node port")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if !utils.IsFoundHost(*host, uint16(*port)) {
+		return fmt.Errorf("%s:%d is not reachable", *host, *port)
+	}
+
+	fmt.Fprintf(e.stdout, "%s:%d is up (%s)\n", *host, *port, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// ------------------------------------------------------------------
+
+func readTransaction(e *env, path string) (*client.TransactionRequest, error) {
+
+	var r io.Reader = e.stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var txn client.TransactionRequest
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&txn); err != nil {
+		return nil, fmt.Errorf("decode transaction: %w", err)
+	}
+
+	// The decoder reads ahead of the transaction. Hand what it buffered
+	// back to stdin, less the end of the transaction's last line, so a
+	// password can follow on the next line.
+	if path == "" {
+		e.stdin = bufio.NewReader(io.MultiReader(dec.Buffered(), e.stdin))
+		if rest, _ := e.stdin.ReadString('\n'); strings.TrimSpace(rest) != "" {
+			return nil, errors.New("decode transaction: unexpected input after it on the same line")
+		}
+	}
+
+	return &txn, nil
+}
+
+// writeOut writes v as indented JSON to path, or to stdout when path is
+// empty.
+func writeOut(e *env, path string, v any) error {
+
+	m, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	m = append(m, '\n')
+
+	if path == "" {
+		_, err = e.stdout.Write(m)
+		return err
+	}
+
+	return os.WriteFile(path, m, 0600)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+// MIN_TOKEN_LENGTH keeps API keys and the peer token hard to guess.
+const MIN_TOKEN_LENGTH = 16
+
+var nodeIDPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Auth sets who may call which endpoints. Requests present a token as
+// "Authorization: Bearer <token>" or X-API-Key; requests without one get
+// the anonymous roles of the listener they arrive on.
+type Auth struct {
+	Keys APIKeys `yaml:"keys"`
+	// Anonymous are the roles of keyless requests on the public HTTP and
+	// gRPC listeners.
+	Anonymous []string `yaml:"anonymous"`
+	// AdminAnonymous are the roles of keyless requests on the admin
+	// listener, which binds to loopback by default.
+	AdminAnonymous []string `yaml:"admin_anonymous"`
+	// PeerToken is shared by the nodes of a network. It grants the peer
+	// role and is sent with every request to a peer. Without it, peers
+	// get the role from a peer CA certificate or a signature by one of
+	// TrustedNodes; anonymous callers never hold it.
+	PeerToken string `yaml:"peer_token"`
+	// TrustedNodes are the node IDs whose signed requests get the peer
+	// role. A signature from any other node only attributes the request.
+	TrustedNodes []string `yaml:"trusted_nodes"`
+	// RequirePeerSignatures refuses peer calls that are not signed by a
+	// node identity. Signed calls are attributed to the signing node
+	// either way.
+	RequirePeerSignatures bool `yaml:"require_peer_signatures"`
+}
+
+type APIKey struct {
+	Name  string   `yaml:"name"`
+	Token string   `yaml:"token"`
+	Roles []string `yaml:"roles"`
+}
+
+// APIKeys can be set from the environment or a flag as comma separated
+// name:role+role:token entries.
+type APIKeys []APIKey
+
+func (ks *APIKeys) Set(s string) error {
+	*ks = nil
+	for _, entry := range splitList(s) {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("%q is not name:role+role:token", entry)
+		}
+		*ks = append(*ks, APIKey{Name: parts[0], Roles: strings.Split(parts[1], "+"), Token: parts[2]})
+	}
+	return nil
+}
+
+// String lists key names only, so tokens never reach usage output.
+func (ks APIKeys) String() string {
+	names := make([]string, len(ks))
+	for i, k := range ks {
+		names[i] = k.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func DefaultAuth() Auth {
+	return Auth{
+		Keys:           APIKeys{},
+		TrustedNodes:   []string{},
+		Anonymous:      []string{string(utils.ROLE_READ), string(utils.ROLE_SUBMIT)},
+		AdminAnonymous: []string{string(utils.ROLE_READ), string(utils.ROLE_SUBMIT), string(utils.ROLE_ADMIN)},
+	}
+}
+
+// APIKeys returns the configured keys plus the peer token, if set, for
+// utils.NewAuthenticator.
+func (a Auth) APIKeys() []utils.APIKey {
+
+	keys := make([]utils.APIKey, 0, len(a.Keys)+1)
+	for _, k := range a.Keys {
+		keys = append(keys, utils.APIKey{Name: k.Name, Token: k.Token, Roles: Roles(k.Roles)})
+	}
+	if a.PeerToken != "" {
+		keys = append(keys, utils.APIKey{Name: "peer", Token: a.PeerToken, Roles: []utils.Role{utils.ROLE_PEER}})
+	}
+
+	return keys
+}
+
+func Roles(names []string) []utils.Role {
+	roles := make([]utils.Role, len(names))
+	for i, n := range names {
+		roles[i] = utils.Role(n)
+	}
+	return roles
+}
+
+// validate checks the auth settings of a node whose admin listener binds
+// to adminHost.
+func (a Auth) validate(adminHost string) []error {
+
+	var errs []error
+
+	checkRoles := func(where string, names []string) {
+		for _, n := range names {
+			if !utils.ValidRole(utils.Role(n)) {
+				errs = append(errs, fmt.Errorf("%s: unknown role %q", where, n))
+			}
+		}
+	}
+
+	checkRoles("auth.anonymous", a.Anonymous)
+	checkRoles("auth.admin_anonymous", a.AdminAnonymous)
+	for _, r := range []utils.Role{utils.ROLE_PEER, utils.ROLE_ADMIN} {
+		if slices.Contains(a.Anonymous, string(r)) {
+			errs = append(errs, fmt.Errorf("auth.anonymous must not grant the %s role", r))
+		}
+	}
+	if slices.Contains(a.AdminAnonymous, string(utils.ROLE_ADMIN)) && !isLoopback(adminHost) {
+		errs = append(errs, fmt.Errorf("auth.admin_anonymous grants admin on %q, which is not a loopback address", adminHost))
+	}
+
+	tokens := make(map[string]bool)
+	names := make(map[string]bool)
+	for _, k := range a.Keys {
+		if k.Name == "" || names[k.Name] {
+			errs = append(errs, fmt.Errorf("auth.keys: names must be set and unique, got %q", k.Name))
+		}
+		names[k.Name] = true
+		if len(k.Token) < MIN_TOKEN_LENGTH {
+			errs = append(errs, fmt.Errorf("auth.keys.%s: token must be at least %d characters", k.Name, MIN_TOKEN_LENGTH))
+		}
+		if tokens[k.Token] {
+			errs = append(errs, fmt.Errorf("auth.keys.%s: token is used twice", k.Name))
+		}
+		tokens[k.Token] = true
+		if len(k.Roles) == 0 {
+			errs = append(errs, fmt.Errorf("auth.keys.%s: at least one role is required", k.Name))
+		}
+		checkRoles("auth.keys."+k.Name, k.Roles)
+	}
+
+	for _, id := range a.TrustedNodes {
+		if !nodeIDPattern.MatchString(id) {
+			errs = append(errs, fmt.Errorf("auth.trusted_nodes: %q is not a node ID (40 hex digits)", id))
+		}
+	}
+
+	if a.PeerToken != "" {
+		if len(a.PeerToken) < MIN_TOKEN_LENGTH {
+			errs = append(errs, fmt.Errorf("auth.peer_token must be at least %d characters", MIN_TOKEN_LENGTH))
+		}
+		if tokens[a.PeerToken] {
+			errs = append(errs, fmt.Errorf("auth.peer_token is also an API key token"))
+		}
+	}
+
+	return errs
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
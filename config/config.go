@@ -0,0 +1,229 @@
+// Package config loads the settings of blockchain_server and
+// wallet_server. Each setting starts at its default and is overridden in
+// turn by a YAML file, an environment variable and a command line flag.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// API is the address an HTTP server listens on.
+type API struct {
+	Host string `yaml:"host"`
+	Port uint16 `yaml:"port"`
+}
+
+func (a API) Addr() string {
+	return fmt.Sprintf("%s:%d", a.Host, a.Port)
+}
+
+// Log sets the minimum level (debug, info, warn, error) and the format
+// (text, json) of server logs.
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+func DefaultLog() Log {
+	return Log{Level: "info", Format: utils.LOG_FORMAT_TEXT}
+}
+
+// Logger returns the logger l describes, writing to w.
+func (l Log) Logger(w io.Writer) (*slog.Logger, error) {
+	return utils.NewLogger(w, l.Level, l.Format)
+}
+
+// field binds one setting to its environment variable and flag. Either
+// may be empty.
+type field struct {
+	env   string
+	flag  string
+	usage string
+	ptr   any
+}
+
+// load applies the config file named by -config or envConfig, then the
+// environment, then any flags set in args, to cfg.
+func load(name string, args []string, getenv func(string) string, envConfig string, cfg any, fields []field) error {
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", "", "YAML config file (env "+envConfig+")")
+
+	byFlag := make(map[string]field)
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		usage := f.usage
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		_, isBool := f.ptr.(*bool)
+		fs.Var(&flagValue{text: format(f.ptr), isBool: isBool}, f.flag, usage)
+		byFlag[f.flag] = f
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" {
+		*path = getenv(envConfig)
+	}
+
+	if *path != "" {
+		if err := loadFile(*path, cfg); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range fields {
+		if v := getenv(f.env); f.env != "" && v != "" {
+			if err := set(f.ptr, v); err != nil {
+				return fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if f, ok := byFlag[fl.Name]; ok && err == nil {
+			if e := set(f.ptr, fl.Value.String()); e != nil {
+				err = fmt.Errorf("-%s: %w", fl.Name, e)
+			}
+		}
+	})
+
+	return err
+}
+
+// flagValue holds a flag's text until the file and environment have
+// been applied.
+type flagValue struct {
+	text   string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.text
+}
+
+func (v *flagValue) Set(s string) error {
+	v.text = s
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// loadFile decodes a YAML file over cfg. Unknown keys are rejected so a
+// misspelt setting does not silently keep its default.
+func loadFile(path string, cfg any) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+func set(ptr any, s string) error {
+
+	switch p := ptr.(type) {
+	case flag.Value:
+		return p.Set(s)
+	case *string:
+		*p = s
+	case *bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *int:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *uint8:
+		v, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return err
+		}
+		*p = uint8(v)
+	case *uint16:
+		v, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return err
+		}
+		*p = uint16(v)
+	case *float32:
+		v, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return err
+		}
+		*p = float32(v)
+	case *time.Duration:
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *[]string:
+		*p = splitList(s)
+	default:
+		return fmt.Errorf("unsupported setting type %T", ptr)
+	}
+
+	return nil
+}
+
+func format(ptr any) string {
+	switch p := ptr.(type) {
+	case flag.Value:
+		return p.String()
+	case *[]string:
+		return strings.Join(*p, ",")
+	case *time.Duration:
+		return p.String()
+	default:
+		return fmt.Sprint(reflect.ValueOf(ptr).Elem().Interface())
+	}
+}
+
+// splitList reads a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
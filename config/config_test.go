@@ -0,0 +1,264 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestLoadNodePrecedence(t *testing.T) {
+	//
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	os.WriteFile(path, []byte("api:\n  port: 5001\nnetwork:\n  difficulty: 4\n  reward: 50\nminer:\n  interval: 1m\n"), 0600)
+	//
+	cfg, err := LoadNode(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultNode().Network, cfg.Network)
+	assert.Equal(t, uint16(6000), cfg.API.GRPCPort)
+	assert.False(t, cfg.API.GRPC)
+	//
+	cfg, err = LoadNode([]string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(5001), cfg.API.Port)
+	assert.Equal(t, uint16(6001), cfg.API.GRPCPort)
+	assert.Equal(t, 4, cfg.Network.Difficulty)
+	assert.Equal(t, time.Minute, cfg.Miner.Interval)
+	//
+	vars := map[string]string{
+		"BLOCKCHAIN_CONFIG":     path,
+		"BLOCKCHAIN_DIFFICULTY": "5",
+		"BLOCKCHAIN_PEERS":      "10.0.0.1:5000, 10.0.0.2:5000",
+	}
+	cfg, err = LoadNode([]string{"-difficulty", "2", "-grpc"}, env(vars))
+	require.NoError(t, err)
+	assert.True(t, cfg.API.GRPC)
+	assert.Equal(t, 2, cfg.Network.Difficulty)
+	assert.Equal(t, float32(50), cfg.Network.Reward)
+	assert.Equal(t, []string{"10.0.0.1:5000", "10.0.0.2:5000"}, cfg.Network.Peers)
+	assert.Equal(t, cfg.Network.Peers, cfg.Params(cfg.Chains[0]).Peers)
+}
+
+func TestLoadNodeRejectsBadConfig(t *testing.T) {
+	//
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typo.yaml")
+	os.WriteFile(typo, []byte("network:\n  dificulty: 4\n"), 0600)
+	//
+	_, err := LoadNode([]string{"-config", typo}, env(nil))
+	assert.ErrorContains(t, err, "dificulty")
+	//
+	_, err = LoadNode([]string{"-config", filepath.Join(dir, "missing.yaml")}, env(nil))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	//
+	_, err = LoadNode(nil, env(map[string]string{"BLOCKCHAIN_PORT": "http"}))
+	assert.ErrorContains(t, err, "BLOCKCHAIN_PORT")
+	//
+	_, err = LoadNode([]string{"-difficulty", "0", "-peers", "nope", "-miner-address", "bob"}, env(nil))
+	assert.ErrorContains(t, err, "network.difficulty")
+	assert.ErrorContains(t, err, "network.peers")
+	assert.ErrorIs(t, err, wallet.ErrInvalidAddress)
+	//
+	_, err = LoadNode([]string{"-port", "5000", "-grpc-port", "5000"}, env(nil))
+	assert.ErrorContains(t, err, "api.grpc_port")
+	//
+	_, err = LoadNode([]string{"-log-level", "loud"}, env(nil))
+	assert.ErrorContains(t, err, `log: unknown log level "loud"`)
+	_, err = LoadNode(nil, env(map[string]string{"BLOCKCHAIN_LOG_FORMAT": "xml"}))
+	assert.ErrorContains(t, err, `log: unknown log format "xml"`)
+	//
+	_, err = LoadNode([]string{"-rate-limit", "-1", "-rate-burst", "0", "-ban-duration", "0s"}, env(nil))
+	assert.ErrorContains(t, err, "limits: rates must not be negative")
+	assert.ErrorContains(t, err, "limits.burst must be at least 1")
+	assert.ErrorContains(t, err, "limits.ban_duration must be positive")
+}
+
+func TestLoadNodeAuth(t *testing.T) {
+	//
+	cfg, err := LoadNode(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:7000", cfg.API.AdminAddr())
+	assert.Equal(t, []string{"read", "submit"}, cfg.Auth.Anonymous)
+	assert.Equal(t, filepath.Join("data", "node.key"), cfg.KeyFile())
+	assert.False(t, cfg.Auth.RequirePeerSignatures)
+	//
+	cfg, err = LoadNode([]string{"-node-key", "/etc/node.key", "-require-peer-signatures"}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/node.key", cfg.KeyFile())
+	assert.True(t, cfg.Auth.RequirePeerSignatures)
+	//
+	vars := map[string]string{
+		"BLOCKCHAIN_API_KEYS":   "ops:admin:ops-token-0123456789, feed:read+submit:feed-token-0123456789",
+		"BLOCKCHAIN_PEER_TOKEN": "peer-token-0123456789",
+	}
+	cfg, err = LoadNode(nil, env(vars))
+	require.NoError(t, err)
+	assert.Equal(t, APIKeys{
+		{Name: "ops", Token: "ops-token-0123456789", Roles: []string{"admin"}},
+		{Name: "feed", Token: "feed-token-0123456789", Roles: []string{"read", "submit"}},
+	}, cfg.Auth.Keys)
+	assert.Equal(t, "ops,feed", cfg.Auth.Keys.String())
+	assert.Len(t, cfg.Auth.APIKeys(), 3)
+	assert.Equal(t, "peer-token-0123456789", cfg.Params(cfg.Chains[0]).PeerToken)
+	//
+	_, err = LoadNode([]string{"-api-keys", "ops:root:short"}, env(nil))
+	assert.ErrorContains(t, err, `auth.keys.ops: unknown role "root"`)
+	assert.ErrorContains(t, err, "auth.keys.ops: token must be at least 16 characters")
+	//
+	_, err = LoadNode([]string{"-api-keys", "ops"}, env(nil))
+	assert.ErrorContains(t, err, `"ops" is not name:role+role:token`)
+	//
+	_, err = LoadNode([]string{"-admin-host", "0.0.0.0"}, env(nil))
+	assert.ErrorContains(t, err, "auth.admin_anonymous grants admin")
+	_, err = LoadNode([]string{"-admin-host", "0.0.0.0", "-admin-anonymous-roles", "read"}, env(nil))
+	assert.NoError(t, err)
+	//
+	_, err = LoadNode([]string{"-anonymous-roles", "read,admin"}, env(nil))
+	assert.ErrorContains(t, err, "auth.anonymous must not grant the admin role")
+	_, err = LoadNode([]string{"-anonymous-roles", "read,peer"}, env(nil))
+	assert.ErrorContains(t, err, "auth.anonymous must not grant the peer role")
+	//
+	_, err = LoadNode([]string{"-admin-port", "6000"}, env(nil))
+	assert.ErrorContains(t, err, "api.admin_port")
+	//
+	id := strings.Repeat("ab", 20)
+	cfg, err = LoadNode(nil, env(map[string]string{"BLOCKCHAIN_TRUSTED_NODES": id}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{id}, cfg.Auth.TrustedNodes)
+	_, err = LoadNode([]string{"-trusted-nodes", "node-b"}, env(nil))
+	assert.ErrorContains(t, err, `auth.trusted_nodes: "node-b" is not a node ID`)
+}
+
+// writeCert writes a self-signed CA certificate and its key to dir.
+func writeCert(t *testing.T, dir string) (string, string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "node"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	cert, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	return cert, keyFile
+}
+
+func TestLoadNodeTLS(t *testing.T) {
+	//
+	dir := t.TempDir()
+	cert, key := writeCert(t, dir)
+	//
+	cfg, err := LoadNode(nil, env(nil))
+	require.NoError(t, err)
+	assert.Nil(t, cfg.TLS.Server())
+	assert.Nil(t, cfg.Params(cfg.Chains[0]).PeerTLS)
+	//
+	cfg, err = LoadNode([]string{"-tls-cert", cert, "-tls-key", key}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, cfg.TLS.Server().ClientAuth)
+	assert.Empty(t, cfg.TLS.Peer().Certificates)
+	//
+	cfg, err = LoadNode([]string{"-tls-cert", cert, "-tls-key", key}, env(map[string]string{"BLOCKCHAIN_TLS_PEER_CA_FILE": cert}))
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, cfg.TLS.Server().ClientAuth)
+	assert.Len(t, cfg.Params(cfg.Chains[0]).PeerTLS.Certificates, 1)
+	//
+	_, err = LoadNode([]string{"-tls-cert", cert}, env(nil))
+	assert.ErrorContains(t, err, "tls: cert_file and key_file must be set together")
+	_, err = LoadNode([]string{"-tls-peer-ca", cert}, env(nil))
+	assert.ErrorContains(t, err, "tls: peer_ca_file needs cert_file and key_file")
+	_, err = LoadNode([]string{"-tls-cert", cert, "-tls-key", cert}, env(nil))
+	assert.ErrorContains(t, err, "tls:")
+	_, err = LoadNode([]string{"-tls-cert", cert, "-tls-key", key, "-tls-peer-ca", key}, env(nil))
+	assert.ErrorContains(t, err, "no PEM certificates")
+	//
+	w, err := LoadWallet([]string{"-tls-cert", cert, "-tls-key", key, "-gateway", "https://127.0.0.1:5000", "-gateway-ca", cert}, env(nil))
+	require.NoError(t, err)
+	assert.NotNil(t, w.TLS.Server())
+	assert.NotNil(t, w.GatewayTLS().RootCAs)
+	_, err = LoadWallet([]string{"-gateway-ca", cert}, env(nil))
+	assert.ErrorContains(t, err, "gateway_ca_file needs an https:// gateway")
+}
+
+func TestLoadWallet(t *testing.T) {
+	//
+	cfg, err := LoadWallet([]string{"-port", "8081"}, env(map[string]string{"WALLET_GATEWAY": "https://node.example:5000"}))
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8081", cfg.API.Addr())
+	assert.Equal(t, "https://node.example:5000", cfg.Gateway)
+	//
+	_, err = LoadWallet([]string{"-gateway", "127.0.0.1:5000"}, env(nil))
+	assert.NoError(t, err)
+	_, err = LoadWallet([]string{"-gateway", "ftp://node"}, env(nil))
+	assert.ErrorContains(t, err, "gateway")
+}
+
+func TestExampleFiles(t *testing.T) {
+	//
+	node, err := LoadNode([]string{"-config", "blockchain_server.example.yaml"}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "node-1", node.Node.Name)
+	//
+	_, err = LoadWallet([]string{"-config", "wallet_server.example.yaml"}, env(nil))
+	require.NoError(t, err)
+}
+
+func TestLoadNodeChains(t *testing.T) {
+	//
+	miner := wallet.NewWallet().BlockchainAddress()
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	os.WriteFile(path, []byte("miner:\n  address: "+miner+"\nchains:\n  - id: main\n  - id: test\n    difficulty: 1\n"), 0600)
+	//
+	cfg, err := LoadNode([]string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	require.Len(t, cfg.Chains, 2)
+	assert.Equal(t, 3, cfg.Params(cfg.Chains[0]).Difficulty)
+	assert.Equal(t, 1, cfg.Params(cfg.Chains[1]).Difficulty)
+	assert.Equal(t, miner, cfg.MinerAddress(cfg.Chains[1]))
+	//
+	cfg, err = LoadNode(nil, env(map[string]string{"BLOCKCHAIN_CHAINS": "a, b"}))
+	require.NoError(t, err)
+	assert.Equal(t, Chains{{ID: "a"}, {ID: "b"}}, cfg.Chains)
+	//
+	_, err = LoadNode([]string{"-chains", "main,main,Bad/ID"}, env(nil))
+	assert.ErrorContains(t, err, `"main" is listed twice`)
+	assert.ErrorContains(t, err, `"Bad/ID" is not a valid chain ID`)
+	//
+	os.WriteFile(path, []byte("chains:\n  - id: main\n    difficulty: 65\n"), 0600)
+	_, err = LoadNode([]string{"-config", path}, env(nil))
+	assert.ErrorContains(t, err, "chains.main.difficulty must be between 1 and 64, or 0 to use network.difficulty")
+	//
+	_, err = LoadNode([]string{"-chains", ""}, env(nil))
+	assert.ErrorContains(t, err, "at least one chain")
+	//
+	_, err = LoadNode([]string{"-mine"}, env(nil))
+	assert.ErrorContains(t, err, "miner.enabled needs a miner address")
+}
@@ -0,0 +1,351 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+// Node configures blockchain_server.
+type Node struct {
+	Node    Identity `yaml:"node"`
+	API     NodeAPI  `yaml:"api"`
+	Network Network  `yaml:"network"`
+	Miner   Miner    `yaml:"miner"`
+	Chains  Chains   `yaml:"chains"`
+	Log     Log      `yaml:"log"`
+	Auth    Auth     `yaml:"auth"`
+	Limits  Limits   `yaml:"limits"`
+	TLS     NodeTLS  `yaml:"tls"`
+}
+
+type Identity struct {
+	Name    string `yaml:"name"`
+	DataDir string `yaml:"data_dir"`
+	// KeyFile holds the node's identity key, created on first start. It
+	// defaults to node.key in the data directory.
+	KeyFile string `yaml:"key_file"`
+}
+
+// NodeAPI adds the gRPC listener, off unless GRPC is set, on a port that
+// defaults to the HTTP port + 1000, the admin listener, which defaults to
+// 127.0.0.1 on the HTTP port + 2000, and how long shutdown waits for
+// requests in flight.
+type NodeAPI struct {
+	API             `yaml:",inline"`
+	GRPC            bool          `yaml:"grpc"`
+	GRPCPort        uint16        `yaml:"grpc_port"`
+	AdminHost       string        `yaml:"admin_host"`
+	AdminPort       uint16        `yaml:"admin_port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+func (a NodeAPI) GRPCAddr() string {
+	return fmt.Sprintf("%s:%d", a.Host, a.GRPCPort)
+}
+
+func (a NodeAPI) AdminAddr() string {
+	return fmt.Sprintf("%s:%d", a.AdminHost, a.AdminPort)
+}
+
+type Network struct {
+	Difficulty     int           `yaml:"difficulty"`
+	Reward         float32       `yaml:"reward"`
+	SyncInterval   time.Duration `yaml:"sync_interval"`
+	PeerTimeout    time.Duration `yaml:"peer_timeout"`
+	PortRangeStart uint16        `yaml:"port_range_start"`
+	PortRangeEnd   uint16        `yaml:"port_range_end"`
+	IPRangeStart   uint8         `yaml:"ip_range_start"`
+	IPRangeEnd     uint8         `yaml:"ip_range_end"`
+	Peers          []string      `yaml:"peers"`
+}
+
+// Limits protect the public listeners. Rates are requests per second per
+// client IP or API key, and per peer for peer calls; zero disables one.
+type Limits struct {
+	Rate      float32 `yaml:"rate"`
+	Burst     int     `yaml:"burst"`
+	PeerRate  float32 `yaml:"peer_rate"`
+	PeerBurst int     `yaml:"peer_burst"`
+	// MaxVerifications caps concurrent transaction signature checks. Zero
+	// means one per CPU.
+	MaxVerifications int           `yaml:"max_verifications"`
+	BanScore         int           `yaml:"ban_score"`
+	BanDuration      time.Duration `yaml:"ban_duration"`
+}
+
+type Miner struct {
+	// Address receives mining rewards. Without one a chain refuses to
+	// mine.
+	Address  string        `yaml:"address"`
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Chain is one independent chain hosted by the node. The first chain is
+// also served at the root API paths. Zero values inherit from the network
+// and miner settings.
+type Chain struct {
+	ID           string `yaml:"id"`
+	MinerAddress string `yaml:"miner_address"`
+	Difficulty   int    `yaml:"difficulty"`
+}
+
+// Chains can be set from the environment or a flag as comma separated
+// chain IDs.
+type Chains []Chain
+
+func (cs *Chains) Set(s string) error {
+	*cs = nil
+	for _, id := range splitList(s) {
+		*cs = append(*cs, Chain{ID: id})
+	}
+	return nil
+}
+
+func (cs Chains) String() string {
+	ids := make([]string, len(cs))
+	for i, c := range cs {
+		ids[i] = c.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+var chainIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+func DefaultNode() *Node {
+
+	p := blockchain.DefaultParams()
+
+	return &Node{
+		Node: Identity{
+			Name:    "node",
+			DataDir: "data",
+		},
+		API: NodeAPI{
+			API:             API{Host: "0.0.0.0", Port: 5000},
+			AdminHost:       "127.0.0.1",
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Network: Network{
+			Difficulty:     p.Difficulty,
+			Reward:         p.Reward,
+			SyncInterval:   p.SyncInterval,
+			PeerTimeout:    p.PeerTimeout,
+			PortRangeStart: p.PortRangeStart,
+			PortRangeEnd:   p.PortRangeEnd,
+			IPRangeStart:   p.IPRangeStart,
+			IPRangeEnd:     p.IPRangeEnd,
+			Peers:          []string{},
+		},
+		Miner: Miner{
+			Interval: p.MiningInterval,
+		},
+		Chains: Chains{{ID: "main"}},
+		Log:    DefaultLog(),
+		Auth:   DefaultAuth(),
+		Limits: Limits{
+			Rate:        20,
+			Burst:       40,
+			PeerRate:    100,
+			PeerBurst:   200,
+			BanScore:    blockchain.BAN_SCORE,
+			BanDuration: blockchain.BAN_DURATION,
+		},
+	}
+}
+
+func (n *Node) fields() []field {
+	return []field{
+		{"BLOCKCHAIN_NODE_NAME", "name", "Node name", &n.Node.Name},
+		{"BLOCKCHAIN_DATA_DIR", "data-dir", "Data directory", &n.Node.DataDir},
+		{"BLOCKCHAIN_NODE_KEY_FILE", "node-key", "Identity key of the node, created if missing (default <data-dir>/node.key)", &n.Node.KeyFile},
+		{"BLOCKCHAIN_HOST", "host", "Interface to listen on", &n.API.Host},
+		{"BLOCKCHAIN_PORT", "port", "TCP Port Number for Blockchain Server", &n.API.Port},
+		{"BLOCKCHAIN_GRPC", "grpc", "Serve the gRPC node service for external sync clients", &n.API.GRPC},
+		{"BLOCKCHAIN_GRPC_PORT", "grpc-port", "TCP Port Number for the gRPC service (default port+1000)", &n.API.GRPCPort},
+		{"BLOCKCHAIN_ADMIN_HOST", "admin-host", "Interface of the admin listener", &n.API.AdminHost},
+		{"BLOCKCHAIN_ADMIN_PORT", "admin-port", "TCP Port Number of the admin listener (default port+2000)", &n.API.AdminPort},
+		{"BLOCKCHAIN_SHUTDOWN_TIMEOUT", "shutdown-timeout", "How long shutdown waits for requests in flight", &n.API.ShutdownTimeout},
+		{"BLOCKCHAIN_DIFFICULTY", "difficulty", "Leading zero hex digits required of a block hash", &n.Network.Difficulty},
+		{"BLOCKCHAIN_REWARD", "reward", "Mining reward", &n.Network.Reward},
+		{"BLOCKCHAIN_SYNC_INTERVAL", "sync-interval", "Interval between neighbor scans", &n.Network.SyncInterval},
+		{"BLOCKCHAIN_PEER_TIMEOUT", "peer-timeout", "Timeout of requests to peers", &n.Network.PeerTimeout},
+		{"BLOCKCHAIN_PORT_RANGE_START", "port-range-start", "First port scanned for neighbors", &n.Network.PortRangeStart},
+		{"BLOCKCHAIN_PORT_RANGE_END", "port-range-end", "Last port scanned for neighbors", &n.Network.PortRangeEnd},
+		{"BLOCKCHAIN_IP_RANGE_START", "ip-range-start", "First offset from this host's IP scanned for neighbors", &n.Network.IPRangeStart},
+		{"BLOCKCHAIN_IP_RANGE_END", "ip-range-end", "Last offset from this host's IP scanned for neighbors", &n.Network.IPRangeEnd},
+		{"BLOCKCHAIN_PEERS", "peers", "Comma separated host:port peers to always keep", &n.Network.Peers},
+		{"BLOCKCHAIN_MINER_ADDRESS", "miner-address", "Address that receives mining rewards", &n.Miner.Address},
+		{"BLOCKCHAIN_MINER_ENABLED", "mine", "Start the mining loop at startup", &n.Miner.Enabled},
+		{"BLOCKCHAIN_MINER_INTERVAL", "mining-interval", "Interval between mined blocks", &n.Miner.Interval},
+		{"BLOCKCHAIN_CHAINS", "chains", "Comma separated IDs of the chains to host; the first is the default", &n.Chains},
+		{"BLOCKCHAIN_LOG_LEVEL", "log-level", "Minimum log level: debug, info, warn or error", &n.Log.Level},
+		{"BLOCKCHAIN_LOG_FORMAT", "log-format", "Log format: text or json", &n.Log.Format},
+		{"BLOCKCHAIN_API_KEYS", "api-keys", "Comma separated name:role+role:token API keys; roles are read, submit, peer and admin", &n.Auth.Keys},
+		{"BLOCKCHAIN_ANONYMOUS_ROLES", "anonymous-roles", "Roles of requests without a key on the public listeners", &n.Auth.Anonymous},
+		{"BLOCKCHAIN_ADMIN_ANONYMOUS_ROLES", "admin-anonymous-roles", "Roles of requests without a key on the admin listener", &n.Auth.AdminAnonymous},
+		{"BLOCKCHAIN_PEER_TOKEN", "peer-token", "Token shared by the nodes of the network, sent to peers", &n.Auth.PeerToken},
+		{"BLOCKCHAIN_TRUSTED_NODES", "trusted-nodes", "Comma separated node IDs whose signed requests get the peer role", &n.Auth.TrustedNodes},
+		{"BLOCKCHAIN_REQUIRE_PEER_SIGNATURES", "require-peer-signatures", "Refuse peer calls not signed by a node identity", &n.Auth.RequirePeerSignatures},
+		{"BLOCKCHAIN_RATE_LIMIT", "rate-limit", "Requests per second per client IP or API key (0: unlimited)", &n.Limits.Rate},
+		{"BLOCKCHAIN_RATE_BURST", "rate-burst", "Requests a client may make at once", &n.Limits.Burst},
+		{"BLOCKCHAIN_PEER_RATE_LIMIT", "peer-rate-limit", "Peer calls per second per peer (0: unlimited)", &n.Limits.PeerRate},
+		{"BLOCKCHAIN_PEER_RATE_BURST", "peer-rate-burst", "Peer calls a peer may make at once", &n.Limits.PeerBurst},
+		{"BLOCKCHAIN_MAX_VERIFICATIONS", "max-verifications", "Concurrent transaction signature checks (0: one per CPU)", &n.Limits.MaxVerifications},
+		{"BLOCKCHAIN_BAN_SCORE", "ban-score", "Misbehavior points that get a peer banned", &n.Limits.BanScore},
+		{"BLOCKCHAIN_BAN_DURATION", "ban-duration", "How long a peer stays banned", &n.Limits.BanDuration},
+		{"BLOCKCHAIN_TLS_CERT_FILE", "tls-cert", "PEM certificate served on every listener and, with a peer CA, presented to peers", &n.TLS.CertFile},
+		{"BLOCKCHAIN_TLS_KEY_FILE", "tls-key", "PEM key of the TLS certificate", &n.TLS.KeyFile},
+		{"BLOCKCHAIN_TLS_PEER_CA_FILE", "tls-peer-ca", "PEM CA that signs the certificates of peers; enables mutual TLS", &n.TLS.PeerCAFile},
+	}
+}
+
+// LoadNode reads the node configuration from defaults, the file named by
+// -config or BLOCKCHAIN_CONFIG, the environment and args, then validates
+// it.
+func LoadNode(args []string, getenv func(string) string) (*Node, error) {
+
+	n := DefaultNode()
+
+	if err := load("blockchain_server", args, getenv, "BLOCKCHAIN_CONFIG", n, n.fields()); err != nil {
+		return nil, err
+	}
+
+	if n.API.GRPCPort == 0 {
+		n.API.GRPCPort = n.API.Port + 1000
+	}
+	if n.API.AdminPort == 0 {
+		n.API.AdminPort = n.API.Port + 2000
+	}
+
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+func (n *Node) Validate() error {
+
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(n.Node.Name != "", "node.name is required")
+	check(n.Node.DataDir != "", "node.data_dir is required")
+
+	check(n.API.Port != 0, "api.port is required")
+	check(n.API.GRPCPort != n.API.Port, "api.grpc_port must differ from api.port")
+	check(n.API.AdminHost != "", "api.admin_host is required")
+	check(n.API.AdminPort != n.API.Port && n.API.AdminPort != n.API.GRPCPort, "api.admin_port must differ from api.port and api.grpc_port")
+	check(n.API.ShutdownTimeout > 0, "api.shutdown_timeout must be positive")
+
+	nw := n.Network
+	check(nw.Difficulty >= 1 && nw.Difficulty <= 64, "network.difficulty must be between 1 and 64")
+	check(nw.Reward >= 0, "network.reward must not be negative")
+	check(nw.SyncInterval > 0, "network.sync_interval must be positive")
+	check(nw.PeerTimeout > 0, "network.peer_timeout must be positive")
+	check(nw.PortRangeStart != 0 && nw.PortRangeStart <= nw.PortRangeEnd && nw.PortRangeEnd < 65535,
+		"network.port_range_start..port_range_end must be a non-empty range within 1..65534")
+	check(nw.IPRangeStart <= nw.IPRangeEnd && nw.IPRangeEnd < 255,
+		"network.ip_range_start..ip_range_end must be a non-empty range within 0..254")
+	for _, p := range nw.Peers {
+		check(utils.ValidHostPort(p), "network.peers: %q is not host:port", p)
+	}
+
+	if n.Miner.Address != "" {
+		if err := wallet.ValidateAddress(n.Miner.Address); err != nil {
+			errs = append(errs, fmt.Errorf("miner.address: %w", err))
+		}
+	}
+	check(n.Miner.Interval > 0, "miner.interval must be positive")
+
+	check(len(n.Chains) > 0, "chains: at least one chain is required")
+	seen := make(map[string]bool)
+	for _, c := range n.Chains {
+		check(chainIDPattern.MatchString(c.ID), "chains: %q is not a valid chain ID (lower case letters, digits, - and _)", c.ID)
+		check(!seen[c.ID], "chains: %q is listed twice", c.ID)
+		seen[c.ID] = true
+
+		check(c.Difficulty >= 0 && c.Difficulty <= 64, "chains.%s.difficulty must be between 1 and 64, or 0 to use network.difficulty", c.ID)
+		if c.MinerAddress != "" {
+			if err := wallet.ValidateAddress(c.MinerAddress); err != nil {
+				errs = append(errs, fmt.Errorf("chains.%s.miner_address: %w", c.ID, err))
+			}
+		}
+		check(!n.Miner.Enabled || n.MinerAddress(c) != "", "chains.%s: miner.enabled needs a miner address", c.ID)
+	}
+
+	if _, err := n.Log.Logger(io.Discard); err != nil {
+		errs = append(errs, fmt.Errorf("log: %w", err))
+	}
+
+	errs = append(errs, n.Auth.validate(n.API.AdminHost)...)
+
+	l := n.Limits
+	check(l.Rate >= 0 && l.PeerRate >= 0, "limits: rates must not be negative")
+	check(l.Rate == 0 || l.Burst >= 1, "limits.burst must be at least 1")
+	check(l.PeerRate == 0 || l.PeerBurst >= 1, "limits.peer_burst must be at least 1")
+	check(l.MaxVerifications >= 0, "limits.max_verifications must not be negative")
+	check(l.BanScore >= 1, "limits.ban_score must be at least 1")
+	check(l.BanDuration > 0, "limits.ban_duration must be positive")
+
+	if err := n.TLS.load(); err != nil {
+		errs = append(errs, fmt.Errorf("tls: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// KeyFile is the path of the node's identity key.
+func (n *Node) KeyFile() string {
+	if n.Node.KeyFile != "" {
+		return n.Node.KeyFile
+	}
+	return filepath.Join(n.Node.DataDir, "node.key")
+}
+
+// MinerAddress is the reward address of chain c, which may be empty.
+func (n *Node) MinerAddress(c Chain) string {
+	if c.MinerAddress != "" {
+		return c.MinerAddress
+	}
+	return n.Miner.Address
+}
+
+// Params returns the settings of chain c for blockchain.WithParams.
+func (n *Node) Params(c Chain) blockchain.Params {
+
+	difficulty := n.Network.Difficulty
+	if c.Difficulty != 0 {
+		difficulty = c.Difficulty
+	}
+
+	return blockchain.Params{
+		Difficulty:     difficulty,
+		Reward:         n.Network.Reward,
+		MiningInterval: n.Miner.Interval,
+		SyncInterval:   n.Network.SyncInterval,
+		PeerTimeout:    n.Network.PeerTimeout,
+		PortRangeStart: n.Network.PortRangeStart,
+		PortRangeEnd:   n.Network.PortRangeEnd,
+		IPRangeStart:   n.Network.IPRangeStart,
+		IPRangeEnd:     n.Network.IPRangeEnd,
+		Peers:          n.Network.Peers,
+		PeerToken:      n.Auth.PeerToken,
+		PeerTLS:        n.TLS.Peer(),
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLS serves a listener over HTTPS when a certificate and key are set.
+// Validate loads the files, so a certificate that is missing or does not
+// match its key stops the server at startup.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	cert *tls.Certificate
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Server returns the config of a listener serving the certificate, or nil
+// when TLS is off.
+func (t TLS) Server() *tls.Config {
+	if t.cert == nil {
+		return nil
+	}
+	return &tls.Config{Certificates: []tls.Certificate{*t.cert}, MinVersion: tls.VersionTLS12}
+}
+
+func (t *TLS) load() error {
+
+	t.cert = nil
+	if t.CertFile == "" && t.KeyFile == "" {
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("cert_file and key_file must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return err
+	}
+	t.cert = &cert
+
+	return nil
+}
+
+// NodeTLS adds mutual TLS between peers. With a peer CA, the node presents
+// its certificate to the peers it calls, and callers presenting a
+// certificate signed by the CA get the peer role.
+type NodeTLS struct {
+	TLS        `yaml:",inline"`
+	PeerCAFile string `yaml:"peer_ca_file"`
+
+	peerCAs *x509.CertPool
+}
+
+// Server also asks callers for a certificate signed by the peer CA, if
+// one is set. Callers without one fall back to tokens.
+func (t NodeTLS) Server() *tls.Config {
+	c := t.TLS.Server()
+	if c != nil && t.peerCAs != nil {
+		c.ClientCAs = t.peerCAs
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return c
+}
+
+// Peer returns the config of requests to peers, or nil when TLS is off.
+// Peers are verified against the peer CA, or the system roots without
+// one.
+func (t NodeTLS) Peer() *tls.Config {
+
+	if t.cert == nil {
+		return nil
+	}
+
+	c := &tls.Config{RootCAs: t.peerCAs, MinVersion: tls.VersionTLS12}
+	if t.peerCAs != nil {
+		c.Certificates = []tls.Certificate{*t.cert}
+	}
+
+	return c
+}
+
+func (t *NodeTLS) load() error {
+
+	t.peerCAs = nil
+	if err := t.TLS.load(); err != nil {
+		return err
+	}
+	if t.PeerCAFile == "" {
+		return nil
+	}
+	if !t.Enabled() {
+		return errors.New("peer_ca_file needs cert_file and key_file")
+	}
+
+	pool, err := loadCertPool(t.PeerCAFile)
+	if err != nil {
+		return err
+	}
+	t.peerCAs = pool
+
+	return nil
+}
+
+// loadCertPool reads the PEM certificates of path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificates", path)
+	}
+
+	return pool, nil
+}
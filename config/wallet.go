@@ -0,0 +1,123 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+// Wallet configures wallet_server.
+type Wallet struct {
+	API     API    `yaml:"api"`
+	Gateway string `yaml:"gateway"`
+	// GatewayToken is the API key sent to the gateway node.
+	GatewayToken string `yaml:"gateway_token"`
+	// GatewayCAFile verifies an https gateway whose certificate the
+	// system roots do not.
+	GatewayCAFile string `yaml:"gateway_ca_file"`
+	Keystore      string `yaml:"keystore"`
+	Log           Log    `yaml:"log"`
+	TLS           TLS    `yaml:"tls"`
+
+	gatewayCAs *x509.CertPool
+}
+
+func DefaultWallet() *Wallet {
+	return &Wallet{
+		API:      API{Host: "0.0.0.0", Port: 8080},
+		Gateway:  "http://127.0.0.1:5000",
+		Keystore: "keystore",
+		Log:      DefaultLog(),
+	}
+}
+
+func (w *Wallet) fields() []field {
+	return []field{
+		{"WALLET_HOST", "host", "Interface to listen on", &w.API.Host},
+		{"WALLET_PORT", "port", "TCP Port Number for Wallet Server", &w.API.Port},
+		{"WALLET_GATEWAY", "gateway", "Blockchain Gateway", &w.Gateway},
+		{"WALLET_GATEWAY_TOKEN", "gateway-token", "API key sent to the gateway", &w.GatewayToken},
+		{"WALLET_KEYSTORE", "keystore", "Directory holding encrypted wallet keys", &w.Keystore},
+		{"WALLET_LOG_LEVEL", "log-level", "Minimum log level: debug, info, warn or error", &w.Log.Level},
+		{"WALLET_LOG_FORMAT", "log-format", "Log format: text or json", &w.Log.Format},
+		{"WALLET_TLS_CERT_FILE", "tls-cert", "PEM certificate to serve HTTPS with", &w.TLS.CertFile},
+		{"WALLET_TLS_KEY_FILE", "tls-key", "PEM key of the TLS certificate", &w.TLS.KeyFile},
+		{"WALLET_GATEWAY_CA_FILE", "gateway-ca", "PEM CA that signs the gateway's certificate", &w.GatewayCAFile},
+	}
+}
+
+// LoadWallet reads the wallet server configuration from defaults, the
+// file named by -config or WALLET_CONFIG, the environment and args, then
+// validates it.
+func LoadWallet(args []string, getenv func(string) string) (*Wallet, error) {
+
+	w := DefaultWallet()
+
+	if err := load("wallet_server", args, getenv, "WALLET_CONFIG", w, w.fields()); err != nil {
+		return nil, err
+	}
+
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// GatewayTLS returns the config of requests to the gateway, or nil to use
+// the defaults.
+func (w *Wallet) GatewayTLS() *tls.Config {
+	if w.gatewayCAs == nil {
+		return nil
+	}
+	return &tls.Config{RootCAs: w.gatewayCAs, MinVersion: tls.VersionTLS12}
+}
+
+func (w *Wallet) Validate() error {
+
+	var errs []error
+
+	if w.API.Port == 0 {
+		errs = append(errs, errors.New("api.port is required"))
+	}
+
+	// A bare host:port is accepted, as the client treats it as http.
+	if !utils.ValidHostPort(w.Gateway) {
+		if u, err := url.Parse(w.Gateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("gateway: %q is not an http(s) URL or host:port", w.Gateway))
+		}
+	}
+
+	if w.GatewayCAFile != "" && !strings.HasPrefix(w.Gateway, "https://") {
+		errs = append(errs, errors.New("gateway_ca_file needs an https:// gateway"))
+	}
+
+	if w.Keystore == "" {
+		errs = append(errs, errors.New("keystore is required"))
+	}
+
+	if _, err := w.Log.Logger(io.Discard); err != nil {
+		errs = append(errs, fmt.Errorf("log: %w", err))
+	}
+
+	if err := w.TLS.load(); err != nil {
+		errs = append(errs, fmt.Errorf("tls: %w", err))
+	}
+
+	w.gatewayCAs = nil
+	if w.GatewayCAFile != "" {
+		pool, err := loadCertPool(w.GatewayCAFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("gateway_ca_file: %w", err))
+		}
+		w.gatewayCAs = pool
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,3 @@
+package nodepb
+
+//go:generate buf generate
@@ -0,0 +1,327 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: node.proto
+
+package nodepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Node_GetPeerInfo_FullMethodName      = "/node.v1.Node/GetPeerInfo"
+	Node_AnnounceBlock_FullMethodName    = "/node.v1.Node/AnnounceBlock"
+	Node_GetHeaders_FullMethodName       = "/node.v1.Node/GetHeaders"
+	Node_GetBlocks_FullMethodName        = "/node.v1.Node/GetBlocks"
+	Node_RelayTransaction_FullMethodName = "/node.v1.Node/RelayTransaction"
+)
+
+// NodeClient is the client API for Node service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Node is an opt-in API served by blockchain_server next to the REST API
+// for external clients: they announce blocks, sync headers and bodies, and
+// relay transactions with it. Nodes themselves peer over HTTP and never
+// dial it.
+type NodeClient interface {
+	GetPeerInfo(ctx context.Context, in *PeerInfoRequest, opts ...grpc.CallOption) (*PeerInfo, error)
+	AnnounceBlock(ctx context.Context, in *BlockAnnouncement, opts ...grpc.CallOption) (*AnnounceResponse, error)
+	GetHeaders(ctx context.Context, in *HeadersRequest, opts ...grpc.CallOption) (Node_GetHeadersClient, error)
+	GetBlocks(ctx context.Context, in *BlocksRequest, opts ...grpc.CallOption) (Node_GetBlocksClient, error)
+	RelayTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*RelayResponse, error)
+}
+
+type nodeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNodeClient(cc grpc.ClientConnInterface) NodeClient {
+	return &nodeClient{cc}
+}
+
+func (c *nodeClient) GetPeerInfo(ctx context.Context, in *PeerInfoRequest, opts ...grpc.CallOption) (*PeerInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PeerInfo)
+	err := c.cc.Invoke(ctx, Node_GetPeerInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) AnnounceBlock(ctx context.Context, in *BlockAnnouncement, opts ...grpc.CallOption) (*AnnounceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnnounceResponse)
+	err := c.cc.Invoke(ctx, Node_AnnounceBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetHeaders(ctx context.Context, in *HeadersRequest, opts ...grpc.CallOption) (Node_GetHeadersClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[0], Node_GetHeaders_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeGetHeadersClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_GetHeadersClient interface {
+	Recv() (*BlockHeader, error)
+	grpc.ClientStream
+}
+
+type nodeGetHeadersClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeGetHeadersClient) Recv() (*BlockHeader, error) {
+	m := new(BlockHeader)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nodeClient) GetBlocks(ctx context.Context, in *BlocksRequest, opts ...grpc.CallOption) (Node_GetBlocksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[1], Node_GetBlocks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeGetBlocksClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_GetBlocksClient interface {
+	Recv() (*Block, error)
+	grpc.ClientStream
+}
+
+type nodeGetBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeGetBlocksClient) Recv() (*Block, error) {
+	m := new(Block)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nodeClient) RelayTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*RelayResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelayResponse)
+	err := c.cc.Invoke(ctx, Node_RelayTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NodeServer is the server API for Node service.
+// All implementations must embed UnimplementedNodeServer
+// for forward compatibility
+//
+// Node is an opt-in API served by blockchain_server next to the REST API
+// for external clients: they announce blocks, sync headers and bodies, and
+// relay transactions with it. Nodes themselves peer over HTTP and never
+// dial it.
+type NodeServer interface {
+	GetPeerInfo(context.Context, *PeerInfoRequest) (*PeerInfo, error)
+	AnnounceBlock(context.Context, *BlockAnnouncement) (*AnnounceResponse, error)
+	GetHeaders(*HeadersRequest, Node_GetHeadersServer) error
+	GetBlocks(*BlocksRequest, Node_GetBlocksServer) error
+	RelayTransaction(context.Context, *Transaction) (*RelayResponse, error)
+	mustEmbedUnimplementedNodeServer()
+}
+
+// UnimplementedNodeServer must be embedded to have forward compatible implementations.
+type UnimplementedNodeServer struct {
+}
+
+func (UnimplementedNodeServer) GetPeerInfo(context.Context, *PeerInfoRequest) (*PeerInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeerInfo not implemented")
+}
+func (UnimplementedNodeServer) AnnounceBlock(context.Context, *BlockAnnouncement) (*AnnounceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnnounceBlock not implemented")
+}
+func (UnimplementedNodeServer) GetHeaders(*HeadersRequest, Node_GetHeadersServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetHeaders not implemented")
+}
+func (UnimplementedNodeServer) GetBlocks(*BlocksRequest, Node_GetBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetBlocks not implemented")
+}
+func (UnimplementedNodeServer) RelayTransaction(context.Context, *Transaction) (*RelayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RelayTransaction not implemented")
+}
+func (UnimplementedNodeServer) mustEmbedUnimplementedNodeServer() {}
+
+// UnsafeNodeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NodeServer will
+// result in compilation errors.
+type UnsafeNodeServer interface {
+	mustEmbedUnimplementedNodeServer()
+}
+
+func RegisterNodeServer(s grpc.ServiceRegistrar, srv NodeServer) {
+	s.RegisterService(&Node_ServiceDesc, srv)
+}
+
+func _Node_GetPeerInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeerInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetPeerInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetPeerInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetPeerInfo(ctx, req.(*PeerInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_AnnounceBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockAnnouncement)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).AnnounceBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_AnnounceBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).AnnounceBlock(ctx, req.(*BlockAnnouncement))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetHeaders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HeadersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).GetHeaders(m, &nodeGetHeadersServer{ServerStream: stream})
+}
+
+type Node_GetHeadersServer interface {
+	Send(*BlockHeader) error
+	grpc.ServerStream
+}
+
+type nodeGetHeadersServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeGetHeadersServer) Send(m *BlockHeader) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Node_GetBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).GetBlocks(m, &nodeGetBlocksServer{ServerStream: stream})
+}
+
+type Node_GetBlocksServer interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type nodeGetBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeGetBlocksServer) Send(m *Block) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Node_RelayTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).RelayTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_RelayTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).RelayTransaction(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Node_ServiceDesc is the grpc.ServiceDesc for Node service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Node_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "node.v1.Node",
+	HandlerType: (*NodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPeerInfo",
+			Handler:    _Node_GetPeerInfo_Handler,
+		},
+		{
+			MethodName: "AnnounceBlock",
+			Handler:    _Node_AnnounceBlock_Handler,
+		},
+		{
+			MethodName: "RelayTransaction",
+			Handler:    _Node_RelayTransaction_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetHeaders",
+			Handler:       _Node_GetHeaders_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetBlocks",
+			Handler:       _Node_GetBlocks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "node.proto",
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type Role string
+
+const (
+	// ROLE_READ queries chain state.
+	ROLE_READ Role = "read"
+	// ROLE_SUBMIT submits signed transactions.
+	ROLE_SUBMIT Role = "submit"
+	// ROLE_PEER calls the endpoints other nodes use: transaction relay,
+	// pool clearing and consensus.
+	ROLE_PEER Role = "peer"
+	// ROLE_ADMIN controls the node and holds every other role.
+	ROLE_ADMIN Role = "admin"
+
+	API_KEY_HEADER = "X-API-Key"
+)
+
+var ROLES = []Role{ROLE_READ, ROLE_SUBMIT, ROLE_PEER, ROLE_ADMIN}
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+func ValidRole(r Role) bool {
+	return slices.Contains(ROLES, r)
+}
+
+// APIKey grants roles to requests presenting Token.
+type APIKey struct {
+	Name  string
+	Token string
+	Roles []Role
+}
+
+// Principal is who a request authenticated as. Anonymous requests have
+// an empty Name.
+type Principal struct {
+	Name  string
+	Roles []Role
+}
+
+// Has reports whether p holds role. Admins hold every role, and the
+// submit and peer roles include read.
+func (p *Principal) Has(role Role) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.Roles {
+		if r == role || r == ROLE_ADMIN || (role == ROLE_READ && (r == ROLE_SUBMIT || r == ROLE_PEER)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------------
+
+// Authenticator maps tokens to principals. Tokens are kept as SHA-256
+// digests, so lookups do not compare secrets byte by byte.
+type Authenticator struct {
+	keys      map[[32]byte]*Principal
+	anonymous *Principal
+	certRoles []Role
+	nodeRoles []Role
+	trusted   func(id string) bool
+}
+
+// NewAuthenticator grants anonymous to requests without a token.
+func NewAuthenticator(keys []APIKey, anonymous []Role) *Authenticator {
+
+	a := &Authenticator{
+		keys:      make(map[[32]byte]*Principal),
+		anonymous: &Principal{Roles: anonymous},
+	}
+
+	for _, k := range keys {
+		a.keys[sha256.Sum256([]byte(k.Token))] = &Principal{Name: k.Name, Roles: k.Roles}
+	}
+
+	return a
+}
+
+// WithClientCerts grants roles to callers presenting a client certificate
+// the listener verified. They are named after its common name.
+func (a *Authenticator) WithClientCerts(roles []Role) *Authenticator {
+	a.certRoles = roles
+	return a
+}
+
+// WithNodeSignatures grants roles to callers whose requests carry a valid
+// signature by a node trusted reports true for. They are named after the
+// signing node ID. A node ID only proves the caller holds a key, so
+// signatures from other nodes grant nothing.
+func (a *Authenticator) WithNodeSignatures(roles []Role, trusted func(id string) bool) *Authenticator {
+	a.nodeRoles = roles
+	a.trusted = trusted
+	return a
+}
+
+// AuthenticateCert resolves the verified client certificate of a TLS
+// connection, returning nil if there is none or certificates grant no
+// roles.
+func (a *Authenticator) AuthenticateCert(cs *tls.ConnectionState) *Principal {
+
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(a.certRoles) == 0 {
+		return nil
+	}
+
+	return &Principal{Name: "cert:" + cs.VerifiedChains[0][0].Subject.CommonName, Roles: a.certRoles}
+}
+
+// AuthenticateNode resolves the node ID VerifyNodeSignatures recorded in
+// ctx, returning nil if the request was not signed, the signer is not
+// trusted or signatures grant no roles.
+func (a *Authenticator) AuthenticateNode(ctx context.Context) *Principal {
+
+	id := NodeIDFromContext(ctx)
+	if id == "" || len(a.nodeRoles) == 0 || a.trusted == nil || !a.trusted(id) {
+		return nil
+	}
+
+	return &Principal{Name: "node:" + id, Roles: a.nodeRoles}
+}
+
+// AuthenticateCaller resolves a token or, without one, the client
+// certificate cs and then the node signature recorded in ctx, falling
+// back to the anonymous principal.
+func (a *Authenticator) AuthenticateCaller(ctx context.Context, token string, cs *tls.ConnectionState) (*Principal, error) {
+
+	if token == "" {
+		if p := a.AuthenticateCert(cs); p != nil {
+			return p, nil
+		}
+		if p := a.AuthenticateNode(ctx); p != nil {
+			return p, nil
+		}
+	}
+
+	return a.Authenticate(token)
+}
+
+// Authenticate resolves a token, which may be empty for an anonymous
+// caller.
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+
+	if token == "" {
+		return a.anonymous, nil
+	}
+
+	p, ok := a.keys[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	return p, nil
+}
+
+// RequestToken reads "Authorization: Bearer <token>" or the X-API-Key
+// header.
+func RequestToken(req *http.Request) string {
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return req.Header.Get(API_KEY_HEADER)
+}
+
+type principalKey struct{}
+
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Middleware authenticates every request with AuthenticateCaller,
+// refusing unknown tokens with 401. It runs after VerifyNodeSignatures.
+// Handlers check roles with Require or PrincipalFromContext.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		p, err := a.AuthenticateCaller(req.Context(), RequestToken(req), req.TLS)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(JsonStatus(err.Error()))
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(WithPrincipal(req.Context(), p)))
+	})
+}
+
+// Require lets requests through only if their principal holds role:
+// anonymous callers get 401 and authenticated ones 403.
+func Require(role Role, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		p := PrincipalFromContext(req.Context())
+		if p.Has(role) {
+			next(w, req)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if p == nil || p.Name == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write(JsonStatus(string(role) + " role required"))
+	})
+}
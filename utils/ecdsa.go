@@ -1,56 +1,88 @@
 package utils
 
 import (
-	"crypto/ecdsa"
 	"crypto/elliptic"
-	"encoding/hex"
+	"encoding/asn1"
+	"errors"
 	"fmt"
 	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+var (
+	ErrInvalidPublicKey  = errors.New("invalid public key")
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	ErrInvalidSignature  = errors.New("invalid signature")
 )
 
 type Signature struct {
-	R *big.Int
-	S *big.Int
+	Algorithm Algorithm
+	R         *big.Int
+	S         *big.Int
 }
 
 func (s *Signature) String() string {
-	return fmt.Sprintf("%064x%064x", s.R, s.S)
+	if s.Algorithm == ALG_P256 || s.Algorithm == "" {
+		return fmt.Sprintf("%064x%064x", s.R, s.S)
+	}
+	return fmt.Sprintf("%s:%064x%064x", s.Algorithm, s.R, s.S)
 }
 
-func String2BigIntTuple(s string) (big.Int, big.Int) {
+// curveOrder returns the group order of an ECDSA algorithm, or nil for
+// schemes without one.
+func curveOrder(alg Algorithm) *big.Int {
+	switch alg {
+	case ALG_P256, "":
+		return elliptic.P256().Params().N
+	case ALG_SECP256K1:
+		return secp256k1.S256().N
+	}
+	return nil
+}
+
+// normalizeS flips s into the lower half of the group order, so every
+// ECDSA signature has exactly one accepted encoding.
+func normalizeS(s *big.Int, n *big.Int) *big.Int {
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return new(big.Int).Sub(n, s)
+	}
+	return s
+}
 
-	bx, _ := hex.DecodeString(s[:64])
-	by, _ := hex.DecodeString(s[64:])
+// checkECDSA rejects r and s outside [1, n) and high-S signatures.
+func checkECDSA(sig *Signature) error {
 
-	var bix big.Int
-	var biy big.Int
+	n := curveOrder(sig.Algorithm)
+	if n == nil {
+		return nil
+	}
 
-	_ = bix.SetBytes(bx)
-	_ = biy.SetBytes(by)
+	if sig.R.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(n) >= 0 {
+		return fmt.Errorf("%w: r or s out of range", ErrInvalidSignature)
+	}
+	if sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return fmt.Errorf("%w: s is not in the lower half of the curve order", ErrInvalidSignature)
+	}
 
-	return bix, biy
+	return nil
 }
 
-func SignatureFromString(s string) *Signature {
-	x, y := String2BigIntTuple(s)
-	return &Signature{&x, &y}
-}
+// parseDER reads an ASN.1 DER ECDSA-Sig-Value, SEQUENCE { r INTEGER,
+// s INTEGER }, with no trailing data.
+func parseDER(b []byte) (*big.Int, *big.Int, error) {
 
-func PublicKeyFromString(s string) *ecdsa.PublicKey {
-	x, y := String2BigIntTuple(s)
-	return &ecdsa.PublicKey{
-		Curve: elliptic.P256(),
-		X:     &x,
-		Y:     &y,
+	var v struct {
+		R, S *big.Int
 	}
-}
 
-func PrivateKeyFromString(s string, publicKey *ecdsa.PublicKey) *ecdsa.PrivateKey {
-	b, _ := hex.DecodeString(s[:])
-	var bi big.Int
-	_ = bi.SetBytes(b)
-	return &ecdsa.PrivateKey{
-		PublicKey: *publicKey,
-		D:         &bi,
+	rest, err := asn1.Unmarshal(b, &v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
 	}
+	if len(rest) != 0 {
+		return nil, nil, fmt.Errorf("%w: trailing data after DER signature", ErrInvalidSignature)
+	}
+
+	return v.R, v.S, nil
 }
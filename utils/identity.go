@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Nodes sign the messages they send each other with a persistent Ed25519
+// key. A node is known by its ID, derived from the public key, so peers can
+// attribute and ban it however it reaches them.
+
+// Peer message signatures travel in these headers, or the same keys in
+// lower case as gRPC metadata.
+const (
+	NODE_KEY_HEADER       = "X-Node-Key"
+	NODE_TIMESTAMP_HEADER = "X-Node-Timestamp"
+	NODE_NONCE_HEADER     = "X-Node-Nonce"
+	NODE_SIGNATURE_HEADER = "X-Node-Signature"
+
+	// MAX_CLOCK_SKEW bounds how old, or how far ahead, a signed message
+	// may be.
+	MAX_CLOCK_SKEW = 5 * time.Minute
+)
+
+var (
+	ErrUnsigned       = errors.New("message not signed")
+	ErrNodeSignature  = errors.New("invalid node signature")
+	ErrStaleSignature = errors.New("node signature outside the allowed clock skew")
+	ErrReplayed       = errors.New("node signature already used")
+)
+
+type NodeIdentity struct {
+	key PrivateKey
+	id  string
+}
+
+func NewNodeIdentity(key PrivateKey) *NodeIdentity {
+	return &NodeIdentity{key: key, id: NodeID(key.Public())}
+}
+
+// GenerateNodeIdentity returns a new Ed25519 identity, which lasts as long
+// as the process unless saved.
+func GenerateNodeIdentity() (*NodeIdentity, error) {
+	key, err := GenerateKey(ALG_ED25519)
+	if err != nil {
+		return nil, err
+	}
+	return NewNodeIdentity(key), nil
+}
+
+// LoadNodeIdentity reads the key at path, creating it readable by the
+// owner only if it does not exist yet.
+func LoadNodeIdentity(path string) (*NodeIdentity, error) {
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return createNodeIdentity(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePrivateKey(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return NewNodeIdentity(key), nil
+}
+
+func createNodeIdentity(path string) (*NodeIdentity, error) {
+
+	id, err := GenerateNodeIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(f, id.key.String()+"\n"); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return id, f.Close()
+}
+
+// NodeID is the hex of the first 20 bytes of the SHA-256 of a node's
+// public key.
+func NodeID(pub PublicKey) string {
+	h := sha256.Sum256(pub.Bytes())
+	return hex.EncodeToString(h[:20])
+}
+
+func (id *NodeIdentity) ID() string {
+	return id.id
+}
+
+func (id *NodeIdentity) PublicKey() PublicKey {
+	return id.key.Public()
+}
+
+// Sign signs a SHA-256 digest.
+func (id *NodeIdentity) Sign(hash []byte) (*Signature, error) {
+	return id.key.Sign(hash)
+}
+
+// ------------------------------------------------------------------
+
+// MessageSignature signs a request to a peer: its method, target (the
+// request URI or gRPC method), body, time and a random nonce, so no two
+// requests share a signature.
+type MessageSignature struct {
+	Key       string
+	Timestamp string
+	Nonce     string
+	Signature string
+}
+
+func messageDigest(method string, target string, timestamp string, nonce string, body []byte) []byte {
+	bh := sha256.Sum256(body)
+	h := sha256.Sum256([]byte(method + "\n" + target + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bh[:])))
+	return h[:]
+}
+
+func (id *NodeIdentity) SignMessage(method string, target string, body []byte, now time.Time) (*MessageSignature, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	ts, nonce := strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(b)
+
+	sig, err := id.key.Sign(messageDigest(method, target, ts, nonce, body))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageSignature{Key: id.key.Public().String(), Timestamp: ts, Nonce: nonce, Signature: sig.String()}, nil
+}
+
+// Verify checks the signature of a message received at now and returns
+// the ID of the node that signed it.
+func (ms *MessageSignature) Verify(method string, target string, body []byte, now time.Time) (string, error) {
+
+	if *ms == (MessageSignature{}) {
+		return "", ErrUnsigned
+	}
+	if len(ms.Nonce) < 16 {
+		return "", fmt.Errorf("%w: nonce required", ErrNodeSignature)
+	}
+
+	pub, err := ParsePublicKey(ms.Key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNodeSignature, err)
+	}
+	sig, err := ParseSignature(ms.Signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNodeSignature, err)
+	}
+	ts, err := strconv.ParseInt(ms.Timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad timestamp", ErrNodeSignature)
+	}
+
+	if d := now.Sub(time.Unix(ts, 0)); d > MAX_CLOCK_SKEW || d < -MAX_CLOCK_SKEW {
+		return "", ErrStaleSignature
+	}
+	if !pub.Verify(messageDigest(method, target, ms.Timestamp, ms.Nonce, body), sig) {
+		return "", ErrNodeSignature
+	}
+
+	return NodeID(pub), nil
+}
+
+// SignRequest sets the signature headers of a request whose body is body.
+func (id *NodeIdentity) SignRequest(req *http.Request, body []byte) error {
+
+	ms, err := id.SignMessage(req.Method, req.URL.RequestURI(), body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(NODE_KEY_HEADER, ms.Key)
+	req.Header.Set(NODE_TIMESTAMP_HEADER, ms.Timestamp)
+	req.Header.Set(NODE_NONCE_HEADER, ms.Nonce)
+	req.Header.Set(NODE_SIGNATURE_HEADER, ms.Signature)
+
+	return nil
+}
+
+// ------------------------------------------------------------------
+
+// ReplayCache remembers the signatures a node accepted for as long as
+// their timestamps are within MAX_CLOCK_SKEW, so a captured request cannot
+// be sent again.
+type ReplayCache struct {
+	mux   sync.Mutex
+	seen  map[string]time.Time
+	swept time.Time
+}
+
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{seen: make(map[string]time.Time)}
+}
+
+// Check records a verified signature, failing with ErrReplayed if it was
+// recorded before.
+func (rc *ReplayCache) Check(ms *MessageSignature, now time.Time) error {
+
+	ts, err := strconv.ParseInt(ms.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrNodeSignature)
+	}
+	key := ms.Key + "/" + ms.Signature
+
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+
+	if now.Sub(rc.swept) > MAX_CLOCK_SKEW {
+		for k, expires := range rc.seen {
+			if now.After(expires) {
+				delete(rc.seen, k)
+			}
+		}
+		rc.swept = now
+	}
+
+	if _, ok := rc.seen[key]; ok {
+		return ErrReplayed
+	}
+	rc.seen[key] = time.Unix(ts, 0).Add(MAX_CLOCK_SKEW)
+
+	return nil
+}
+
+// ------------------------------------------------------------------
+
+type nodeIDKey struct{}
+
+func WithNodeID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, nodeIDKey{}, id)
+}
+
+// NodeIDFromContext is the ID of the node that signed the request, or
+// empty if it was not signed.
+func NodeIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(nodeIDKey{}).(string)
+	return id
+}
+
+// VerifyNodeSignatures checks the signature headers of every request that
+// carries them, refusing bad or replayed signatures with 401 and recording
+// the signer's node ID in the context. The body of a signed request is
+// read up to maxBody bytes.
+func VerifyNodeSignatures(maxBody int64, replays *ReplayCache) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			ms := &MessageSignature{
+				Key:       req.Header.Get(NODE_KEY_HEADER),
+				Timestamp: req.Header.Get(NODE_TIMESTAMP_HEADER),
+				Nonce:     req.Header.Get(NODE_NONCE_HEADER),
+				Signature: req.Header.Get(NODE_SIGNATURE_HEADER),
+			}
+			if *ms == (MessageSignature{}) {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBody))
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write(JsonStatus(err.Error()))
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			id, err := ms.Verify(req.Method, req.URL.RequestURI(), body, now)
+			if err == nil {
+				err = replays.Check(ms, now)
+			}
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write(JsonStatus(err.Error()))
+				return
+			}
+
+			next.ServeHTTP(w, req.WithContext(WithNodeID(req.Context(), id)))
+		})
+	}
+}
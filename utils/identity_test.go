@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadNodeIdentity(t *testing.T) {
+	//
+	path := filepath.Join(t.TempDir(), "node.key")
+	id, err := LoadNodeIdentity(path)
+	require.NoError(t, err)
+	assert.Len(t, id.ID(), 40)
+	assert.Equal(t, ALG_ED25519, id.PublicKey().Algorithm())
+	//
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	//
+	again, err := LoadNodeIdentity(path)
+	require.NoError(t, err)
+	assert.Equal(t, id.ID(), again.ID())
+	//
+	os.WriteFile(path, []byte("not a key"), 0600)
+	_, err = LoadNodeIdentity(path)
+	assert.Error(t, err)
+}
+
+func TestMessageSignature(t *testing.T) {
+	//
+	id, err := GenerateNodeIdentity()
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	body := []byte(`{"value":1}`)
+	//
+	ms, err := id.SignMessage("PUT", "/v1/transactions", body, now)
+	require.NoError(t, err)
+	signer, err := ms.Verify("PUT", "/v1/transactions", body, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, id.ID(), signer)
+	//
+	_, err = ms.Verify("PUT", "/v1/transactions", []byte(`{"value":2}`), now)
+	assert.ErrorIs(t, err, ErrNodeSignature)
+	_, err = ms.Verify("PUT", "/v1/consensus", body, now)
+	assert.ErrorIs(t, err, ErrNodeSignature)
+	_, err = ms.Verify("PUT", "/v1/transactions", body, now.Add(MAX_CLOCK_SKEW+time.Second))
+	assert.ErrorIs(t, err, ErrStaleSignature)
+	_, err = (&MessageSignature{}).Verify("PUT", "/v1/transactions", body, now)
+	assert.ErrorIs(t, err, ErrUnsigned)
+	//
+	again, err := id.SignMessage("PUT", "/v1/transactions", body, now)
+	require.NoError(t, err)
+	assert.NotEqual(t, ms.Signature, again.Signature)
+	again.Nonce = ms.Nonce
+	_, err = again.Verify("PUT", "/v1/transactions", body, now)
+	assert.ErrorIs(t, err, ErrNodeSignature)
+}
+
+func TestReplayCache(t *testing.T) {
+	//
+	id, err := GenerateNodeIdentity()
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	ms, err := id.SignMessage("PUT", "/v1/transactions", nil, now)
+	require.NoError(t, err)
+	rc := NewReplayCache()
+	//
+	assert.NoError(t, rc.Check(ms, now))
+	assert.ErrorIs(t, rc.Check(ms, now.Add(time.Minute)), ErrReplayed)
+	//
+	// Entries are dropped once their timestamp is stale anyway.
+	later := now.Add(2*MAX_CLOCK_SKEW + time.Second)
+	other, err := id.SignMessage("PUT", "/v1/transactions", nil, later)
+	require.NoError(t, err)
+	assert.NoError(t, rc.Check(other, later))
+	assert.Len(t, rc.seen, 1)
+}
+
+func TestVerifyNodeSignatures(t *testing.T) {
+	//
+	id, err := GenerateNodeIdentity()
+	require.NoError(t, err)
+	var signer, got string
+	h := VerifyNodeSignatures(1<<10, NewReplayCache())(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		signer, got = NodeIDFromContext(req.Context()), string(b)
+	}))
+	//
+	req := httptest.NewRequest("PUT", "/v1/transactions?x=1", strings.NewReader("body"))
+	require.NoError(t, id.SignRequest(req, []byte("body")))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, id.ID(), signer)
+	assert.Equal(t, "body", got)
+	//
+	replayed := httptest.NewRequest("PUT", "/v1/transactions?x=1", strings.NewReader("body"))
+	replayed.Header = req.Header.Clone()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, replayed)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrReplayed.Error())
+	//
+	req = httptest.NewRequest("PUT", "/v1/transactions?x=1", strings.NewReader("other"))
+	require.NoError(t, id.SignRequest(req, []byte("body")))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	//
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/v1/transactions", strings.NewReader("body")))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, signer)
+}
@@ -0,0 +1,378 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Keys and signatures are encoded as "<algorithm>:<hex>". P-256 is the
+// original scheme and is written untagged, so existing keys, signatures
+// and addresses keep their form; untagged input is read as P-256.
+type Algorithm string
+
+const (
+	ALG_P256      Algorithm = "p256"
+	ALG_SECP256K1 Algorithm = "secp256k1"
+	ALG_ED25519   Algorithm = "ed25519"
+)
+
+// Valid reports whether alg is a supported scheme. The empty algorithm
+// is read as P-256.
+func (alg Algorithm) Valid() bool {
+	switch alg {
+	case ALG_P256, ALG_SECP256K1, ALG_ED25519, "":
+		return true
+	}
+	return false
+}
+
+type PublicKey interface {
+	Algorithm() Algorithm
+	// Bytes is the fixed-width encoding: X||Y for the ECDSA curves and
+	// the 32-byte point for Ed25519.
+	Bytes() []byte
+	String() string
+	// Verify checks sig over a SHA-256 transaction hash.
+	Verify(hash []byte, sig *Signature) bool
+}
+
+type PrivateKey interface {
+	Algorithm() Algorithm
+	Bytes() []byte
+	String() string
+	Public() PublicKey
+	Sign(hash []byte) (*Signature, error)
+}
+
+func GenerateKey(alg Algorithm) (PrivateKey, error) {
+	switch alg {
+	case ALG_P256, "":
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewP256PrivateKey(k), nil
+	case ALG_SECP256K1:
+		k, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		return &Secp256k1PrivateKey{k}, nil
+	case ALG_ED25519:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return Ed25519PrivateKey(k), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidPrivateKey, alg)
+	}
+}
+
+func splitTag(s string) (Algorithm, string) {
+	if tag, body, ok := strings.Cut(s, ":"); ok {
+		return Algorithm(tag), body
+	}
+	return ALG_P256, s
+}
+
+func tagged(alg Algorithm, b []byte) string {
+	if alg == ALG_P256 {
+		return hex.EncodeToString(b)
+	}
+	return string(alg) + ":" + hex.EncodeToString(b)
+}
+
+// ParsePublicKey reads a tagged public key. ECDSA keys may be given as
+// raw X||Y, SEC1 uncompressed (0x04||X||Y) or SEC1 compressed, and must
+// lie on their curve.
+func ParsePublicKey(s string) (PublicKey, error) {
+
+	alg, body := splitTag(s)
+
+	b, err := hex.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad hex: %v", ErrInvalidPublicKey, err)
+	}
+
+	switch alg {
+	case ALG_P256:
+		k, err := parseP256PublicKey(b)
+		if err != nil {
+			return nil, err
+		}
+		return &P256PublicKey{k}, nil
+	case ALG_SECP256K1:
+		if len(b) == 64 {
+			b = append([]byte{0x04}, b...)
+		}
+		k, err := secp256k1.ParsePubKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		return &Secp256k1PublicKey{k}, nil
+	case ALG_ED25519:
+		if len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: ed25519 key is %d bytes, want %d", ErrInvalidPublicKey, len(b), ed25519.PublicKeySize)
+		}
+		return Ed25519PublicKey(b), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidPublicKey, alg)
+	}
+}
+
+func parseP256PublicKey(b []byte) (*ecdsa.PublicKey, error) {
+
+	curve := elliptic.P256()
+
+	switch {
+	case len(b) == 33 && (b[0] == 0x02 || b[0] == 0x03):
+		x, y := elliptic.UnmarshalCompressed(curve, b)
+		if x == nil {
+			return nil, fmt.Errorf("%w: point is not on p256", ErrInvalidPublicKey)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case len(b) == 64:
+		b = append([]byte{0x04}, b...)
+	case len(b) == 65 && b[0] == 0x04:
+	default:
+		return nil, fmt.Errorf("%w: p256 key has unexpected length %d", ErrInvalidPublicKey, len(b))
+	}
+
+	k, err := ecdsa.ParseUncompressedPublicKey(curve, b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: point is not on p256", ErrInvalidPublicKey)
+	}
+	return k, nil
+}
+
+// ParsePrivateKey reads a private key in the tagged encoding. Leading
+// zero bytes of an untagged P-256 scalar may be omitted.
+func ParsePrivateKey(s string) (PrivateKey, error) {
+
+	alg, body := splitTag(s)
+
+	b, err := hex.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad hex: %v", ErrInvalidPrivateKey, err)
+	}
+
+	return PrivateKeyFromBytes(alg, b)
+}
+
+func PrivateKeyFromBytes(alg Algorithm, b []byte) (PrivateKey, error) {
+	switch alg {
+	case ALG_P256, "":
+		if len(b) == 0 || len(b) > 32 {
+			return nil, fmt.Errorf("%w: p256 key has unexpected length %d", ErrInvalidPrivateKey, len(b))
+		}
+		k, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), leftPad(b, 32))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+		}
+		return NewP256PrivateKey(k), nil
+	case ALG_SECP256K1:
+		if len(b) != secp256k1.PrivKeyBytesLen {
+			return nil, fmt.Errorf("%w: secp256k1 key has unexpected length %d", ErrInvalidPrivateKey, len(b))
+		}
+		var d secp256k1.ModNScalar
+		if d.SetByteSlice(b) || d.IsZero() {
+			return nil, fmt.Errorf("%w: secp256k1 scalar out of range", ErrInvalidPrivateKey)
+		}
+		return &Secp256k1PrivateKey{secp256k1.NewPrivateKey(&d)}, nil
+	case ALG_ED25519:
+		if len(b) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: ed25519 key has unexpected length %d", ErrInvalidPrivateKey, len(b))
+		}
+		return Ed25519PrivateKey(ed25519.NewKeyFromSeed(b)), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidPrivateKey, alg)
+	}
+}
+
+// ParseSignature reads a tagged signature. ECDSA signatures are either
+// 64 raw bytes R||S or ASN.1 DER, and must be low-S; Ed25519 signatures
+// are always 64 raw bytes.
+func ParseSignature(s string) (*Signature, error) {
+
+	alg, body := splitTag(s)
+	if !alg.Valid() {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, alg)
+	}
+
+	b, err := hex.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad hex: %v", ErrInvalidSignature, err)
+	}
+
+	sig := &Signature{Algorithm: alg}
+
+	switch {
+	case len(b) == 64:
+		sig.R = new(big.Int).SetBytes(b[:32])
+		sig.S = new(big.Int).SetBytes(b[32:])
+	case alg == ALG_ED25519:
+		return nil, fmt.Errorf("%w: ed25519 signature is %d bytes, want 64", ErrInvalidSignature, len(b))
+	default:
+		if sig.R, sig.S, err = parseDER(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkECDSA(sig); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+func leftPad(b []byte, size int) []byte {
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+func sigBytes(sig *Signature) []byte {
+	b := make([]byte, 64)
+	sig.R.FillBytes(b[:32])
+	sig.S.FillBytes(b[32:])
+	return b
+}
+
+// ------------------------------------------------------------------
+
+type P256PublicKey struct {
+	key *ecdsa.PublicKey
+}
+
+func NewP256PublicKey(k *ecdsa.PublicKey) *P256PublicKey {
+	return &P256PublicKey{k}
+}
+
+func (k *P256PublicKey) ECDSA() *ecdsa.PublicKey { return k.key }
+func (k *P256PublicKey) Algorithm() Algorithm    { return ALG_P256 }
+func (k *P256PublicKey) String() string          { return tagged(ALG_P256, k.Bytes()) }
+
+func (k *P256PublicKey) Bytes() []byte {
+	b := make([]byte, 64)
+	k.key.X.FillBytes(b[:32])
+	k.key.Y.FillBytes(b[32:])
+	return b
+}
+
+func (k *P256PublicKey) Verify(hash []byte, sig *Signature) bool {
+	return sig.Algorithm == ALG_P256 && ecdsa.Verify(k.key, hash, sig.R, sig.S)
+}
+
+type P256PrivateKey struct {
+	key *ecdsa.PrivateKey
+}
+
+func NewP256PrivateKey(k *ecdsa.PrivateKey) *P256PrivateKey {
+	return &P256PrivateKey{k}
+}
+
+func (k *P256PrivateKey) ECDSA() *ecdsa.PrivateKey { return k.key }
+func (k *P256PrivateKey) Algorithm() Algorithm     { return ALG_P256 }
+func (k *P256PrivateKey) Public() PublicKey        { return &P256PublicKey{&k.key.PublicKey} }
+func (k *P256PrivateKey) Bytes() []byte            { return k.key.D.FillBytes(make([]byte, 32)) }
+
+// String keeps the historical unpadded form of P-256 private keys.
+func (k *P256PrivateKey) String() string {
+	return fmt.Sprintf("%x", k.key.D.Bytes())
+}
+
+func (k *P256PrivateKey) Sign(hash []byte) (*Signature, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, k.key, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{Algorithm: ALG_P256, R: r, S: normalizeS(s, k.key.Params().N)}, nil
+}
+
+// ------------------------------------------------------------------
+
+type Secp256k1PublicKey struct {
+	key *secp256k1.PublicKey
+}
+
+func (k *Secp256k1PublicKey) Algorithm() Algorithm { return ALG_SECP256K1 }
+func (k *Secp256k1PublicKey) Bytes() []byte        { return k.key.SerializeUncompressed()[1:] }
+func (k *Secp256k1PublicKey) String() string       { return tagged(ALG_SECP256K1, k.Bytes()) }
+
+func (k *Secp256k1PublicKey) Verify(hash []byte, sig *Signature) bool {
+
+	if sig.Algorithm != ALG_SECP256K1 {
+		return false
+	}
+
+	b := sigBytes(sig)
+
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(b[:32]) || s.SetByteSlice(b[32:]) {
+		return false
+	}
+
+	return secp256k1ecdsa.NewSignature(&r, &s).Verify(hash, k.key)
+}
+
+type Secp256k1PrivateKey struct {
+	key *secp256k1.PrivateKey
+}
+
+func (k *Secp256k1PrivateKey) Algorithm() Algorithm { return ALG_SECP256K1 }
+func (k *Secp256k1PrivateKey) Public() PublicKey    { return &Secp256k1PublicKey{k.key.PubKey()} }
+func (k *Secp256k1PrivateKey) Bytes() []byte        { return k.key.Serialize() }
+func (k *Secp256k1PrivateKey) String() string       { return tagged(ALG_SECP256K1, k.Bytes()) }
+
+func (k *Secp256k1PrivateKey) Sign(hash []byte) (*Signature, error) {
+	sig := secp256k1ecdsa.Sign(k.key, hash)
+	r, s := sig.R(), sig.S()
+	rb, sb := r.Bytes(), s.Bytes()
+	return &Signature{
+		Algorithm: ALG_SECP256K1,
+		R:         new(big.Int).SetBytes(rb[:]),
+		S:         new(big.Int).SetBytes(sb[:]),
+	}, nil
+}
+
+// ------------------------------------------------------------------
+
+type Ed25519PublicKey ed25519.PublicKey
+
+func (k Ed25519PublicKey) Algorithm() Algorithm { return ALG_ED25519 }
+func (k Ed25519PublicKey) Bytes() []byte        { return []byte(k) }
+func (k Ed25519PublicKey) String() string       { return tagged(ALG_ED25519, k) }
+
+func (k Ed25519PublicKey) Verify(hash []byte, sig *Signature) bool {
+	return sig.Algorithm == ALG_ED25519 && ed25519.Verify(ed25519.PublicKey(k), hash, sigBytes(sig))
+}
+
+// Ed25519PrivateKey is stored and encoded as its 32-byte seed.
+type Ed25519PrivateKey ed25519.PrivateKey
+
+func (k Ed25519PrivateKey) Algorithm() Algorithm { return ALG_ED25519 }
+func (k Ed25519PrivateKey) Bytes() []byte        { return ed25519.PrivateKey(k).Seed() }
+func (k Ed25519PrivateKey) String() string       { return tagged(ALG_ED25519, k.Bytes()) }
+
+func (k Ed25519PrivateKey) Public() PublicKey {
+	return Ed25519PublicKey(ed25519.PrivateKey(k).Public().(ed25519.PublicKey))
+}
+
+func (k Ed25519PrivateKey) Sign(hash []byte) (*Signature, error) {
+	b := ed25519.Sign(ed25519.PrivateKey(k), hash)
+	return &Signature{
+		Algorithm: ALG_ED25519,
+		R:         new(big.Int).SetBytes(b[:32]),
+		S:         new(big.Int).SetBytes(b[32:]),
+	}, nil
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyAlgorithms(t *testing.T) {
+	//
+	hash := sha256.Sum256([]byte("transaction"))
+	other := sha256.Sum256([]byte("tampered"))
+	//
+	for _, alg := range []Algorithm{ALG_P256, ALG_SECP256K1, ALG_ED25519} {
+		t.Run(string(alg), func(t *testing.T) {
+			//
+			privateKey, err := GenerateKey(alg)
+			assert.NoError(t, err)
+			assert.Equal(t, alg, privateKey.Algorithm())
+			//
+			sig, err := privateKey.Sign(hash[:])
+			assert.NoError(t, err)
+			assert.True(t, privateKey.Public().Verify(hash[:], sig))
+			assert.False(t, privateKey.Public().Verify(other[:], sig))
+			//
+			publicKey, err := ParsePublicKey(privateKey.Public().String())
+			assert.NoError(t, err)
+			assert.Equal(t, privateKey.Public().Bytes(), publicKey.Bytes())
+			//
+			parsedSig, err := ParseSignature(sig.String())
+			assert.NoError(t, err)
+			assert.True(t, publicKey.Verify(hash[:], parsedSig))
+			//
+			parsedKey, err := ParsePrivateKey(privateKey.String())
+			assert.NoError(t, err)
+			assert.Equal(t, privateKey.Public().String(), parsedKey.Public().String())
+			//
+			if alg == ALG_P256 {
+				assert.False(t, strings.Contains(publicKey.String(), ":"))
+			} else {
+				assert.True(t, strings.HasPrefix(publicKey.String(), string(alg)+":"))
+			}
+		})
+	}
+}
+
+func TestCrossAlgorithmSignatureRejected(t *testing.T) {
+	//
+	hash := sha256.Sum256([]byte("transaction"))
+	k1, _ := GenerateKey(ALG_SECP256K1)
+	p256, _ := GenerateKey(ALG_P256)
+	//
+	sig, _ := k1.Sign(hash[:])
+	sig.Algorithm = ALG_P256
+	assert.False(t, p256.Public().Verify(hash[:], sig))
+	assert.False(t, k1.Public().Verify(hash[:], sig))
+	//
+	_, err := ParsePublicKey("rsa:00")
+	assert.Error(t, err)
+	_, err = ParseSignature("ed25519:00")
+	assert.Error(t, err)
+}
+
+func TestParseMalformedInput(t *testing.T) {
+	//
+	for _, s := range []string{"", "ab", "zz", "secp256k1:", strings.Repeat("00", 64), strings.Repeat("01", 64)} {
+		_, err := ParsePublicKey(s)
+		assert.ErrorIs(t, err, ErrInvalidPublicKey, s)
+	}
+	for _, s := range []string{"", "ab", strings.Repeat("00", 64), "ed25519:" + strings.Repeat("01", 70)} {
+		_, err := ParseSignature(s)
+		assert.ErrorIs(t, err, ErrInvalidSignature, s)
+	}
+	_, err := ParsePrivateKey("secp256k1:" + strings.Repeat("ff", 32))
+	assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+}
+
+func TestParseCompressedPublicKey(t *testing.T) {
+	//
+	hash := sha256.Sum256([]byte("transaction"))
+	//
+	p256, _ := GenerateKey(ALG_P256)
+	ek := p256.(*P256PrivateKey).ECDSA()
+	compressed := elliptic.MarshalCompressed(elliptic.P256(), ek.X, ek.Y)
+	publicKey, err := ParsePublicKey(hex.EncodeToString(compressed))
+	assert.NoError(t, err)
+	assert.Equal(t, p256.Public().String(), publicKey.String())
+	//
+	k1, _ := GenerateKey(ALG_SECP256K1)
+	compressed = k1.(*Secp256k1PrivateKey).key.PubKey().SerializeCompressed()
+	publicKey, err = ParsePublicKey("secp256k1:" + hex.EncodeToString(compressed))
+	assert.NoError(t, err)
+	sig, _ := k1.Sign(hash[:])
+	assert.True(t, publicKey.Verify(hash[:], sig))
+}
+
+func TestParseSignatureDERAndLowS(t *testing.T) {
+	//
+	hash := sha256.Sum256([]byte("transaction"))
+	privateKey, _ := GenerateKey(ALG_P256)
+	n := elliptic.P256().Params().N
+	//
+	for i := 0; i < 8; i++ {
+		sig, _ := privateKey.Sign(hash[:])
+		assert.True(t, sig.S.Cmp(new(big.Int).Rsh(n, 1)) <= 0)
+		//
+		der, err := asn1.Marshal(struct{ R, S *big.Int }{sig.R, sig.S})
+		assert.NoError(t, err)
+		parsed, err := ParseSignature(hex.EncodeToString(der))
+		assert.NoError(t, err)
+		assert.True(t, privateKey.Public().Verify(hash[:], parsed))
+		//
+		high := &Signature{Algorithm: ALG_P256, R: sig.R, S: new(big.Int).Sub(n, sig.S)}
+		_, err = ParseSignature(high.String())
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	}
+	//
+	der, _ := asn1.Marshal(struct{ R, S *big.Int }{big.NewInt(1), big.NewInt(1)})
+	_, err := ParseSignature(hex.EncodeToString(append(der, 0x00)))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+
+	REDACTED = "[REDACTED]"
+)
+
+// redactedKeys are attribute keys whose values never reach the log, at
+// any level of grouping.
+var redactedKeys = map[string]bool{
+	"private_key":       true,
+	"public_key":        true,
+	"public_keys":       true,
+	"sender_public_key": true,
+	"signature":         true,
+	"signatures":        true,
+	"password":          true,
+	"mnemonic":          true,
+	"seed":              true,
+	"token":             true,
+	"api_key":           true,
+	"authorization":     true,
+	"cookie":            true,
+}
+
+// ParseLogLevel accepts debug, info, warn or error in any case.
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// NewLogger returns a logger writing text or JSON records at or above
+// level to w, with key and signature attributes redacted.
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+
+	lvl, err := ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+
+	switch strings.ToLower(format) {
+	case LOG_FORMAT_TEXT, "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case LOG_FORMAT_JSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, REDACTED)
+	}
+	return a
+}
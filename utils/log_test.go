@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	//
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "INFO", "json")
+	require.NoError(t, err)
+	//
+	logger.Debug("hidden")
+	logger.Info("transaction", "tx", "ab12", "signature", "3045", slog.Group("witness", "public_key", "04aa", "threshold", 2))
+	//
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "transaction", record["msg"])
+	assert.Equal(t, "ab12", record["tx"])
+	assert.Equal(t, REDACTED, record["signature"])
+	assert.Equal(t, map[string]any{"public_key": REDACTED, "threshold": float64(2)}, record["witness"])
+	//
+	buf.Reset()
+	logger, err = NewLogger(&buf, "debug", "")
+	require.NoError(t, err)
+	logger.Debug("shown", "password", "hunter2")
+	assert.Contains(t, buf.String(), "msg=shown password="+REDACTED)
+	//
+	_, err = NewLogger(&buf, "loud", "text")
+	assert.Error(t, err)
+	_, err = NewLogger(&buf, "info", "xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRegistry returns a Prometheus registry with the Go runtime and
+// process collectors registered.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// MetricsHandler serves the metrics in reg for GET /metrics.
+func MetricsHandler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// ------------------------------------------------------------------
+
+type routeKey struct{}
+
+type route struct {
+	mux     sync.Mutex
+	pattern string
+}
+
+// Route records the ServeMux pattern that matched the request for the
+// metrics middleware. Muxes behind http.StripPrefix or http.TimeoutHandler
+// only see a copy of the request, so each mux is wrapped; the innermost
+// match wins.
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		next.ServeHTTP(w, req)
+
+		if r, ok := req.Context().Value(routeKey{}).(*route); ok && req.Pattern != "" {
+			r.mux.Lock()
+			if r.pattern == "" {
+				r.pattern = req.Pattern
+			}
+			r.mux.Unlock()
+		}
+	})
+}
+
+// HTTPMetrics times requests by route and status code.
+type HTTPMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTPMetrics(reg prometheus.Registerer) *HTTPMetrics {
+
+	m := &HTTPMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "code"}),
+	}
+	reg.MustRegister(m.duration)
+
+	return m
+}
+
+// Middleware observes every request. Requests no route matched are
+// labelled "unmatched" so unknown paths cannot grow the label set.
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r := &route{}
+
+		next.ServeHTTP(sr, req.WithContext(context.WithValue(req.Context(), routeKey{}, r)))
+
+		r.mux.Lock()
+		pattern := r.pattern
+		r.mux.Unlock()
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		m.duration.WithLabelValues(pattern, strconv.Itoa(sr.status)).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	//
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+	//
+	inner := http.NewServeMux()
+	inner.HandleFunc("GET /v1/items/{id}", func(w http.ResponseWriter, req *http.Request) {})
+	root := http.NewServeMux()
+	root.Handle("/chains/test/", http.StripPrefix("/chains/test", Chain(inner, Timeout(time.Second), Route)))
+	root.Handle("GET /metrics", MetricsHandler(reg))
+	h := Chain(root, m.Middleware, Route)
+	//
+	for _, path := range []string{"/chains/test/v1/items/1", "/chains/test/v1/items/2", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `http_request_duration_seconds_count{code="200",route="GET /v1/items/{id}"} 2`)
+	assert.Contains(t, rec.Body.String(), `http_request_duration_seconds_count{code="404",route="unmatched"} 1`)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
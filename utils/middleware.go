@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h so that the first middleware is the outermost one.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// ------------------------------------------------------------------
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestLogger returns the default logger tagged with the request ID.
+func RequestLogger(req *http.Request) *slog.Logger {
+	return slog.With("request_id", RequestIDFromContext(req.Context()))
+}
+
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		id := req.Header.Get(RequestIDHeader)
+
+		if id == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(req.Context(), requestIDKey{}, id)
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// ------------------------------------------------------------------
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sr, req)
+
+		slog.Info("request",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", sr.status,
+			"duration", time.Since(start),
+			"request_id", RequestIDFromContext(req.Context()))
+	})
+}
+
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				slog.Error("panic serving request",
+					"method", req.Method,
+					"path", req.URL.Path,
+					"request_id", RequestIDFromContext(req.Context()),
+					"error", err,
+					"stack", string(debug.Stack()))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write(JsonStatus("internal server error"))
+			}
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, string(JsonStatus("request timed out")))
+	}
+}
+
+func MaxBodySize(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.Body = http.MaxBytesReader(w, req.Body, n)
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	//
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = RequestIDFromContext(req.Context())
+	}))
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+	//
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "abc", seen)
+}
+
+func TestRecoverer(t *testing.T) {
+	//
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	}), Logger, Recoverer)
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	//
+	var readErr error
+	h := MaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, readErr = io.ReadAll(req.Body)
+	}))
+	//
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("1234")))
+	assert.NoError(t, readErr)
+	//
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("12345")))
+	assert.Error(t, readErr)
+}
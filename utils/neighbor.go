@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"log/slog"
 	"net"
 	"os"
 	"regexp"
@@ -10,11 +11,11 @@ import (
 )
 
 func IsFoundHost(host string, port uint16) bool {
-	target := fmt.Sprintf("%s:%d", host, port)
+	target := net.JoinHostPort(host, strconv.Itoa(int(port)))
 
 	_, err := net.DialTimeout("tcp", target, 1*time.Second)
 	if err != nil {
-		fmt.Printf("%s %v\n", target, err)
+		slog.Debug("neighbor not found", "peer", target, "error", err)
 		return false
 	}
 	return true
@@ -56,3 +57,14 @@ func GetHost() string {
 	}
 	return address[0]
 }
+
+// ValidHostPort reports whether address is a "host:port" with a non-empty
+// host and a non-zero port, as used for peers.
+func ValidHostPort(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return false
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	return err == nil && p > 0
+}
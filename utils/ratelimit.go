@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter keeps a token bucket per key, such as a client IP. Buckets
+// that have refilled are dropped, so idle clients cost nothing.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mux       sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows perSecond requests per key on average and burst
+// at once. A rate of zero or less disables the limit.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    perSecond,
+		burst:   math.Max(float64(burst), 1),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from key's bucket, reporting false if it is empty.
+func (rl *RateLimiter) Allow(key string) bool {
+
+	if rl == nil || rl.rate <= 0 {
+		return true
+	}
+
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	now := rl.now()
+	rl.prune(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RetryAfter is how long an empty bucket takes to earn a token.
+func (rl *RateLimiter) RetryAfter() time.Duration {
+	return time.Duration(math.Ceil(1/rl.rate)) * time.Second
+}
+
+// prune drops full buckets once a minute.
+func (rl *RateLimiter) prune(now time.Time) {
+
+	if now.Sub(rl.lastPrune) < time.Minute {
+		return
+	}
+	rl.lastPrune = now
+
+	for key, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// TooManyRequests answers 429 with a Retry-After header.
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(retryAfter.Seconds(), 1))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write(JsonStatus("rate limit exceeded"))
+}
+
+// ClientIP is the IP a request came from. Proxy headers are not trusted.
+func ClientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// ------------------------------------------------------------------
+
+// Limiter bounds how many callers run an expensive operation at once.
+// Callers that find every slot taken are turned away rather than queued,
+// so a flood cannot pile up goroutines.
+type Limiter struct {
+	slots chan struct{}
+}
+
+func NewLimiter(n int) *Limiter {
+	return &Limiter{slots: make(chan struct{}, max(n, 1))}
+}
+
+// TryAcquire takes a slot if one is free. Callers that get one must
+// Release it.
+func (l *Limiter) TryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Limiter) Release() {
+	<-l.slots
+}
+
+// Middleware answers 503 while every slot is taken.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		if !l.TryAcquire() {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(JsonStatus("server busy"))
+			return
+		}
+		defer l.Release()
+
+		next.ServeHTTP(w, req)
+	})
+}
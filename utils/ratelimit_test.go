@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	//
+	now := time.Unix(1000, 0)
+	rl := NewRateLimiter(2, 3)
+	rl.now = func() time.Time { return now }
+	//
+	for range 3 {
+		assert.True(t, rl.Allow("10.0.0.1"))
+	}
+	assert.False(t, rl.Allow("10.0.0.1"))
+	assert.True(t, rl.Allow("10.0.0.2"))
+	//
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, rl.Allow("10.0.0.1"))
+	assert.False(t, rl.Allow("10.0.0.1"))
+	//
+	now = now.Add(2 * time.Minute)
+	assert.True(t, rl.Allow("10.0.0.3"))
+	assert.Len(t, rl.buckets, 1)
+	//
+	assert.True(t, NewRateLimiter(0, 0).Allow("10.0.0.1"))
+	assert.True(t, (*RateLimiter)(nil).Allow("10.0.0.1"))
+}
+
+func TestLimiter(t *testing.T) {
+	//
+	l := NewLimiter(1)
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(entered)
+		<-release
+	}))
+	//
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+		close(done)
+	}()
+	<-entered
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	//
+	close(release)
+	<-done
+	assert.True(t, l.TryAcquire())
+	l.Release()
+}
@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/i101dev/blockchain-api/utils"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Addresses are Base58Check: a version byte, a 20-byte key hash and the
+// first four bytes of the double SHA-256 of the two.
+//
+// Version 1 hashes RIPEMD-160(SHA-256(key)) over the fixed-width key
+// encoding. Version 0 is the original scheme, SHA-256(SHA-256(key))
+// truncated to 20 bytes over unpadded X and Y; it is still accepted so
+// existing wallets keep working, but new wallets use version 1.
+const (
+	ADDRESS_VERSION        byte = 0x01
+	ADDRESS_VERSION_LEGACY byte = 0x00
+
+	ADDRESS_HASH_LEN  = 20
+	ADDRESS_CHECK_LEN = 4
+)
+
+var (
+	ErrInvalidAddress  = errors.New("invalid address")
+	ErrAddressMismatch = errors.New("address does not match public key")
+)
+
+type Address struct {
+	Version byte
+	Hash    [ADDRESS_HASH_LEN]byte
+}
+
+func (a *Address) String() string {
+	payload := make([]byte, 0, 1+ADDRESS_HASH_LEN+ADDRESS_CHECK_LEN)
+	payload = append(payload, a.Version)
+	payload = append(payload, a.Hash[:]...)
+	payload = append(payload, addressChecksum(payload)...)
+	return base58.Encode(payload)
+}
+
+// ParseAddress decodes a Base58Check address and verifies its length,
+// version and checksum.
+func ParseAddress(s string) (*Address, error) {
+
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidAddress)
+	}
+
+	b := base58.Decode(s)
+	if len(b) != 1+ADDRESS_HASH_LEN+ADDRESS_CHECK_LEN {
+		return nil, fmt.Errorf("%w: %q is not base58 of %d bytes", ErrInvalidAddress, s, 1+ADDRESS_HASH_LEN+ADDRESS_CHECK_LEN)
+	}
+
+	body, check := b[:1+ADDRESS_HASH_LEN], b[1+ADDRESS_HASH_LEN:]
+	if !bytes.Equal(addressChecksum(body), check) {
+		return nil, fmt.Errorf("%w: %q has a bad checksum", ErrInvalidAddress, s)
+	}
+	switch body[0] {
+	case ADDRESS_VERSION, ADDRESS_VERSION_LEGACY, ADDRESS_VERSION_MULTISIG:
+	default:
+		return nil, fmt.Errorf("%w: %q has unknown version %d", ErrInvalidAddress, s, body[0])
+	}
+
+	a := &Address{Version: body[0]}
+	copy(a.Hash[:], body[1:])
+	return a, nil
+}
+
+func ValidateAddress(s string) error {
+	_, err := ParseAddress(s)
+	return err
+}
+
+// VerifyAddress checks that address is the one derived from publicKey
+// under the scheme its version names.
+func VerifyAddress(address string, publicKey utils.PublicKey) error {
+
+	a, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	if publicKey == nil {
+		return fmt.Errorf("%w: %s", ErrAddressMismatch, address)
+	}
+
+	expected := AddressFromPublicKey(publicKey)
+	if a.Version == ADDRESS_VERSION_LEGACY {
+		expected = LegacyAddressFromPublicKey(publicKey)
+	}
+	if expected != address {
+		return fmt.Errorf("%w: %s", ErrAddressMismatch, address)
+	}
+
+	return nil
+}
+
+// AddressFromPublicKey returns the version 1 address of publicKey, for
+// every algorithm: the Base58Check of 0x01 and RIPEMD-160(SHA-256(key)).
+// ECDSA keys are hashed in SEC1 uncompressed form, 0x04||X||Y with both
+// coordinates padded to 32 bytes, so the hash matches the key's string
+// form byte for byte; Ed25519 keys are hashed as their 32 raw bytes. It
+// never returns another version: LegacyAddressFromPublicKey gives the
+// version 0 address of the same key, and version 0x05 addresses belong to
+// a Multisig, not a single key.
+func AddressFromPublicKey(publicKey utils.PublicKey) string {
+
+	var preimage []byte
+	switch publicKey.Algorithm() {
+	case utils.ALG_ED25519:
+		preimage = publicKey.Bytes()
+	default:
+		preimage = append([]byte{0x04}, publicKey.Bytes()...)
+	}
+
+	sha := sha256.Sum256(preimage)
+	h := ripemd160.New()
+	h.Write(sha[:])
+
+	a := &Address{Version: ADDRESS_VERSION}
+	copy(a.Hash[:], h.Sum(nil))
+
+	return a.String()
+}
+
+// LegacyAddressFromPublicKey derives a version 0 address. P-256 keys are
+// hashed over the unpadded X and Y as the original wallets did; other
+// algorithms hash their tag and key bytes.
+func LegacyAddressFromPublicKey(publicKey utils.PublicKey) string {
+
+	h := sha256.New()
+	switch k := publicKey.(type) {
+	case *utils.P256PublicKey:
+		h.Write(k.ECDSA().X.Bytes())
+		h.Write(k.ECDSA().Y.Bytes())
+	default:
+		h.Write([]byte(publicKey.Algorithm()))
+		h.Write(publicKey.Bytes())
+	}
+	digest := sha256.Sum256(h.Sum(nil))
+
+	a := &Address{Version: ADDRESS_VERSION_LEGACY}
+	copy(a.Hash[:], digest[:])
+
+	return a.String()
+}
+
+func addressChecksum(payload []byte) []byte {
+	h1 := sha256.Sum256(payload)
+	h2 := sha256.Sum256(h1[:])
+	return h2[:ADDRESS_CHECK_LEN]
+}
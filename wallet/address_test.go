@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	//
+	w := NewWallet()
+	a, err := ParseAddress(w.BlockchainAddress())
+	assert.NoError(t, err)
+	assert.Equal(t, ADDRESS_VERSION, a.Version)
+	assert.Equal(t, w.BlockchainAddress(), a.String())
+	//
+	typo := []byte(w.BlockchainAddress())
+	typo[5] ^= 1
+	//
+	raw := base58.Decode(w.BlockchainAddress())
+	raw[0] = 0x05
+	badVersion := (&Address{Version: 0x07, Hash: a.Hash}).String()
+	legacy, err := ParseAddress(w.LegacyAddress())
+	assert.NoError(t, err)
+	assert.Equal(t, ADDRESS_VERSION_LEGACY, legacy.Version)
+	//
+	for _, s := range []string{"", "bob", "0OIl", string(typo), base58.Encode(raw), badVersion, w.BlockchainAddress() + "1"} {
+		_, err := ParseAddress(s)
+		assert.ErrorIs(t, err, ErrInvalidAddress, s)
+	}
+}
+
+func TestVerifyAddress(t *testing.T) {
+	//
+	for _, alg := range []utils.Algorithm{utils.ALG_P256, utils.ALG_SECP256K1, utils.ALG_ED25519} {
+		w, _ := NewWalletWithAlgorithm(alg)
+		other, _ := NewWalletWithAlgorithm(alg)
+		//
+		assert.NoError(t, VerifyAddress(w.BlockchainAddress(), w.PublicKey()))
+		assert.ErrorIs(t, VerifyAddress(other.BlockchainAddress(), w.PublicKey()), ErrAddressMismatch)
+		assert.ErrorIs(t, VerifyAddress(other.LegacyAddress(), w.PublicKey()), ErrAddressMismatch)
+		assert.ErrorIs(t, VerifyAddress("bob", w.PublicKey()), ErrInvalidAddress)
+	}
+}
+
+func TestWalletTXNRequestValidate(t *testing.T) {
+	//
+	id := NewWallet().BlockchainAddress()
+	good := NewWallet().BlockchainAddress()
+	bad := "bob"
+	value := float32(1)
+	//
+	assert.NoError(t, (&WalletTXNRequest{&id, &good, &value}).Validate())
+	assert.Error(t, (&WalletTXNRequest{&id, &bad, &value}).Validate())
+	assert.Error(t, (&WalletTXNRequest{&id, nil, &value}).Validate())
+}
+
+func TestAddressVectors(t *testing.T) {
+	//
+	// Key 0x2b has a 31-byte Y coordinate, which the legacy scheme hashed
+	// unpadded.
+	vectors := []struct {
+		privateKey string
+		hash       string
+		address    string
+		legacy     string
+	}{
+		{"01", "4ceab2c8be16c1d4c6b4147d39aa449c2d63f184", "XMJVpNSYvYMnPrEkzKBn3pGrdS5EfKw8W", "1Fr1eGypju3oExV4RHf4vLzANzDUuKBRuv"},
+		{"2b", "4b8d5db1bf06d474e3c57e4400eb3cd86991e51e", "XE61n5oVJ8f4DD1Fsfu4zgahRnhMP4VQ4", "1AMZ8TfpztXdNXojsV7SFcm6y1BwHedPiP"},
+		{"secp256k1:" + strings.Repeat("00", 31) + "01", "91b24bf9f5288532960ac687abb035127b1d28a5", "dcyZChM2AVnjoP3MUUSFMKqMFnej487Nz", "1HwifHiTeNMZQorM8R7BboDjB9sb3PA5vd"},
+		{"ed25519:" + strings.Repeat("00", 31) + "01", "384e00f1adfdd52fc7256fcb09586f0c85ffa196", "VUKGeFoMnnW2N4fVmqC56FHFhoeZNSGDr", "1AgP8ZddYEW24uC647mTXExdhpFUGU48Ny"},
+	}
+	//
+	for _, v := range vectors {
+		privateKey, err := utils.ParsePrivateKey(v.privateKey)
+		assert.NoError(t, err)
+		//
+		assert.Equal(t, v.address, AddressFromPublicKey(privateKey.Public()), v.privateKey)
+		assert.Equal(t, v.legacy, LegacyAddressFromPublicKey(privateKey.Public()), v.privateKey)
+		//
+		a, err := ParseAddress(v.address)
+		assert.NoError(t, err)
+		assert.Equal(t, ADDRESS_VERSION, a.Version)
+		assert.Equal(t, v.hash, hex.EncodeToString(a.Hash[:]))
+		//
+		assert.NoError(t, VerifyAddress(v.address, privateKey.Public()))
+		assert.NoError(t, VerifyAddress(v.legacy, privateKey.Public()))
+	}
+	//
+	// The secp256k1 hash is Bitcoin's HASH160 of the uncompressed key, so
+	// under Bitcoin's version byte it is the well-known address of key 1.
+	a, _ := ParseAddress(vectors[2].address)
+	a.Version = 0x00
+	assert.Equal(t, "1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm", a.String())
+}
@@ -0,0 +1,227 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Keys are derived following SLIP-0010, which adapts BIP32 to the NIST
+// P-256 curve our wallets use.
+const (
+	HARDENED = 0x80000000
+
+	MNEMONIC_ENTROPY_BITS = 256
+	HD_SEED_KEY           = "Nist256p1 seed"
+
+	// ACCOUNT_PATH is the external chain of the first account. Address i
+	// of an HD wallet lives at ACCOUNT_PATH/i.
+	ACCOUNT_PATH = "m/44'/1'/0'/0"
+)
+
+var ErrInvalidMnemonic = errors.New("wallet: invalid mnemonic")
+
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MNEMONIC_ENTROPY_BITS)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+func ValidateMnemonic(mnemonic string) bool {
+	return bip39.IsMnemonicValid(mnemonic)
+}
+
+func SeedFromMnemonic(mnemonic string, passphrase string) ([]byte, error) {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	return bip39.NewSeed(mnemonic, passphrase), nil
+}
+
+// ------------------------------------------------------------------
+
+type ExtendedKey struct {
+	privateKey *ecdsa.PrivateKey
+	chainCode  []byte
+	depth      uint8
+	index      uint32
+}
+
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+
+	mac := hmac.New(sha512.New, []byte(HD_SEED_KEY))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+
+	n := elliptic.P256().Params().N
+
+	for {
+		k := new(big.Int).SetBytes(I[:32])
+		if k.Sign() != 0 && k.Cmp(n) < 0 {
+			return newExtendedKey(k, I[32:], 0, 0)
+		}
+
+		mac = hmac.New(sha512.New, []byte(HD_SEED_KEY))
+		mac.Write(I)
+		I = mac.Sum(nil)
+	}
+}
+
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+
+	if k.depth == 255 {
+		return nil, errors.New("wallet: maximum derivation depth reached")
+	}
+
+	data := make([]byte, 0, 37)
+
+	if index >= HARDENED {
+		key, err := k.privateKey.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, 0x00)
+		data = append(data, key...)
+	} else {
+		data = append(data, elliptic.MarshalCompressed(elliptic.P256(), k.privateKey.X, k.privateKey.Y)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	n := elliptic.P256().Params().N
+
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		I := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(I[:32])
+		child := new(big.Int).Add(il, k.privateKey.D)
+		child.Mod(child, n)
+
+		if il.Cmp(n) < 0 && child.Sign() != 0 {
+			return newExtendedKey(child, I[32:], k.depth+1, index)
+		}
+
+		data = append([]byte{0x01}, I[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+// Derive walks a path such as "m/44'/1'/0'/0/3" from a master key.
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("wallet: derivation path must start with m: %q", path)
+	}
+	if k.depth != 0 {
+		return nil, errors.New("wallet: derivation paths start from the master key")
+	}
+
+	key := k
+	for _, p := range parts[1:] {
+
+		offset := uint32(0)
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "H") {
+			offset = HARDENED
+			p = p[:len(p)-1]
+		}
+
+		i, err := strconv.ParseUint(p, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("wallet: invalid path segment %q: %w", p, err)
+		}
+
+		key, err = key.Child(uint32(i) + offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (k *ExtendedKey) PrivateKey() *ecdsa.PrivateKey {
+	return k.privateKey
+}
+
+func (k *ExtendedKey) ChainCode() []byte {
+	return k.chainCode
+}
+
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+func (k *ExtendedKey) Index() uint32 {
+	return k.index
+}
+
+func (k *ExtendedKey) Wallet() *Wallet {
+	return NewWalletFromPrivateKey(utils.NewP256PrivateKey(k.privateKey))
+}
+
+func newExtendedKey(d *big.Int, chainCode []byte, depth uint8, index uint32) (*ExtendedKey, error) {
+
+	privateKey, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), d.FillBytes(make([]byte, 32)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendedKey{
+		privateKey: privateKey,
+		chainCode:  append([]byte(nil), chainCode...),
+		depth:      depth,
+		index:      index,
+	}, nil
+}
+
+// ------------------------------------------------------------------
+
+// Account derives the addresses of one HD wallet from its seed.
+type Account struct {
+	chain *ExtendedKey
+}
+
+func NewAccount(mnemonic string, passphrase string) (*Account, error) {
+
+	seed, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := master.Derive(ACCOUNT_PATH)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Account{chain: chain}, nil
+}
+
+func (a *Account) Wallet(index uint32) (*Wallet, error) {
+	if index >= HARDENED {
+		return nil, fmt.Errorf("wallet: address index %d out of range", index)
+	}
+	k, err := a.chain.Child(index)
+	if err != nil {
+		return nil, err
+	}
+	return k.Wallet(), nil
+}
@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SLIP-0010 test vector 1 for nist256p1.
+func TestSLIP10Vectors(t *testing.T) {
+	//
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	assert.NoError(t, err)
+	//
+	key, _ := master.PrivateKey().Bytes()
+	assert.Equal(t, "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2", hex.EncodeToString(key))
+	assert.Equal(t, "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", hex.EncodeToString(master.ChainCode()))
+	//
+	child, err := master.Derive("m/0'")
+	assert.NoError(t, err)
+	key, _ = child.PrivateKey().Bytes()
+	assert.Equal(t, "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c", hex.EncodeToString(key))
+	assert.Equal(t, "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", hex.EncodeToString(child.ChainCode()))
+	//
+	child, err = master.Derive("m/0'/1")
+	assert.NoError(t, err)
+	key, _ = child.PrivateKey().Bytes()
+	assert.Equal(t, "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129", hex.EncodeToString(key))
+	assert.Equal(t, "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", hex.EncodeToString(child.ChainCode()))
+}
+
+func TestAccountRestore(t *testing.T) {
+	//
+	mnemonic, err := NewMnemonic()
+	assert.NoError(t, err)
+	assert.True(t, ValidateMnemonic(mnemonic))
+	//
+	a, err := NewAccount(mnemonic, "")
+	assert.NoError(t, err)
+	b, err := NewAccount(mnemonic, "")
+	assert.NoError(t, err)
+	//
+	for i := uint32(0); i < 3; i++ {
+		wa, err := a.Wallet(i)
+		assert.NoError(t, err)
+		wb, _ := b.Wallet(i)
+		assert.Equal(t, wa.BlockchainAddress(), wb.BlockchainAddress())
+	}
+	//
+	w0, _ := a.Wallet(0)
+	w1, _ := a.Wallet(1)
+	assert.NotEqual(t, w0.BlockchainAddress(), w1.BlockchainAddress())
+	//
+	_, err = NewAccount("not a mnemonic", "")
+	assert.ErrorIs(t, err, ErrInvalidMnemonic)
+}
@@ -0,0 +1,393 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KEYSTORE_VERSION = 1
+
+	KEYSTORE_CIPHER = "aes-256-gcm"
+	KEYSTORE_KDF    = "scrypt"
+
+	SCRYPT_N     = 1 << 15
+	SCRYPT_R     = 8
+	SCRYPT_P     = 1
+	SCRYPT_DKLEN = 32
+)
+
+var (
+	ErrKeyNotFound   = errors.New("keystore: key not found")
+	ErrKeyExists     = errors.New("keystore: key already exists")
+	ErrWrongPassword = errors.New("keystore: wrong password")
+	ErrLocked        = errors.New("keystore: key is locked")
+)
+
+// ------------------------------------------------------------------
+
+// KeyFile is the on-disk format of one encrypted key. The address is
+// bound to the ciphertext as additional data, so a file cannot be renamed
+// onto another address.
+type KeyFile struct {
+	Version   int             `json:"version"`
+	Algorithm utils.Algorithm `json:"algorithm,omitempty"`
+	Address   string          `json:"address"`
+	PublicKey string          `json:"public_key"`
+	Crypto    CryptoJSON      `json:"crypto"`
+}
+
+type CryptoJSON struct {
+	Cipher     string     `json:"cipher"`
+	CipherText string     `json:"ciphertext"`
+	Nonce      string     `json:"nonce"`
+	KDF        string     `json:"kdf"`
+	KDFParams  ScryptJSON `json:"kdfparams"`
+}
+
+type ScryptJSON struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// ------------------------------------------------------------------
+
+type Keystore struct {
+	dir     string
+	scryptN int
+
+	mux      sync.Mutex
+	unlocked map[string]*Wallet
+	refs     map[string]int
+}
+
+func NewKeystore(dir string) (*Keystore, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Keystore{
+		dir:      dir,
+		scryptN:  SCRYPT_N,
+		unlocked: make(map[string]*Wallet),
+		refs:     make(map[string]int),
+	}, nil
+}
+
+func (ks *Keystore) Dir() string {
+	return ks.dir
+}
+
+// Create generates a new P-256 key, stores it encrypted under password
+// and leaves it unlocked.
+func (ks *Keystore) Create(password string) (*Wallet, error) {
+	return ks.CreateWithAlgorithm(password, utils.ALG_P256)
+}
+
+func (ks *Keystore) CreateWithAlgorithm(password string, alg utils.Algorithm) (*Wallet, error) {
+	w, err := NewWalletWithAlgorithm(alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.store(w, password); err != nil {
+		return nil, err
+	}
+	ks.setUnlocked(w)
+	return w, nil
+}
+
+// Import stores a private key, in the encoding PrivateKeyStr returns,
+// encrypted under password.
+func (ks *Keystore) Import(privateKeyHex string, password string) (*Wallet, error) {
+
+	privateKey, err := utils.ParsePrivateKey(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	w := NewWalletFromPrivateKey(privateKey)
+	if err := ks.store(w, password); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Export decrypts the key for address and returns it hex encoded.
+func (ks *Keystore) Export(address string, password string) (string, error) {
+	w, err := ks.decrypt(address, password)
+	if err != nil {
+		return "", err
+	}
+	return w.PrivateKeyStr(), nil
+}
+
+func (ks *Keystore) Unlock(address string, password string) (*Wallet, error) {
+	w, err := ks.decrypt(address, password)
+	if err != nil {
+		return nil, err
+	}
+	ks.setUnlocked(w)
+	return w, nil
+}
+
+// Migrate re-stores a legacy key under its current address and unlocks
+// it there. The legacy file is kept so funds still held at the old
+// address remain spendable.
+func (ks *Keystore) Migrate(address string, password string) (*Wallet, error) {
+
+	legacy, err := ks.decrypt(address, password)
+	if err != nil {
+		return nil, err
+	}
+
+	w := NewWalletFromPrivateKey(legacy.privateKey)
+	if err := ks.store(w, password); err != nil && !errors.Is(err, ErrKeyExists) {
+		return nil, err
+	}
+
+	ks.setUnlocked(w)
+	return w, nil
+}
+
+// Lock releases one Create, Unlock or Migrate of address. The key stays
+// unlocked until every one of them has been released.
+func (ks *Keystore) Lock(address string) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	if ks.refs[address]--; ks.refs[address] > 0 {
+		return
+	}
+	delete(ks.refs, address)
+	delete(ks.unlocked, address)
+}
+
+// Wallet returns the unlocked wallet for address.
+func (ks *Keystore) Wallet(address string) (*Wallet, error) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	w, ok := ks.unlocked[address]
+	if !ok {
+		return nil, ErrLocked
+	}
+	return w, nil
+}
+
+// List returns the addresses of every stored key.
+func (ks *Keystore) List() ([]string, error) {
+
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			addresses = append(addresses, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// ------------------------------------------------------------------
+
+// KeyFilePath is where the encrypted key for address is stored.
+func (ks *Keystore) KeyFilePath(address string) string {
+	return ks.path(address)
+}
+
+func (ks *Keystore) path(address string) string {
+	return filepath.Join(ks.dir, filepath.Base(address)+".json")
+}
+
+func (ks *Keystore) setUnlocked(w *Wallet) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	ks.unlocked[w.BlockchainAddress()] = w
+	ks.refs[w.BlockchainAddress()]++
+}
+
+func (ks *Keystore) store(w *Wallet, password string) error {
+
+	kf, err := encryptKey(w, password, ks.scryptN)
+	if err != nil {
+		return err
+	}
+
+	m, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(ks.path(w.BlockchainAddress()), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return ErrKeyExists
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(m); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (ks *Keystore) decrypt(address string, password string) (*Wallet, error) {
+	return ReadKeyFile(ks.path(address), password)
+}
+
+// ReadKeyFile decrypts one key file on its own, as an offline signer
+// holding a single copied file would.
+func ReadKeyFile(path string, password string) (*Wallet, error) {
+
+	m, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var kf KeyFile
+	if err := json.Unmarshal(m, &kf); err != nil {
+		return nil, err
+	}
+
+	return decryptKey(&kf, password)
+}
+
+// ------------------------------------------------------------------
+
+func encryptKey(w *Wallet, password string, scryptN int) (*KeyFile, error) {
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	params := ScryptJSON{
+		N:     scryptN,
+		R:     SCRYPT_R,
+		P:     SCRYPT_P,
+		DKLen: SCRYPT_DKLEN,
+		Salt:  hex.EncodeToString(salt),
+	}
+
+	gcm, err := newGCM(password, salt, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ciphertext := gcm.Seal(nil, nonce, w.privateKey.Bytes(), []byte(w.BlockchainAddress()))
+
+	return &KeyFile{
+		Version:   KEYSTORE_VERSION,
+		Algorithm: w.Algorithm(),
+		Address:   w.BlockchainAddress(),
+		PublicKey: w.PublicKeyStr(),
+		Crypto: CryptoJSON{
+			Cipher:     KEYSTORE_CIPHER,
+			CipherText: hex.EncodeToString(ciphertext),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        KEYSTORE_KDF,
+			KDFParams:  params,
+		},
+	}, nil
+}
+
+func decryptKey(kf *KeyFile, password string) (*Wallet, error) {
+
+	if kf.Version != KEYSTORE_VERSION {
+		return nil, fmt.Errorf("keystore: unsupported version %d", kf.Version)
+	}
+	if kf.Crypto.Cipher != KEYSTORE_CIPHER || kf.Crypto.KDF != KEYSTORE_KDF {
+		return nil, fmt.Errorf("keystore: unsupported cipher %q or kdf %q", kf.Crypto.Cipher, kf.Crypto.KDF)
+	}
+
+	salt, err := hex.DecodeString(kf.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid salt: %w", err)
+	}
+	nonce, err := hex.DecodeString(kf.Crypto.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid nonce: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid ciphertext: %w", err)
+	}
+
+	gcm, err := newGCM(password, salt, &kf.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("keystore: invalid nonce length %d", len(nonce))
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(kf.Address))
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	privateKey, err := utils.PrivateKeyFromBytes(kf.Algorithm, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	w := NewWalletFromPrivateKey(privateKey)
+	switch kf.Address {
+	case w.BlockchainAddress():
+	case w.LegacyAddress():
+		// Files written before the RIPEMD-160 scheme keep signing for
+		// their old address until they are migrated.
+		w.blockchainAddress = kf.Address
+	default:
+		return nil, fmt.Errorf("keystore: key does not match address %s", kf.Address)
+	}
+
+	return w, nil
+}
+
+func newGCM(password string, salt []byte, params *ScryptJSON) (cipher.AEAD, error) {
+
+	key, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeystore(t *testing.T) *Keystore {
+	ks, err := NewKeystore(t.TempDir())
+	assert.NoError(t, err)
+	ks.scryptN = 1 << 10
+	return ks
+}
+
+func TestKeystoreCreateUnlock(t *testing.T) {
+	//
+	ks := newTestKeystore(t)
+	w, err := ks.Create("secret")
+	assert.NoError(t, err)
+	//
+	unlocked, err := ks.Wallet(w.BlockchainAddress())
+	assert.NoError(t, err)
+	assert.Equal(t, w.PrivateKeyStr(), unlocked.PrivateKeyStr())
+	//
+	ks.Lock(w.BlockchainAddress())
+	_, err = ks.Wallet(w.BlockchainAddress())
+	assert.ErrorIs(t, err, ErrLocked)
+	//
+	_, err = ks.Unlock(w.BlockchainAddress(), "wrong")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	//
+	unlocked, err = ks.Unlock(w.BlockchainAddress(), "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, w.PublicKeyStr(), unlocked.PublicKeyStr())
+	//
+	_, err = ks.Unlock(w.BlockchainAddress(), "secret")
+	assert.NoError(t, err)
+	ks.Lock(w.BlockchainAddress())
+	_, err = ks.Wallet(w.BlockchainAddress())
+	assert.NoError(t, err)
+	ks.Lock(w.BlockchainAddress())
+	_, err = ks.Wallet(w.BlockchainAddress())
+	assert.ErrorIs(t, err, ErrLocked)
+	//
+	info, err := os.Stat(filepath.Join(ks.Dir(), w.BlockchainAddress()+".json"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestKeystoreImportExport(t *testing.T) {
+	//
+	ks := newTestKeystore(t)
+	original := NewWallet()
+	//
+	w, err := ks.Import(original.PrivateKeyStr(), "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, original.BlockchainAddress(), w.BlockchainAddress())
+	//
+	_, err = ks.Import(original.PrivateKeyStr(), "secret")
+	assert.ErrorIs(t, err, ErrKeyExists)
+	//
+	exported, err := ks.Export(w.BlockchainAddress(), "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, original.PrivateKeyStr(), exported)
+	//
+	_, err = ks.Export("missing", "secret")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	//
+	addresses, err := ks.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{w.BlockchainAddress()}, addresses)
+}
+
+func TestKeystoreMigrateLegacy(t *testing.T) {
+	//
+	ks := newTestKeystore(t)
+	w := NewWallet()
+	legacy := NewWalletFromPrivateKey(w.PrivateKey())
+	legacy.blockchainAddress = w.LegacyAddress()
+	assert.NoError(t, ks.store(legacy, "secret"))
+	//
+	unlocked, err := ks.Unlock(w.LegacyAddress(), "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, w.LegacyAddress(), unlocked.BlockchainAddress())
+	//
+	_, err = ks.Migrate(w.LegacyAddress(), "wrong")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	//
+	migrated, err := ks.Migrate(w.LegacyAddress(), "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, w.BlockchainAddress(), migrated.BlockchainAddress())
+	//
+	addresses, _ := ks.List()
+	assert.ElementsMatch(t, []string{w.BlockchainAddress(), w.LegacyAddress()}, addresses)
+	//
+	_, err = ks.Migrate(w.LegacyAddress(), "secret")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// ADDRESS_VERSION_MULTISIG marks addresses that commit to a set of
+	// public keys and a threshold rather than a single key.
+	ADDRESS_VERSION_MULTISIG byte = 0x05
+
+	MULTISIG_MAX_KEYS = 15
+)
+
+var (
+	ErrInvalidMultisig    = errors.New("invalid multisig")
+	ErrThresholdNotMet    = errors.New("multisig threshold not met")
+	ErrUnknownCosigner    = errors.New("signature from a key outside the multisig")
+	ErrInvalidCosignature = errors.New("invalid co-signer signature")
+)
+
+// Multisig is an M-of-N account. Keys are held sorted by their string
+// form so the address does not depend on the order they were listed in.
+type Multisig struct {
+	threshold  int
+	publicKeys []utils.PublicKey
+}
+
+func NewMultisig(threshold int, publicKeys []utils.PublicKey) (*Multisig, error) {
+
+	n := len(publicKeys)
+	if n == 0 || n > MULTISIG_MAX_KEYS {
+		return nil, fmt.Errorf("%w: %d keys, want 1 to %d", ErrInvalidMultisig, n, MULTISIG_MAX_KEYS)
+	}
+	if threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("%w: threshold %d of %d keys", ErrInvalidMultisig, threshold, n)
+	}
+
+	keys := append([]utils.PublicKey(nil), publicKeys...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	for i := 1; i < n; i++ {
+		if keys[i].String() == keys[i-1].String() {
+			return nil, fmt.Errorf("%w: duplicate key %s", ErrInvalidMultisig, keys[i])
+		}
+	}
+
+	return &Multisig{threshold: threshold, publicKeys: keys}, nil
+}
+
+func ParseMultisig(threshold int, publicKeys []string) (*Multisig, error) {
+
+	keys := make([]utils.PublicKey, 0, len(publicKeys))
+	for _, s := range publicKeys {
+		k, err := utils.ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return NewMultisig(threshold, keys)
+}
+
+func (m *Multisig) Threshold() int {
+	return m.threshold
+}
+
+func (m *Multisig) PublicKeys() []utils.PublicKey {
+	return append([]utils.PublicKey(nil), m.publicKeys...)
+}
+
+func (m *Multisig) PublicKeyStrs() []string {
+	strs := make([]string, 0, len(m.publicKeys))
+	for _, k := range m.publicKeys {
+		strs = append(strs, k.String())
+	}
+	return strs
+}
+
+func (m *Multisig) Contains(publicKey utils.PublicKey) bool {
+	for _, k := range m.publicKeys {
+		if k.String() == publicKey.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Address hashes the threshold, the key count and each length-prefixed
+// key string, in sorted order, with RIPEMD-160(SHA-256).
+func (m *Multisig) Address() string {
+
+	script := []byte{byte(m.threshold), byte(len(m.publicKeys))}
+	for _, k := range m.publicKeys {
+		s := k.String()
+		script = binary.BigEndian.AppendUint16(script, uint16(len(s)))
+		script = append(script, s...)
+	}
+
+	sha := sha256.Sum256(script)
+	h := ripemd160.New()
+	h.Write(sha[:])
+
+	a := &Address{Version: ADDRESS_VERSION_MULTISIG}
+	copy(a.Hash[:], h.Sum(nil))
+
+	return a.String()
+}
+
+// Verify checks signatures over hash, keyed by the signer's public key
+// string. Every signature must be valid and from a member key, and at
+// least threshold distinct members must have signed.
+func (m *Multisig) Verify(hash []byte, signatures map[string]*utils.Signature) error {
+
+	valid := 0
+	for key, sig := range signatures {
+
+		publicKey, err := utils.ParsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		if !m.Contains(publicKey) {
+			return fmt.Errorf("%w: %s", ErrUnknownCosigner, key)
+		}
+		if sig == nil || sig.Algorithm != publicKey.Algorithm() || !publicKey.Verify(hash, sig) {
+			return fmt.Errorf("%w: %s", ErrInvalidCosignature, key)
+		}
+
+		valid++
+	}
+
+	if valid < m.threshold {
+		return fmt.Errorf("%w: %d of %d signatures", ErrThresholdNotMet, valid, m.threshold)
+	}
+
+	return nil
+}
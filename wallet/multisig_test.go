@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultisigAddress(t *testing.T) {
+	//
+	a, b := NewWallet(), NewWallet()
+	c, _ := NewWalletWithAlgorithm(utils.ALG_ED25519)
+	//
+	ms1, err := NewMultisig(2, []utils.PublicKey{a.PublicKey(), b.PublicKey(), c.PublicKey()})
+	assert.NoError(t, err)
+	ms2, err := ParseMultisig(2, []string{c.PublicKeyStr(), a.PublicKeyStr(), b.PublicKeyStr()})
+	assert.NoError(t, err)
+	assert.Equal(t, ms1.Address(), ms2.Address())
+	//
+	parsed, err := ParseAddress(ms1.Address())
+	assert.NoError(t, err)
+	assert.Equal(t, ADDRESS_VERSION_MULTISIG, parsed.Version)
+	//
+	ms3, _ := NewMultisig(3, []utils.PublicKey{a.PublicKey(), b.PublicKey(), c.PublicKey()})
+	assert.NotEqual(t, ms1.Address(), ms3.Address())
+	assert.ErrorIs(t, VerifyAddress(ms1.Address(), a.PublicKey()), ErrAddressMismatch)
+	//
+	for _, threshold := range []int{0, 4} {
+		_, err := NewMultisig(threshold, []utils.PublicKey{a.PublicKey(), b.PublicKey(), c.PublicKey()})
+		assert.ErrorIs(t, err, ErrInvalidMultisig)
+	}
+	_, err = NewMultisig(1, []utils.PublicKey{a.PublicKey(), a.PublicKey()})
+	assert.ErrorIs(t, err, ErrInvalidMultisig)
+}
+
+func TestMultisigVerify(t *testing.T) {
+	//
+	a, b, c, outsider := NewWallet(), NewWallet(), NewWallet(), NewWallet()
+	ms, _ := NewMultisig(2, []utils.PublicKey{a.PublicKey(), b.PublicKey(), c.PublicKey()})
+	//
+	hash := TransactionHash(ms.Address(), outsider.BlockchainAddress(), 5)
+	sign := func(w *Wallet) *utils.Signature {
+		sig, _ := w.PrivateKey().Sign(hash[:])
+		return sig
+	}
+	//
+	assert.NoError(t, ms.Verify(hash[:], map[string]*utils.Signature{
+		a.PublicKeyStr(): sign(a),
+		c.PublicKeyStr(): sign(c),
+	}))
+	assert.ErrorIs(t, ms.Verify(hash[:], map[string]*utils.Signature{
+		a.PublicKeyStr(): sign(a),
+	}), ErrThresholdNotMet)
+	assert.ErrorIs(t, ms.Verify(hash[:], map[string]*utils.Signature{
+		a.PublicKeyStr():        sign(a),
+		outsider.PublicKeyStr(): sign(outsider),
+	}), ErrUnknownCosigner)
+	assert.ErrorIs(t, ms.Verify(hash[:], map[string]*utils.Signature{
+		a.PublicKeyStr(): sign(a),
+		b.PublicKeyStr(): sign(c),
+	}), ErrInvalidCosignature)
+}
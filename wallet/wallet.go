@@ -6,102 +6,90 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
 
-	"github.com/btcsuite/btcutil/base58"
 	"github.com/i101dev/blockchain-api/utils"
 )
 
 type Wallet struct {
-	privateKey        *ecdsa.PrivateKey
-	publicKey         *ecdsa.PublicKey
+	privateKey        utils.PrivateKey
+	publicKey         utils.PublicKey
 	blockchainAddress string
 }
 
 func NewWallet() *Wallet {
-
-	w := new(Wallet)
 	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	w.privateKey = privateKey
-	w.publicKey = &w.privateKey.PublicKey
-
-	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
-	digest2 := h2.Sum(nil)
-
-	h3 := sha256.New()
-	h3.Write(digest2)
-	digest3 := h3.Sum(nil)
-
-	vd4 := make([]byte, 21)
-	vd4[0] = 0x00
-	copy(vd4[1:], digest3[:])
-
-	h5 := sha256.New()
-	h5.Write(vd4)
-	digest5 := h5.Sum(nil)
-
-	h6 := sha256.New()
-	h6.Write(digest5)
-	digest6 := h6.Sum(nil)
-
-	chkSum := digest6[:4]
-
-	dc8 := make([]byte, 25)
-	copy(dc8[:21], vd4[:])
-	copy(dc8[21:], chkSum[:])
+	return NewWalletFromPrivateKey(utils.NewP256PrivateKey(privateKey))
+}
 
-	address := base58.Encode(dc8)
-	w.blockchainAddress = address
+func NewWalletWithAlgorithm(alg utils.Algorithm) (*Wallet, error) {
+	privateKey, err := utils.GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletFromPrivateKey(privateKey), nil
+}
 
+func NewWalletFromPrivateKey(privateKey utils.PrivateKey) *Wallet {
+	w := new(Wallet)
+	w.privateKey = privateKey
+	w.publicKey = privateKey.Public()
+	w.blockchainAddress = AddressFromPublicKey(w.publicKey)
 	return w
 }
 
-func (w *Wallet) PrivateKey() *ecdsa.PrivateKey {
+func (w *Wallet) PrivateKey() utils.PrivateKey {
 	return w.privateKey
 }
 
 func (w *Wallet) PrivateKeyStr() string {
-	return fmt.Sprintf("%x", w.privateKey.D.Bytes())
+	return w.privateKey.String()
 }
 
-func (w *Wallet) PublicKey() *ecdsa.PublicKey {
+func (w *Wallet) PublicKey() utils.PublicKey {
 	return w.publicKey
 }
 
 func (w *Wallet) PublicKeyStr() string {
-	return fmt.Sprintf("%064x%064x", w.publicKey.X.Bytes(), w.publicKey.Y.Bytes())
+	return w.publicKey.String()
+}
+
+func (w *Wallet) Algorithm() utils.Algorithm {
+	return w.publicKey.Algorithm()
 }
 
 func (w *Wallet) BlockchainAddress() string {
 	return w.blockchainAddress
 }
 
+// LegacyAddress is the pre-RIPEMD-160 address of the same key, where
+// funds sent before the migration may still sit.
+func (w *Wallet) LegacyAddress() string {
+	return LegacyAddressFromPublicKey(w.publicKey)
+}
+
+// MarshalJSON never includes the private key; use Keystore.Export for that.
 func (w *Wallet) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		PrivateKey string `json:"private_key"`
-		PublicKey  string `json:"public_key"`
-		Address    string `json:"address"`
+		PublicKey string `json:"public_key"`
+		Address   string `json:"address"`
 	}{
-		PublicKey:  w.PublicKeyStr(),
-		PrivateKey: w.PrivateKeyStr(),
-		Address:    w.BlockchainAddress(),
+		PublicKey: w.PublicKeyStr(),
+		Address:   w.BlockchainAddress(),
 	})
 }
 
 // -------------------------------------------------
 type WalletTXN struct {
-	senderPrivateKey           *ecdsa.PrivateKey
-	senderPublicKey            *ecdsa.PublicKey
+	senderPrivateKey           utils.PrivateKey
+	senderPublicKey            utils.PublicKey
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
 }
 
-func NewWalletTransaction(privKey *ecdsa.PrivateKey, pubKey *ecdsa.PublicKey, sender string, recipient string, value float32) *WalletTXN {
+func NewWalletTransaction(privKey utils.PrivateKey, pubKey utils.PublicKey, sender string, recipient string, value float32) *WalletTXN {
 	return &WalletTXN{
 		senderPrivateKey:           privKey,
 		senderPublicKey:            pubKey,
@@ -123,59 +111,47 @@ func (wt *WalletTXN) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// TransactionHash is the digest every signer of a transfer signs: the
+// SHA-256 of its sender, recipient and value as JSON.
+func TransactionHash(sender string, recipient string, value float32) [32]byte {
+	m, _ := json.Marshal(&WalletTXN{senderBlockchainAddress: sender, recipientBlockchainAddress: recipient, value: value})
+	return sha256.Sum256(m)
+}
+
 func (wt *WalletTXN) GenerateSignature() *utils.Signature {
 
-	m, _ := json.Marshal(wt)
-	hash := sha256.Sum256([]byte(m))
+	hash := TransactionHash(wt.senderBlockchainAddress, wt.recipientBlockchainAddress, wt.value)
 
-	r, s, err := ecdsa.Sign(rand.Reader, wt.senderPrivateKey, hash[:])
+	sig, err := wt.senderPrivateKey.Sign(hash[:])
 
 	if err != nil {
-		fmt.Printf("%s : %+v", strings.Repeat("-", 30), err)
 		panic(err)
 	}
 
-	return &utils.Signature{R: r, S: s}
+	return sig
 }
 
 // -------------------------------------------------
+
+// WalletTXNRequest asks wallet_server to sign with the keystore wallet
+// identified by WalletID, which must be unlocked by the caller's session.
 type WalletTXNRequest struct {
-	SenderPrivateKey           *string  `json:"sender_private_key"`
-	SenderBlockchainAddress    *string  `json:"sender_blockchain_address"`
+	WalletID                   *string  `json:"wallet_id"`
 	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
-	SenderPublicKey            *string  `json:"sender_public_key"`
 	Value                      *float32 `json:"value"`
 }
 
-func (tr *WalletTXNRequest) Validate() bool {
+func (tr *WalletTXNRequest) Validate() error {
 
-	if tr.SenderPrivateKey == nil {
-		log.Panicln("SenderPrivateKey MISSING")
-		return false
+	if tr.WalletID == nil || tr.RecipientBlockchainAddress == nil || tr.Value == nil {
+		return errors.New("missing field(s)")
 	}
-	if tr.SenderBlockchainAddress == nil {
-		log.Panicln("SenderBlockchainAddress MISSING")
-		return false
+	if err := ValidateAddress(*tr.WalletID); err != nil {
+		return fmt.Errorf("wallet_id: %w", err)
 	}
-	if tr.RecipientBlockchainAddress == nil {
-		log.Panicln("RecipientBlockchainAddress MISSING")
-		return false
-	}
-	if tr.SenderPublicKey == nil {
-		log.Panicln("SenderPublicKey MISSING")
-		return false
-	}
-	if tr.Value == nil {
-		log.Panicln("Value MISSING")
-		return false
+	if err := ValidateAddress(*tr.RecipientBlockchainAddress); err != nil {
+		return fmt.Errorf("recipient_blockchain_address: %w", err)
 	}
 
-	if tr.SenderPrivateKey == nil ||
-		tr.SenderBlockchainAddress == nil ||
-		tr.RecipientBlockchainAddress == nil ||
-		tr.SenderPublicKey == nil ||
-		tr.Value == nil {
-		return false
-	}
-	return true
+	return nil
 }
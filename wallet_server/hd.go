@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+// HD_GAP_LIMIT is how many consecutive empty addresses a restore scans
+// past the last funded one before stopping.
+const HD_GAP_LIMIT = 5
+
+// HD_MAX_COUNT caps the addresses a request may ask for, as each one
+// costs a keystore encryption and a key file.
+const HD_MAX_COUNT = 20
+
+type HDRequest struct {
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase"`
+	Password   string `json:"password"`
+	Count      uint32 `json:"count"`
+}
+
+type HDAddress struct {
+	Index     uint32  `json:"index"`
+	Address   string  `json:"address"`
+	PublicKey string  `json:"public_key"`
+	Amount    float32 `json:"amount"`
+}
+
+// CreateHDWallet generates a mnemonic, stores the first address of its
+// account in the keystore and logs in. The mnemonic is returned once so
+// the user can back it up; the server does not keep it.
+func (ws *WalletServer) CreateHDWallet(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	var hr HDRequest
+	if err := json.NewDecoder(req.Body).Decode(&hr); err != nil || hr.Password == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("password required")))
+		return
+	}
+
+	mnemonic, err := wallet.NewMnemonic()
+	if err != nil {
+		utils.RequestLogger(req).Error("generate mnemonic", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	hr.Mnemonic = mnemonic
+	addresses, ok := ws.importAccount(w, req, &hr, false)
+	if !ok {
+		return
+	}
+
+	ws.sessions.Create(w, addresses[0].Address)
+
+	m, _ := json.Marshal(struct {
+		Mnemonic  string      `json:"mnemonic"`
+		Addresses []HDAddress `json:"addresses"`
+	}{
+		Mnemonic:  mnemonic,
+		Addresses: addresses,
+	})
+
+	io.WriteString(w, string(m))
+}
+
+// RestoreHDWallet re-derives an account from its mnemonic, scanning the
+// gateway for funded addresses, and imports them into the keystore.
+func (ws *WalletServer) RestoreHDWallet(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	var hr HDRequest
+	if err := json.NewDecoder(req.Body).Decode(&hr); err != nil || hr.Password == "" || hr.Mnemonic == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("mnemonic and password required")))
+		return
+	}
+
+	addresses, ok := ws.importAccount(w, req, &hr, true)
+	if !ok {
+		return
+	}
+
+	ws.sessions.Create(w, addresses[0].Address)
+
+	m, _ := json.Marshal(struct {
+		Addresses []HDAddress `json:"addresses"`
+	}{
+		Addresses: addresses,
+	})
+
+	io.WriteString(w, string(m))
+}
+
+// importAccount stores the first max(Count, 1) addresses, plus any funded
+// address found within HD_GAP_LIMIT of the previous one when scan is set.
+// It stops when the request ends.
+func (ws *WalletServer) importAccount(w http.ResponseWriter, req *http.Request, hr *HDRequest, scan bool) ([]HDAddress, bool) {
+
+	if hr.Count > HD_MAX_COUNT {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("count must be at most %d", HD_MAX_COUNT))))
+		return nil, false
+	}
+
+	account, err := wallet.NewAccount(hr.Mnemonic, hr.Passphrase)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("invalid mnemonic")))
+		return nil, false
+	}
+
+	count := max(hr.Count, 1)
+	addresses := make([]HDAddress, 0)
+	gap := 0
+
+	for i := uint32(0); i < count || (scan && gap < HD_GAP_LIMIT); i++ {
+
+		if err := req.Context().Err(); err != nil {
+			utils.RequestLogger(req).Warn("import account stopped", "imported", len(addresses), "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return nil, false
+		}
+
+		myWallet, err := account.Wallet(i)
+		if err != nil {
+			utils.RequestLogger(req).Error("derive address", "index", i, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return nil, false
+		}
+
+		var amount float32
+		if scan {
+			amt, err := ws.client.Amount(req.Context(), myWallet.BlockchainAddress())
+			if err != nil {
+				utils.RequestLogger(req).Warn("scan address", "address", myWallet.BlockchainAddress(), "error", err)
+				w.WriteHeader(http.StatusBadGateway)
+				io.WriteString(w, string(utils.JsonStatus("gateway unavailable")))
+				return nil, false
+			}
+			amount = amt.Amount
+		}
+
+		if amount != 0 {
+			gap = 0
+		} else {
+			gap++
+		}
+
+		if i >= count && amount == 0 {
+			continue
+		}
+
+		_, err = ws.keystore.Import(myWallet.PrivateKeyStr(), hr.Password)
+		if err != nil && !errors.Is(err, wallet.ErrKeyExists) {
+			utils.RequestLogger(req).Error("import wallet", "address", myWallet.BlockchainAddress(), "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return nil, false
+		}
+
+		addresses = append(addresses, HDAddress{
+			Index:     i,
+			Address:   myWallet.BlockchainAddress(),
+			PublicKey: myWallet.PublicKeyStr(),
+			Amount:    amount,
+		})
+	}
+
+	// Only the first address backs the session; the rest stay locked.
+	if _, err := ws.keystore.Unlock(addresses[0].Address, hr.Password); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, string(utils.JsonStatus("address already stored under another password")))
+		return nil, false
+	}
+
+	return addresses, true
+}
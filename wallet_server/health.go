@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+)
+
+// GATEWAY_CHECK_TIMEOUT bounds the gateway call made by /readyz.
+const GATEWAY_CHECK_TIMEOUT = 3 * time.Second
+
+// Healthz reports that the process is serving.
+func (ws *WalletServer) Healthz(w http.ResponseWriter, req *http.Request) {
+	writeHealth(w, http.StatusOK, &client.HealthResponse{Message: "ok"})
+}
+
+// Readyz reports ready when the keystore can be read and the gateway
+// node is itself ready.
+func (ws *WalletServer) Readyz(w http.ResponseWriter, req *http.Request) {
+
+	checks := map[string]string{
+		"keystore": "ok",
+		"gateway":  "ok",
+	}
+	ready := true
+
+	if _, err := ws.keystore.List(); err != nil {
+		checks["keystore"] = err.Error()
+		ready = false
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), GATEWAY_CHECK_TIMEOUT)
+	defer cancel()
+
+	if err := ws.client.Ready(ctx); err != nil {
+		checks["gateway"] = err.Error()
+		ready = false
+	}
+
+	if !ready {
+		writeHealth(w, http.StatusServiceUnavailable, &client.HealthResponse{Message: "not ready", Checks: checks})
+		return
+	}
+
+	writeHealth(w, http.StatusOK, &client.HealthResponse{Message: "ready", Checks: checks})
+}
+
+func writeHealth(w http.ResponseWriter, status int, hr *client.HealthResponse) {
+	m, _ := json.Marshal(hr)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(m)
+}
@@ -1,19 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
-)
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
-func init() {
-	log.SetPrefix("Wallet Server: ")
-}
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/wallet"
+)
 
 func main() {
-	port := flag.Uint("port", 8080, "TCP Port Number for Wallet Server")
-	gateway := flag.String("gateway", "http://127.0.0.1:5000", "Blockchain Gateway")
-	flag.Parse()
 
-	app := NewWalletServer(uint16(*port), *gateway)
-	app.Run()
+	cfg, err := config.LoadWallet(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+
+	logger, err := cfg.Log.Logger(os.Stderr)
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger.With("service", "wallet_server"))
+
+	keystore, err := wallet.NewKeystore(cfg.Keystore)
+	if err != nil {
+		slog.Error("open keystore", "path", cfg.Keystore, "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := NewWalletServer(cfg, keystore)
+	if err := app.Run(ctx); err != nil {
+		slog.Error("stopped", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("stopped")
 }
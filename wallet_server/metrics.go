@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatewayTransport counts gateway requests that fail outright or with a
+// server error, by method and path. Retries are counted separately. A TLS
+// config replaces the default verification of an https gateway.
+type gatewayTransport struct {
+	next     http.RoundTripper
+	failures *prometheus.CounterVec
+}
+
+func newGatewayTransport(reg prometheus.Registerer, tlsConfig *tls.Config) *gatewayTransport {
+
+	next := http.DefaultTransport
+	if tlsConfig != nil {
+		ht := http.DefaultTransport.(*http.Transport).Clone()
+		ht.TLSClientConfig = tlsConfig
+		next = ht
+	}
+
+	t := &gatewayTransport{
+		next: next,
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wallet_gateway_request_failures_total",
+			Help: "Failed requests to the blockchain gateway, by operation.",
+		}, []string{"operation"}),
+	}
+	reg.MustRegister(t.failures)
+
+	return t
+}
+
+func (t *gatewayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	resp, err := t.next.RoundTrip(req)
+
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		t.failures.WithLabelValues(req.Method + " " + req.URL.Path).Inc()
+	}
+
+	return resp, err
+}
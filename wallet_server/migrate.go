@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/i101dev/blockchain-api/utils"
+)
+
+type MigrateResponse struct {
+	Address       string  `json:"address"`
+	PublicKey     string  `json:"public_key"`
+	LegacyAddress string  `json:"legacy_address"`
+	Moved         float32 `json:"moved"`
+}
+
+// MigrateWallet moves a legacy keystore wallet onto its RIPEMD-160
+// address: the key is re-stored under the new address, any balance at the
+// old one is transferred across, and the session switches to the new
+// address.
+func (ws *WalletServer) MigrateWallet(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	pr, ok := decodePassword(w, req)
+	if !ok {
+		return
+	}
+
+	myWallet, err := ws.keystore.Migrate(pr.Address, pr.Password)
+	if err != nil {
+		utils.RequestLogger(req).Info("migrate wallet", "address", pr.Address, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, string(utils.JsonStatus("invalid address or password")))
+		return
+	}
+
+	resp := &MigrateResponse{
+		Address:       myWallet.BlockchainAddress(),
+		PublicKey:     myWallet.PublicKeyStr(),
+		LegacyAddress: pr.Address,
+	}
+
+	if pr.Address != myWallet.BlockchainAddress() {
+
+		amt, err := ws.client.Amount(req.Context(), pr.Address)
+		if err != nil {
+			utils.RequestLogger(req).Warn("legacy balance", "address", pr.Address, "error", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("balance query failed")))
+			return
+		}
+
+		if amt.Amount > 0 {
+			if err := ws.submit(req.Context(), myWallet, pr.Address, myWallet.BlockchainAddress(), amt.Amount); err != nil {
+				utils.RequestLogger(req).Warn("migrate funds", "address", pr.Address, "error", err)
+				w.WriteHeader(http.StatusBadGateway)
+				io.WriteString(w, string(utils.JsonStatus("transfer failed")))
+				return
+			}
+			resp.Moved = amt.Amount
+		}
+	}
+
+	ws.sessions.Create(w, myWallet.BlockchainAddress())
+
+	m, _ := json.Marshal(resp)
+	io.WriteString(w, string(m[:]))
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+)
+
+type MultisigRequest struct {
+	Threshold  int      `json:"threshold"`
+	PublicKeys []string `json:"public_keys"`
+}
+
+type MultisigResponse struct {
+	Address    string   `json:"address"`
+	Threshold  int      `json:"threshold"`
+	PublicKeys []string `json:"public_keys"`
+}
+
+type ProposalRequest struct {
+	Threshold                  int      `json:"threshold"`
+	PublicKeys                 []string `json:"public_keys"`
+	RecipientBlockchainAddress string   `json:"recipient_blockchain_address"`
+	Value                      float32  `json:"value"`
+}
+
+// CosignRequest carries a signature made elsewhere, such as by the
+// offline signing tool. An empty body signs with the session wallet.
+type CosignRequest struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// Proposal is a multisig transfer collecting co-signatures. PublicKeys
+// are in canonical order and Signatures are aligned to them, empty until
+// that key signs. Once the threshold is met it is submitted to the node.
+// It is dropped at ExpiresAt, submitted or not.
+type Proposal struct {
+	ID                         string   `json:"id"`
+	SenderBlockchainAddress    string   `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress string   `json:"recipient_blockchain_address"`
+	Value                      float32  `json:"value"`
+	Threshold                  int      `json:"threshold"`
+	PublicKeys                 []string `json:"public_keys"`
+	Signatures                 []string `json:"signatures"`
+	Submitted                  bool      `json:"submitted"`
+	ExpiresAt                  time.Time `json:"expires_at"`
+
+	multisig *wallet.Multisig
+	// proposer is the address of the session wallet that created it.
+	proposer string
+}
+
+func (p *Proposal) signed() int {
+	n := 0
+	for _, s := range p.Signatures {
+		if s != "" {
+			n++
+		}
+	}
+	return n
+}
+
+const (
+	// MAX_PROPOSALS caps the proposals held in memory at once.
+	MAX_PROPOSALS = 1000
+	// MAX_PROPOSALS_PER_PROPOSER caps the unsubmitted proposals one
+	// wallet has open.
+	MAX_PROPOSALS_PER_PROPOSER = 10
+	// PROPOSAL_TTL is how long a proposal is kept after it is created.
+	PROPOSAL_TTL = 24 * time.Hour
+)
+
+var ErrTooManyProposals = errors.New("too many pending proposals")
+
+// ProposalStore keeps pending proposals in memory; they are lost on
+// restart and must be proposed again.
+type ProposalStore struct {
+	mux         sync.Mutex
+	proposals   map[string]*Proposal
+	max         int
+	maxProposer int
+	ttl         time.Duration
+}
+
+func NewProposalStore() *ProposalStore {
+	return &ProposalStore{
+		proposals:   make(map[string]*Proposal),
+		max:         MAX_PROPOSALS,
+		maxProposer: MAX_PROPOSALS_PER_PROPOSER,
+		ttl:         PROPOSAL_TTL,
+	}
+}
+
+// Create stores p under a new ID until the TTL passes. It fails with
+// ErrTooManyProposals when p's proposer already has the most unsubmitted
+// proposals allowed, or when the store is full even after dropping
+// submitted proposals.
+func (ps *ProposalStore) Create(p *Proposal) error {
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	p.ID = hex.EncodeToString(b)
+
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	p.ExpiresAt = time.Now().Add(ps.ttl)
+
+	open := 0
+	for _, old := range ps.proposals {
+		if old.proposer == p.proposer && !old.Submitted {
+			open++
+		}
+	}
+	if open >= ps.maxProposer {
+		return ErrTooManyProposals
+	}
+
+	if len(ps.proposals) >= ps.max {
+		for id, old := range ps.proposals {
+			if old.Submitted {
+				delete(ps.proposals, id)
+			}
+		}
+	}
+	if len(ps.proposals) >= ps.max {
+		return ErrTooManyProposals
+	}
+
+	ps.proposals[p.ID] = p
+	return nil
+}
+
+func (ps *ProposalStore) Get(id string) (*Proposal, bool) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	p, ok := ps.proposals[id]
+	if ok && time.Now().After(p.ExpiresAt) {
+		return nil, false
+	}
+	return p, ok
+}
+
+// Reap drops the proposals that expired by now.
+func (ps *ProposalStore) Reap(now time.Time) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	for id, p := range ps.proposals {
+		if now.After(p.ExpiresAt) {
+			delete(ps.proposals, id)
+		}
+	}
+}
+
+// ------------------------------------------------------------------
+
+// Multisig returns the address of an M-of-N account without storing
+// anything.
+func (ws *WalletServer) Multisig(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	var mr MultisigRequest
+	if err := json.NewDecoder(req.Body).Decode(&mr); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("threshold and public_keys required")))
+		return
+	}
+
+	ms, err := wallet.ParseMultisig(mr.Threshold, mr.PublicKeys)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+
+	m, _ := json.Marshal(&MultisigResponse{
+		Address:    ms.Address(),
+		Threshold:  ms.Threshold(),
+		PublicKeys: ms.PublicKeyStrs(),
+	})
+	io.WriteString(w, string(m[:]))
+}
+
+// CreateProposal starts a transfer from a multisig account. The session
+// wallet must be one of the account's co-signers.
+func (ws *WalletServer) CreateProposal(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	myWallet, ok := ws.sessionWallet(w, req)
+	if !ok {
+		return
+	}
+
+	var pr ProposalRequest
+	if err := json.NewDecoder(req.Body).Decode(&pr); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("invalid proposal")))
+		return
+	}
+
+	if !(pr.Value > 0) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("value must be positive")))
+		return
+	}
+
+	ms, err := wallet.ParseMultisig(pr.Threshold, pr.PublicKeys)
+	if err == nil {
+		err = wallet.ValidateAddress(pr.RecipientBlockchainAddress)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+
+	if !ms.Contains(myWallet.PublicKey()) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, string(utils.JsonStatus("session wallet is not a co-signer")))
+		return
+	}
+
+	p := &Proposal{
+		SenderBlockchainAddress:    ms.Address(),
+		RecipientBlockchainAddress: pr.RecipientBlockchainAddress,
+		Value:                      pr.Value,
+		Threshold:                  ms.Threshold(),
+		PublicKeys:                 ms.PublicKeyStrs(),
+		Signatures:                 make([]string, len(ms.PublicKeys())),
+		multisig:                   ms,
+		proposer:                   myWallet.BlockchainAddress(),
+	}
+	if err := ws.proposals.Create(p); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+
+	m, _ := json.Marshal(p)
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, string(m[:]))
+}
+
+func (ws *WalletServer) GetProposal(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	p, ok := ws.proposals.Get(req.PathValue("id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, string(utils.JsonStatus("proposal not found")))
+		return
+	}
+
+	ws.proposals.mux.Lock()
+	m, _ := json.Marshal(p)
+	ws.proposals.mux.Unlock()
+
+	io.WriteString(w, string(m[:]))
+}
+
+// SignProposal adds one co-signature to a proposal and submits it once
+// the threshold is met.
+func (ws *WalletServer) SignProposal(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	p, ok := ws.proposals.Get(req.PathValue("id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, string(utils.JsonStatus("proposal not found")))
+		return
+	}
+
+	var cr CosignRequest
+	if err := json.NewDecoder(req.Body).Decode(&cr); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("invalid signature request")))
+		return
+	}
+
+	hash := wallet.TransactionHash(p.SenderBlockchainAddress, p.RecipientBlockchainAddress, p.Value)
+
+	var publicKey utils.PublicKey
+	var signature *utils.Signature
+
+	if cr.PublicKey == "" && cr.Signature == "" {
+
+		myWallet, ok := ws.sessionWallet(w, req)
+		if !ok {
+			return
+		}
+
+		var err error
+		publicKey = myWallet.PublicKey()
+		if signature, err = myWallet.PrivateKey().Sign(hash[:]); err != nil {
+			utils.RequestLogger(req).Info("co-sign", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+	} else {
+
+		var err error
+		if publicKey, err = utils.ParsePublicKey(cr.PublicKey); err == nil {
+			signature, err = utils.ParseSignature(cr.Signature)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+	}
+
+	err := p.multisig.Verify(hash[:], map[string]*utils.Signature{publicKey.String(): signature})
+	if err != nil && !errors.Is(err, wallet.ErrThresholdNotMet) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+
+	ws.proposals.mux.Lock()
+	for i, k := range p.PublicKeys {
+		if k == publicKey.String() {
+			p.Signatures[i] = signature.String()
+		}
+	}
+	ready := !p.Submitted && p.signed() >= p.Threshold
+	if ready {
+		p.Submitted = true
+	}
+	tr := p.request()
+	ws.proposals.mux.Unlock()
+
+	if ready {
+		if err := ws.client.SubmitTransaction(req.Context(), tr); err != nil {
+			utils.RequestLogger(req).Warn("submit multisig proposal", "proposal", p.ID, "error", err)
+
+			ws.proposals.mux.Lock()
+			p.Submitted = false
+			ws.proposals.mux.Unlock()
+
+			var apiErr *client.APIError
+			if errors.As(err, &apiErr) {
+				w.WriteHeader(http.StatusBadRequest)
+			} else {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+			io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("submit failed: %v", err))))
+			return
+		}
+	}
+
+	ws.proposals.mux.Lock()
+	m, _ := json.Marshal(p)
+	ws.proposals.mux.Unlock()
+
+	io.WriteString(w, string(m[:]))
+}
+
+// request must be called with the store locked.
+func (p *Proposal) request() *client.TransactionRequest {
+
+	sender, recipient, value, threshold := p.SenderBlockchainAddress, p.RecipientBlockchainAddress, p.Value, p.Threshold
+
+	return &client.TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		Value:                      &value,
+		Threshold:                  &threshold,
+		PublicKeys:                 append([]string(nil), p.PublicKeys...),
+		Signatures:                 append([]string(nil), p.Signatures...),
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/utils"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultisigProposal(t *testing.T) {
+	//
+	var relayed *client.TransactionRequest
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		relayed = new(client.TransactionRequest)
+		json.NewDecoder(req.Body).Decode(relayed)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer gateway.Close()
+	//
+	keystore, _ := wallet.NewKeystore(t.TempDir())
+	srv := httptest.NewServer(NewWalletServer(testConfig(gateway.URL), keystore).Router())
+	defer srv.Close()
+	//
+	resp, err := http.Post(srv.URL+"/v1/wallet", "application/json", strings.NewReader(`{"password":"secret"}`))
+	assert.NoError(t, err)
+	var mine map[string]string
+	json.NewDecoder(resp.Body).Decode(&mine)
+	cookies := resp.Cookies()
+	//
+	offline, outsider := wallet.NewWallet(), wallet.NewWallet()
+	keys := `["` + mine["public_key"] + `","` + offline.PublicKeyStr() + `","` + wallet.NewWallet().PublicKeyStr() + `"]`
+	recipient := wallet.NewWallet().BlockchainAddress()
+	//
+	propose := func(value string, cookies []*http.Cookie) *http.Response {
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/multisig/proposals",
+			strings.NewReader(`{"threshold":2,"public_keys":`+keys+`,"recipient_blockchain_address":"`+recipient+`","value":`+value+`}`))
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+	assert.Equal(t, http.StatusUnauthorized, propose("4", nil).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, propose("0", cookies).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, propose("-4", cookies).StatusCode)
+	//
+	resp, err = http.Post(srv.URL+"/v1/wallet", "application/json", strings.NewReader(`{"password":"secret"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, propose("4", resp.Cookies()).StatusCode)
+	//
+	resp = propose("4", cookies)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var proposal Proposal
+	json.NewDecoder(resp.Body).Decode(&proposal)
+	//
+	sign := func(body string, cookies []*http.Cookie) (int, *Proposal) {
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/multisig/proposals/"+proposal.ID+"/signatures", strings.NewReader(body))
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		var p Proposal
+		json.NewDecoder(resp.Body).Decode(&p)
+		return resp.StatusCode, &p
+	}
+	hash := wallet.TransactionHash(proposal.SenderBlockchainAddress, recipient, 4)
+	cosign := func(w *wallet.Wallet) string {
+		sig, _ := w.PrivateKey().Sign(hash[:])
+		return `{"public_key":"` + w.PublicKeyStr() + `","signature":"` + sig.String() + `"}`
+	}
+	//
+	status, _ := sign(cosign(outsider), nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	//
+	status, p := sign("", cookies)
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, p.Submitted)
+	assert.Nil(t, relayed)
+	//
+	status, p = sign(cosign(offline), nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, p.Submitted)
+	//
+	assert.Equal(t, proposal.SenderBlockchainAddress, *relayed.SenderBlockchainAddress)
+	assert.Equal(t, 2, *relayed.Threshold)
+	ms, _ := wallet.ParseMultisig(*relayed.Threshold, relayed.PublicKeys)
+	assert.Equal(t, proposal.SenderBlockchainAddress, ms.Address())
+	signatures := map[string]*utils.Signature{}
+	for i, s := range relayed.Signatures {
+		if s != "" {
+			signatures[relayed.PublicKeys[i]], _ = utils.ParseSignature(s)
+		}
+	}
+	assert.NoError(t, ms.Verify(hash[:], signatures))
+}
+
+func TestProposalStoreLimit(t *testing.T) {
+	//
+	ps := NewProposalStore()
+	ps.max = 2
+	first, second := &Proposal{proposer: "alice"}, &Proposal{proposer: "bob"}
+	assert.NoError(t, ps.Create(first))
+	assert.NoError(t, ps.Create(second))
+	assert.ErrorIs(t, ps.Create(&Proposal{}), ErrTooManyProposals)
+	//
+	first.Submitted = true
+	assert.NoError(t, ps.Create(&Proposal{}))
+	_, ok := ps.Get(first.ID)
+	assert.False(t, ok)
+	_, ok = ps.Get(second.ID)
+	assert.True(t, ok)
+}
+
+func TestProposalStoreProposerLimit(t *testing.T) {
+	//
+	ps := NewProposalStore()
+	ps.maxProposer = 2
+	first := &Proposal{proposer: "alice"}
+	assert.NoError(t, ps.Create(first))
+	assert.NoError(t, ps.Create(&Proposal{proposer: "alice"}))
+	assert.ErrorIs(t, ps.Create(&Proposal{proposer: "alice"}), ErrTooManyProposals)
+	assert.NoError(t, ps.Create(&Proposal{proposer: "bob"}))
+	//
+	first.Submitted = true
+	assert.NoError(t, ps.Create(&Proposal{proposer: "alice"}))
+}
+
+func TestProposalStoreExpiry(t *testing.T) {
+	//
+	ps := NewProposalStore()
+	ps.ttl = 20 * time.Millisecond
+	p := &Proposal{proposer: "alice"}
+	assert.NoError(t, ps.Create(p))
+	_, ok := ps.Get(p.ID)
+	assert.True(t, ok)
+	//
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reapEvery(ctx, 5*time.Millisecond, ps.Reap)
+	assert.Eventually(t, func() bool {
+		ps.mux.Lock()
+		defer ps.mux.Unlock()
+		return len(ps.proposals) == 0
+	}, time.Second, 5*time.Millisecond)
+	_, ok = ps.Get(p.ID)
+	assert.False(t, ok)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SESSION_COOKIE = "wallet_session"
+	SESSION_TTL    = 30 * time.Minute
+)
+
+type Session struct {
+	Token   string
+	Address string
+	expires time.Time
+}
+
+// SessionStore maps opaque cookie tokens to the wallet each session has
+// unlocked. Sessions expire after SESSION_TTL without use, when onExpire
+// is called for them.
+type SessionStore struct {
+	mux      sync.Mutex
+	sessions map[string]*Session
+	ttl      time.Duration
+	onExpire func(*Session)
+	// secure limits the cookie to HTTPS, set when the server serves TLS.
+	secure bool
+}
+
+func NewSessionStore(onExpire func(*Session)) *SessionStore {
+	return &SessionStore{
+		sessions: make(map[string]*Session),
+		ttl:      SESSION_TTL,
+		onExpire: onExpire,
+	}
+}
+
+func (ss *SessionStore) Create(w http.ResponseWriter, address string) *Session {
+
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	s := &Session{
+		Token:   hex.EncodeToString(b),
+		Address: address,
+		expires: time.Now().Add(ss.ttl),
+	}
+
+	ss.Reap(time.Now())
+
+	ss.mux.Lock()
+	ss.sessions[s.Token] = s
+	ss.mux.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SESSION_COOKIE,
+		Value:    s.Token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   ss.secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return s
+}
+
+// Get returns the live session for the request and extends it.
+func (ss *SessionStore) Get(req *http.Request) (*Session, bool) {
+
+	c, err := req.Cookie(SESSION_COOKIE)
+	if err != nil {
+		return nil, false
+	}
+
+	ss.mux.Lock()
+	s, ok := ss.sessions[c.Value]
+	if ok && time.Now().After(s.expires) {
+		delete(ss.sessions, c.Value)
+		ss.mux.Unlock()
+		ss.onExpire(s)
+		return nil, false
+	}
+	if ok {
+		s.expires = time.Now().Add(ss.ttl)
+	}
+	ss.mux.Unlock()
+
+	return s, ok
+}
+
+// Reap ends the sessions that expired by now, so their keys are locked
+// even if they are never used again.
+func (ss *SessionStore) Reap(now time.Time) {
+
+	ss.mux.Lock()
+	expired := make([]*Session, 0)
+	for token, s := range ss.sessions {
+		if now.After(s.expires) {
+			delete(ss.sessions, token)
+			expired = append(expired, s)
+		}
+	}
+	ss.mux.Unlock()
+
+	for _, s := range expired {
+		ss.onExpire(s)
+	}
+}
+
+func (ss *SessionStore) Delete(w http.ResponseWriter, s *Session) {
+
+	ss.mux.Lock()
+	delete(ss.sessions, s.Token)
+	ss.mux.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SESSION_COOKIE,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   ss.secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
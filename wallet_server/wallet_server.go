@@ -1,210 +1,415 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"path"
-	"strconv"
 	"text/template"
+	"time"
 
-	"github.com/i101dev/blockchain-api/blockchain"
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/config"
 	"github.com/i101dev/blockchain-api/utils"
 	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-const tempDir = "templates"
+const (
+	tempDir = "templates"
+
+	REQUEST_TIMEOUT  = 30 * time.Second
+	MAX_BODY_BYTES   = 1 << 20
+	SHUTDOWN_TIMEOUT = 15 * time.Second
+	// REAP_INTERVAL is how often expired sessions are ended and expired
+	// proposals dropped.
+	REAP_INTERVAL = time.Minute
+)
 
 type WalletServer struct {
-	port    uint16
-	gateway string
+	cfg       *config.Wallet
+	client    *client.Client
+	keystore  *wallet.Keystore
+	sessions  *SessionStore
+	proposals *ProposalStore
+
+	registry    *prometheus.Registry
+	httpMetrics *utils.HTTPMetrics
 }
 
-func NewWalletServer(port uint16, gateway string) *WalletServer {
-	return &WalletServer{port, gateway}
+func NewWalletServer(cfg *config.Wallet, keystore *wallet.Keystore) *WalletServer {
+	ws := &WalletServer{
+		cfg:       cfg,
+		keystore:  keystore,
+		proposals: NewProposalStore(),
+		registry:  utils.NewRegistry(),
+	}
+	ws.httpMetrics = utils.NewHTTPMetrics(ws.registry)
+	ws.client = client.New(cfg.Gateway, client.WithHTTPClient(&http.Client{
+		Transport: newGatewayTransport(ws.registry, cfg.GatewayTLS()),
+		Timeout:   client.DEFAULT_TIMEOUT,
+	}), client.WithToken(cfg.GatewayToken))
+
+	ws.sessions = NewSessionStore(func(s *Session) {
+		ws.keystore.Lock(s.Address)
+	})
+	ws.sessions.secure = cfg.TLS.Enabled()
+	return ws
 }
 
 func (ws *WalletServer) Port() uint16 {
-	return ws.port
+	return ws.cfg.API.Port
 }
 
 func (ws *WalletServer) Gateway() string {
-	return ws.gateway
+	return ws.cfg.Gateway
 }
 
 func (ws *WalletServer) Index(w http.ResponseWriter, req *http.Request) {
-	switch req.Method {
-	case http.MethodGet:
-		t, err := template.ParseFiles(path.Join(tempDir, "index.html"))
-		if err != nil {
-			http.Error(w, "Unable to load template", http.StatusInternalServerError)
-			log.Printf("ERROR: Unable to load template: %v", err)
-			return
-		}
-		err = t.Execute(w, "")
-		if err != nil {
-			http.Error(w, "Unable to execute template", http.StatusInternalServerError)
-			log.Printf("ERROR: Unable to execute template: %v", err)
-		}
-	default:
-		log.Printf("ERROR: Invalid HTTP Method")
+	t, err := template.ParseFiles(path.Join(tempDir, "index.html"))
+	if err != nil {
+		http.Error(w, "Unable to load template", http.StatusInternalServerError)
+		utils.RequestLogger(req).Error("load template", "error", err)
+		return
+	}
+	err = t.Execute(w, "")
+	if err != nil {
+		http.Error(w, "Unable to execute template", http.StatusInternalServerError)
+		utils.RequestLogger(req).Error("execute template", "error", err)
 	}
 }
 
-func (ws *WalletServer) Wallet(w http.ResponseWriter, req *http.Request) {
-	switch req.Method {
-	case http.MethodPost:
-		w.Header().Add("Content-Type", "application/json")
-		myWallet := wallet.NewWallet()
-		m, _ := myWallet.MarshalJSON()
-		io.WriteString(w, string(m[:]))
-	default:
+type PasswordRequest struct {
+	Address   string          `json:"address"`
+	Password  string          `json:"password"`
+	Algorithm utils.Algorithm `json:"algorithm,omitempty"`
+}
+
+func decodePassword(w http.ResponseWriter, req *http.Request) (*PasswordRequest, bool) {
+
+	var pr PasswordRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&pr); err != nil || pr.Password == "" {
 		w.WriteHeader(http.StatusBadRequest)
-		log.Panicln("ERROR: Invalid HTTP Method")
+		io.WriteString(w, string(utils.JsonStatus("password required")))
+		return nil, false
 	}
+
+	return &pr, true
 }
 
-func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Request) {
+// Wallet creates a keystore wallet encrypted under the given password and
+// opens a session for it. Only the public key and address are returned.
+// The key algorithm defaults to P-256.
+func (ws *WalletServer) Wallet(w http.ResponseWriter, req *http.Request) {
 
-	switch req.Method {
+	w.Header().Add("Content-Type", "application/json")
 
-	case http.MethodPost:
+	pr, ok := decodePassword(w, req)
+	if !ok {
+		return
+	}
 
-		var txn wallet.WalletTXNRequest
+	if !pr.Algorithm.Valid() {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("unsupported algorithm")))
+		return
+	}
 
-		decoder := json.NewDecoder(req.Body)
-		err := decoder.Decode(&txn)
+	myWallet, err := ws.keystore.CreateWithAlgorithm(pr.Password, pr.Algorithm)
+	if err != nil {
+		utils.RequestLogger(req).Error("create wallet", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
 
-		if err != nil {
-			log.Printf("ERROR decoding wallet transaction: %+v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if !txn.Validate() {
-			log.Println("ERROR: Missing field(s)")
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+	ws.sessions.Create(w, myWallet.BlockchainAddress())
 
-		publicKey := utils.PublicKeyFromString(*txn.SenderPublicKey)
-		privateKey := utils.PrivateKeyFromString(*txn.SenderPrivateKey, publicKey)
-		value32 := float32(*txn.Value)
+	m, _ := myWallet.MarshalJSON()
+	io.WriteString(w, string(m[:]))
+}
 
-		w.Header().Add("Content-Type", "application/json")
+func (ws *WalletServer) Wallets(w http.ResponseWriter, req *http.Request) {
 
-		transaction := wallet.NewWalletTransaction(privateKey, publicKey, *txn.SenderBlockchainAddress, *txn.RecipientBlockchainAddress, value32)
-		signature := transaction.GenerateSignature()
-		signatureStr := signature.String()
+	w.Header().Add("Content-Type", "application/json")
 
-		bt := &blockchain.TransactionRequest{
-			RecipientBlockchainAddress: txn.RecipientBlockchainAddress,
-			SenderBlockchainAddress:    txn.SenderBlockchainAddress,
-			SenderPublicKey:            txn.SenderPublicKey,
-			Signature:                  &signatureStr,
-			Value:                      &value32,
-		}
+	addresses, err := ws.keystore.List()
+	if err != nil {
+		utils.RequestLogger(req).Error("list wallets", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-		m, _ := json.Marshal(bt)
-		buf := bytes.NewBuffer(m)
+	m, _ := json.Marshal(struct {
+		Addresses []string `json:"addresses"`
+	}{
+		Addresses: addresses,
+	})
 
-		resp, _ := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
+	io.WriteString(w, string(m[:]))
+}
 
-		if resp.StatusCode == 201 {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Transaction processed successfully"))
-			// fmt.Println("\n*** >>> TRANSACTION SUCCESS! <<< ***")
-			return
-		}
+func (ws *WalletServer) Login(w http.ResponseWriter, req *http.Request) {
 
-		io.WriteString(w, "Transaction FAILED!")
-		fmt.Println("\n*** >>> TRANSACTION FAILED! <<< ***")
+	w.Header().Add("Content-Type", "application/json")
 
-	default:
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+	pr, ok := decodePassword(w, req)
+	if !ok {
+		return
+	}
+
+	myWallet, err := ws.keystore.Unlock(pr.Address, pr.Password)
+	if err != nil {
+		utils.RequestLogger(req).Info("unlock wallet", "address", pr.Address, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, string(utils.JsonStatus("invalid address or password")))
+		return
 	}
+
+	ws.sessions.Create(w, myWallet.BlockchainAddress())
+
+	m, _ := myWallet.MarshalJSON()
+	io.WriteString(w, string(m[:]))
 }
 
-func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
+func (ws *WalletServer) Session(w http.ResponseWriter, req *http.Request) {
 
-	switch req.Method {
-	case http.MethodGet:
+	w.Header().Add("Content-Type", "application/json")
 
-		blockchainAddress := req.URL.Query().Get("blockchain_address")
-		endpoint := fmt.Sprintf("%s/amount", ws.Gateway())
+	myWallet, ok := ws.sessionWallet(w, req)
+	if !ok {
+		return
+	}
 
-		// _ = blockchainAddress
-		// _ = endpoint
+	m, _ := myWallet.MarshalJSON()
+	io.WriteString(w, string(m[:]))
+}
 
-		client := &http.Client{}
-		bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+func (ws *WalletServer) Logout(w http.ResponseWriter, req *http.Request) {
 
-		// _ = client
-		// _ = bcsReq
+	w.Header().Add("Content-Type", "application/json")
 
-		q := bcsReq.URL.Query()
-		q.Add("blockchain_address", blockchainAddress)
-		bcsReq.URL.RawQuery = q.Encode()
+	if s, ok := ws.sessions.Get(req); ok {
+		ws.sessions.Delete(w, s)
+		ws.keystore.Lock(s.Address)
+	}
 
-		bcsResp, err := client.Do(bcsReq)
-		if err != nil {
-			log.Printf("%+v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+	io.WriteString(w, string(utils.JsonStatus("success")))
+}
 
-		w.Header().Add("Content-Type", "application/json")
+// sessionWallet writes a 401 and returns false unless the request carries
+// a live session whose wallet is still unlocked.
+func (ws *WalletServer) sessionWallet(w http.ResponseWriter, req *http.Request) (*wallet.Wallet, bool) {
 
-		if bcsResp.StatusCode == 200 {
+	s, ok := ws.sessions.Get(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, string(utils.JsonStatus("login required")))
+		return nil, false
+	}
 
-			var amt blockchain.AmountResponse
-			decoder := json.NewDecoder(bcsResp.Body)
-			err := decoder.Decode(&amt)
+	myWallet, err := ws.keystore.Wallet(s.Address)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, string(utils.JsonStatus("wallet locked")))
+		return nil, false
+	}
 
-			if err != nil {
-				log.Printf("%+v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
+	return myWallet, true
+}
 
-			m, _ := json.Marshal(struct {
-				Message string  `json:"message"`
-				Amount  float32 `json:"amount"`
-			}{
-				Message: "success",
-				Amount:  amt.Amount,
-			})
+func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Request) {
 
-			io.WriteString(w, string(m[:]))
+	var txn wallet.WalletTXNRequest
 
-		} else {
+	w.Header().Add("Content-Type", "application/json")
 
-			m, _ := json.Marshal(struct {
-				Message string `json:"message"`
-			}{
-				Message: "QUERY FAILED",
-			})
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&txn)
 
-			io.WriteString(w, string(m[:]))
-		}
+	if err != nil {
+		utils.RequestLogger(req).Info("malformed transaction request", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := txn.Validate(); err != nil {
+		utils.RequestLogger(req).Info("invalid transaction request", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+
+	myWallet, ok := ws.sessionWallet(w, req)
+	if !ok {
+		return
+	}
+
+	if myWallet.BlockchainAddress() != *txn.WalletID {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, string(utils.JsonStatus("wallet not owned by session")))
+		return
+	}
+
+	err = ws.submit(req.Context(), myWallet, myWallet.BlockchainAddress(), *txn.RecipientBlockchainAddress, *txn.Value)
+
+	if err == nil {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, string(utils.JsonStatus("success")))
+		return
+	}
+
+	utils.RequestLogger(req).Warn("submit transaction", "error", err)
 
-	default:
-		log.Println("ERROR: Invalid HTTP Method")
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
 		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		w.WriteHeader(http.StatusBadGateway)
 	}
+	io.WriteString(w, string(utils.JsonStatus("fail")))
 }
 
-func (ws *WalletServer) Run() {
+// submit signs a transfer with the wallet's key and sends it to the
+// gateway. The sender is passed separately so a migrated key can still
+// spend from its legacy address.
+func (ws *WalletServer) submit(ctx context.Context, myWallet *wallet.Wallet, sender string, recipient string, value float32) error {
 
-	http.HandleFunc("/", ws.Index)
-	http.HandleFunc("/wallet", ws.Wallet)
-	http.HandleFunc("/wallet/amount", ws.WalletAmount)
-	http.HandleFunc("/transaction", ws.CreateTransaction)
+	publicKeyStr := myWallet.PublicKeyStr()
 
-	hostURL := "0.0.0.0:" + strconv.Itoa(int(ws.Port()))
+	transaction := wallet.NewWalletTransaction(myWallet.PrivateKey(), myWallet.PublicKey(), sender, recipient, value)
+	signatureStr := transaction.GenerateSignature().String()
 
-	fmt.Println("Wallet Server is live @:", hostURL)
-	log.Fatal(http.ListenAndServe(hostURL, nil))
+	return ws.client.SubmitTransaction(ctx, &client.TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKeyStr,
+		Signature:                  &signatureStr,
+		Value:                      &value,
+	})
+}
+
+func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
+
+	blockchainAddress := req.URL.Query().Get("blockchain_address")
+
+	w.Header().Add("Content-Type", "application/json")
+
+	amt, err := ws.client.Amount(req.Context(), blockchainAddress)
+
+	if err != nil {
+
+		utils.RequestLogger(req).Warn("query balance", "address", blockchainAddress, "error", err)
+
+		m, _ := json.Marshal(struct {
+			Message string `json:"message"`
+		}{
+			Message: "QUERY FAILED",
+		})
+
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, string(m[:]))
+		return
+	}
+
+	m, _ := json.Marshal(struct {
+		Message string  `json:"message"`
+		Amount  float32 `json:"amount"`
+	}{
+		Message: "success",
+		Amount:  amt.Amount,
+	})
+
+	io.WriteString(w, string(m[:]))
+}
+
+func (ws *WalletServer) Router() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", ws.Index)
+	mux.HandleFunc("POST /v1/wallet", ws.Wallet)
+	mux.HandleFunc("POST /v1/wallet/hd", ws.CreateHDWallet)
+	mux.HandleFunc("POST /v1/wallet/restore", ws.RestoreHDWallet)
+	mux.HandleFunc("POST /v1/wallet/migrate", ws.MigrateWallet)
+	mux.HandleFunc("POST /v1/multisig", ws.Multisig)
+	mux.HandleFunc("POST /v1/multisig/proposals", ws.CreateProposal)
+	mux.HandleFunc("GET /v1/multisig/proposals/{id}", ws.GetProposal)
+	mux.HandleFunc("POST /v1/multisig/proposals/{id}/signatures", ws.SignProposal)
+	mux.HandleFunc("GET /v1/wallets", ws.Wallets)
+	mux.HandleFunc("POST /v1/session", ws.Login)
+	mux.HandleFunc("GET /v1/session", ws.Session)
+	mux.HandleFunc("DELETE /v1/session", ws.Logout)
+	mux.HandleFunc("GET /v1/wallet/amount", ws.WalletAmount)
+	mux.HandleFunc("POST /v1/transaction", ws.CreateTransaction)
+	mux.Handle("GET /metrics", utils.MetricsHandler(ws.registry))
+	mux.HandleFunc("GET /healthz", ws.Healthz)
+	mux.HandleFunc("GET /readyz", ws.Readyz)
+
+	return utils.Chain(mux,
+		utils.RequestID,
+		ws.httpMetrics.Middleware,
+		utils.Logger,
+		utils.Recoverer,
+		utils.Timeout(REQUEST_TIMEOUT),
+		utils.MaxBodySize(MAX_BODY_BYTES),
+		utils.Route,
+	)
+}
+
+// Run serves the wallet API and reaps expired sessions and proposals
+// until ctx is cancelled or the server fails, then waits up to
+// SHUTDOWN_TIMEOUT for requests in flight.
+func (ws *WalletServer) Run(ctx context.Context) error {
+
+	hostURL := ws.cfg.API.Addr()
+
+	slog.Info("listening", "http", hostURL, "tls", ws.cfg.TLS.Enabled(), "gateway", ws.cfg.Gateway)
+
+	reapCtx, stopReaping := context.WithCancel(ctx)
+	defer stopReaping()
+	go reapEvery(reapCtx, REAP_INTERVAL, ws.sessions.Reap)
+	go reapEvery(reapCtx, REAP_INTERVAL, ws.proposals.Reap)
+
+	// Keys are posted to unlock wallets; serve HTTPS unless TLS is
+	// terminated in front of the server.
+	s := &http.Server{Addr: hostURL, Handler: ws.Router(), TLSConfig: ws.cfg.TLS.Server()}
+	errc := make(chan error, 1)
+	go func() {
+		if s.TLSConfig != nil {
+			errc <- s.ListenAndServeTLS("", "")
+			return
+		}
+		errc <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	return s.Shutdown(shutdownCtx)
+}
+
+// reapEvery calls reap with the current time every interval until ctx
+// is done.
+func reapEvery(ctx context.Context, interval time.Duration, reap func(time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reap(now)
+		}
+	}
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i101dev/blockchain-api/client"
+	"github.com/i101dev/blockchain-api/config"
+	"github.com/i101dev/blockchain-api/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig(gateway string) *config.Wallet {
+	cfg := config.DefaultWallet()
+	cfg.Gateway = gateway
+	return cfg
+}
+
+func TestServerSideSigning(t *testing.T) {
+	//
+	var relayed client.TransactionRequest
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&relayed)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer gateway.Close()
+	//
+	keystore, err := wallet.NewKeystore(t.TempDir())
+	assert.NoError(t, err)
+	srv := httptest.NewServer(NewWalletServer(testConfig(gateway.URL), keystore).Router())
+	defer srv.Close()
+	//
+	resp, err := http.Post(srv.URL+"/v1/wallet", "application/json", strings.NewReader(`{"password":"secret"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var created map[string]string
+	json.NewDecoder(resp.Body).Decode(&created)
+	assert.NotContains(t, created, "private_key")
+	cookies := resp.Cookies()
+	assert.Len(t, cookies, 1)
+	//
+	send := func(body string, cookies []*http.Cookie) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/transaction", strings.NewReader(body))
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	//
+	bob := wallet.NewWallet().BlockchainAddress()
+	body := `{"wallet_id":"` + created["address"] + `","recipient_blockchain_address":"` + bob + `","value":1.5}`
+	assert.Equal(t, http.StatusUnauthorized, send(body, nil))
+	assert.Equal(t, http.StatusForbidden, send(`{"wallet_id":"`+wallet.NewWallet().BlockchainAddress()+`","recipient_blockchain_address":"`+bob+`","value":1}`, cookies))
+	assert.Equal(t, http.StatusBadRequest, send(`{"wallet_id":"`+created["address"]+`","recipient_blockchain_address":"bob","value":1}`, cookies))
+	assert.Equal(t, http.StatusOK, send(body, cookies))
+	//
+	assert.Equal(t, created["address"], *relayed.SenderBlockchainAddress)
+	assert.Equal(t, created["public_key"], *relayed.SenderPublicKey)
+	assert.NotEmpty(t, *relayed.Signature)
+	//
+	resp, err = http.Post(srv.URL+"/v1/session", "application/json", strings.NewReader(`{"address":"`+created["address"]+`","password":"secret"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	other := resp.Cookies()
+	//
+	req, _ := http.NewRequest("DELETE", srv.URL+"/v1/session", nil)
+	req.AddCookie(cookies[0])
+	_, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, send(body, cookies))
+	assert.Equal(t, http.StatusOK, send(body, other))
+}
+
+func TestRestoreHDWallet(t *testing.T) {
+	//
+	mnemonic, _ := wallet.NewMnemonic()
+	account, _ := wallet.NewAccount(mnemonic, "")
+	funded, _ := account.Wallet(3)
+	//
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("blockchain_address") == funded.BlockchainAddress() {
+			w.Write([]byte(`{"amount": 7}`))
+			return
+		}
+		w.Write([]byte(`{"amount": 0}`))
+	}))
+	defer gateway.Close()
+	//
+	keystore, _ := wallet.NewKeystore(t.TempDir())
+	srv := httptest.NewServer(NewWalletServer(testConfig(gateway.URL), keystore).Router())
+	defer srv.Close()
+	//
+	body := `{"mnemonic":"` + mnemonic + `","password":"secret"}`
+	resp, err := http.Post(srv.URL+"/v1/wallet/restore", "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	//
+	var restored struct {
+		Addresses []HDAddress `json:"addresses"`
+	}
+	json.NewDecoder(resp.Body).Decode(&restored)
+	assert.Len(t, restored.Addresses, 2)
+	assert.Equal(t, uint32(0), restored.Addresses[0].Index)
+	assert.Equal(t, funded.BlockchainAddress(), restored.Addresses[1].Address)
+	assert.Equal(t, float32(7), restored.Addresses[1].Amount)
+	//
+	stored, _ := keystore.List()
+	assert.Len(t, stored, 2)
+	//
+	body = `{"mnemonic":"` + mnemonic + `","password":"secret","count":21}`
+	resp, err = http.Post(srv.URL+"/v1/wallet/restore", "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Post(srv.URL+"/v1/wallet/hd", "application/json", strings.NewReader(`{"password":"secret","count":1000}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	stored, _ = keystore.List()
+	assert.Len(t, stored, 2)
+}
+
+func TestMetrics(t *testing.T) {
+	//
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer gateway.Close()
+	//
+	keystore, err := wallet.NewKeystore(t.TempDir())
+	assert.NoError(t, err)
+	h := NewWalletServer(testConfig(gateway.URL), keystore).Router()
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/wallet/amount?blockchain_address=x", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	//
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `wallet_gateway_request_failures_total{operation="GET /v1/amount"}`)
+	assert.Contains(t, rec.Body.String(), `http_request_duration_seconds_count{code="502",route="GET /v1/wallet/amount"} 1`)
+}
+
+func TestReadyz(t *testing.T) {
+	//
+	status := http.StatusServiceUnavailable
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/readyz", req.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer gateway.Close()
+	//
+	keystore, err := wallet.NewKeystore(t.TempDir())
+	assert.NoError(t, err)
+	h := NewWalletServer(testConfig(gateway.URL), keystore).Router()
+	//
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	//
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var hr client.HealthResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hr))
+	assert.Equal(t, "ok", hr.Checks["keystore"])
+	assert.Contains(t, hr.Checks["gateway"], "503")
+	//
+	status = http.StatusOK
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestExpiredSessionLocksKey(t *testing.T) {
+	//
+	keystore, err := wallet.NewKeystore(t.TempDir())
+	assert.NoError(t, err)
+	ws := NewWalletServer(testConfig("http://127.0.0.1:1"), keystore)
+	ws.sessions.ttl = 20 * time.Millisecond
+	srv := httptest.NewServer(ws.Router())
+	defer srv.Close()
+	//
+	resp, err := http.Post(srv.URL+"/v1/wallet", "application/json", strings.NewReader(`{"password":"secret"}`))
+	assert.NoError(t, err)
+	var created map[string]string
+	json.NewDecoder(resp.Body).Decode(&created)
+	_, err = keystore.Wallet(created["address"])
+	assert.NoError(t, err)
+	//
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reapEvery(ctx, 5*time.Millisecond, ws.sessions.Reap)
+	assert.Eventually(t, func() bool {
+		_, err := keystore.Wallet(created["address"])
+		return errors.Is(err, wallet.ErrLocked)
+	}, time.Second, 5*time.Millisecond)
+}